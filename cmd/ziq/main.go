@@ -0,0 +1,82 @@
+// Command ziq renders and plays compositions made with ziq.
+//
+// A composition is either a JSON song file (see the song package),
+// or a Go file (or package directory) of package main exporting:
+//
+//	func Composition() wave.Wave
+//
+// Go compositions are compiled as plugins (or loaded directly when a compiled .so plugin is provided).
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/live"
+	"github.com/ejuju/ziq/pkg/song"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+const usage = `Usage: ziq <command> [flags] <composition>
+
+Commands:
+  render    render a composition to a WAV file
+  play      play a composition or an audio file (WAV or PCM)
+  serve     stream a composition or an audio file over HTTP
+  help      show this message
+
+Run "ziq <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "render":
+		err = render(os.Args[2:])
+	case "play":
+		err = play(os.Args[2:])
+	case "serve":
+		err = serve(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// loadComposition loads a composition from a song file, a Go source or a compiled plugin.
+// It also returns the duration of the composition when it is known (0 otherwise).
+func loadComposition(path string) (wave.Wave, time.Duration, error) {
+	switch filepath.Ext(path) {
+	case ".json":
+		s, err := song.Import(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		d, err := s.Duration()
+		if err != nil {
+			return nil, 0, err
+		}
+		w, err := s.Wave()
+		return w, d, err
+	case ".so":
+		w, err := live.LoadPlugin(path)
+		return w, 0, err
+	default:
+		w, err := live.Load(path)
+		return w, 0, err
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/viz"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+const seekStep = 5 * time.Second
+
+func play(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	duration := fs.Duration("duration", 10*time.Minute, "duration of a composition (audio files and songs play until their end)")
+	sampleRate := fs.Int("sample-rate", 44100, "sample rate in hertz")
+	scope := fs.Bool("scope", false, "show an oscilloscope and level meters in the terminal (instead of the ffplay window)")
+	seed := fs.Int64("seed", 0, "seed of the random waves of the composition (the same seed renders the same variation)")
+	record := fs.String("record", "", "also record the session to a timestamped WAV file in this directory")
+	click := fs.Float64("click", 0, "play a click track at this tempo (in BPM), it isn't recorded")
+	clickBar := fs.Int("click-bar", 4, "beats per bar of the click track (the first beat is accented)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ziq play [flags] <composition or audio file>")
+		fmt.Fprintln(fs.Output(), "Keys: space = play/pause, left/right arrows = seek, q = quit")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one composition or audio file")
+	}
+
+	wave.SetSeed(*seed)
+	src, end, err := loadPlayable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if end <= 0 || isFlagSet(fs, "duration") {
+		end = *duration
+	}
+
+	transport := audio.NewTransport(src, end)
+	config := audio.PlayerConfig{Wave: transport.Wave(), SampleRate: *sampleRate, RecordDir: *record}
+	if *click > 0 {
+		config.Click = transport.Follow(wave.Metronome(wave.Tempo{BPM: *click, BeatsPerBar: *clickBar}, wave.MetronomeConfig{}))
+	}
+	var view *viz.Scope
+	if *scope {
+		view = viz.NewScope(64, 12, 1)
+		config.Monitor = view.Write
+	}
+	player, err := audio.NewFFPlayPlayer(config)
+	if err != nil {
+		return err
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return fmt.Errorf("set terminal to raw mode: %w", err)
+	}
+	defer restore()
+
+	transport.Play()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	go func() {
+		<-transport.Done()
+		cancel()
+	}()
+	go handleKeys(transport, cancel)
+	if view != nil {
+		go view.Run(ctx, os.Stderr, 50*time.Millisecond)
+	}
+	go printStatus(ctx, transport, end)
+
+	err = player.PlayContext(ctx)
+	fmt.Fprint(os.Stderr, "\r\n")
+	return err
+}
+
+// loadPlayable loads a WAV file, a PCM file (f64le mono at 44100Hz) or a composition,
+// it also returns its duration when it is known (0 otherwise).
+func loadPlayable(path string) (wave.Wave, time.Duration, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		c, err := wave.ImportWavClip(path)
+		return c.Wave, c.Duration, err
+	case ".pcm":
+		c, err := wave.ImportPCMClip(path, 44100)
+		return c.Wave, c.Duration, err
+	default:
+		w, d, err := loadComposition(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("load composition: %w", err)
+		}
+		return w, d, nil
+	}
+}
+
+func handleKeys(t *audio.Transport, quit func()) {
+	buf := make([]byte, 3)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			quit()
+			return
+		}
+		switch key := string(buf[:n]); key {
+		case " ":
+			t.Toggle()
+		case "q":
+			quit()
+			return
+		case "\x1b[C": // right arrow
+			t.Seek(t.Position() + seekStep)
+		case "\x1b[D": // left arrow
+			t.Seek(t.Position() - seekStep)
+		}
+	}
+}
+
+func printStatus(ctx context.Context, t *audio.Transport, end time.Duration) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		state := "playing"
+		if !t.Playing() {
+			state = "paused "
+		}
+		fmt.Fprintf(os.Stderr, "\r%s %s / %s ", state, t.Position().Truncate(100*time.Millisecond), end)
+	}
+}
+
+// rawTerminal disables line buffering and echo on the terminal (using stty),
+// so keys are received as soon as they are pressed.
+func rawTerminal() (func(), error) {
+	stty := func(args ...string) error {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty("sane") }, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/viz"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func render(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	out := fs.String("out", "out.wav", "output WAV file")
+	duration := fs.Duration("duration", 10*time.Second, "duration of the render (for example: 2m30s), songs default to their own duration")
+	sampleRate := fs.Int("sample-rate", 44100, "sample rate in hertz")
+	waveform := fs.String("waveform", "", "also draw the waveform of the render to a PNG file")
+	spectrogram := fs.String("spectrogram", "", "also draw the spectrogram of the render to a PNG file")
+	loudness := fs.String("loudness", "", `normalize the loudness of the render to a target in LUFS, or to a preset: "streaming" (-14) or "podcast" (-16)`)
+	limit := fs.Float64("limit", 0, "limit true peaks to this level in dBTP as the last stage of the render (for example: -1, as asked by streaming platforms)")
+	failOnClip := fs.Bool("fail-on-clip", false, "fail the render if samples are outside of [-1, 1] (distorted when played or exported)")
+	seed := fs.Int64("seed", 0, "seed of the random waves of the composition (the same seed renders the same variation)")
+	parallel := fs.Bool("parallel", false, "render on all CPU cores (only for compositions without stateful waves, like filters or envelopes)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ziq render [flags] <composition>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one composition")
+	}
+	if *sampleRate <= 0 {
+		return fmt.Errorf("invalid sample rate: %d", *sampleRate)
+	}
+
+	wave.SetSeed(*seed)
+	src, natural, err := loadComposition(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("load composition: %w", err)
+	}
+	if natural > 0 && !isFlagSet(fs, "duration") {
+		*duration = natural
+	}
+	if *duration <= 0 {
+		return fmt.Errorf("invalid duration: %s", *duration)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("create file: %s: %w", *out, err)
+	}
+	defer f.Close()
+	w, err := audio.NewWavWriter(f, *sampleRate, 1)
+	if err != nil {
+		return err
+	}
+
+	var limiter *audio.Limiter
+	if isFlagSet(fs, "limit") {
+		limiter = audio.NewLimiter(*sampleRate, 1, audio.LimiterConfig{Ceiling: math.Pow(10, *limit/20)})
+	}
+	target, normalize := 0.0, *loudness != ""
+	if normalize {
+		if target, err = parseLoudness(*loudness); err != nil {
+			return err
+		}
+	}
+
+	all := []float64{} // kept to draw the waveform and spectrogram
+	meter := analyze.NewMeter(*sampleRate, 1)
+	clipping := analyze.NewClipDetector(*sampleRate, 1)
+
+	// Frames are written once limited (so levels and clipping are those of the file)
+	write := func(frames []float64) error {
+		if err := w.Write(frames); err != nil {
+			return fmt.Errorf("encode frames: %w", err)
+		}
+		meter.Write(frames)
+		clipping.Write(frames)
+		if *waveform != "" || *spectrogram != "" {
+			all = append(all, frames...)
+		}
+		return nil
+	}
+
+	workers := 1
+	if *parallel {
+		workers = 0
+	}
+
+	// Render chunk by chunk (one second each) to report progress.
+	// To normalize loudness, the whole render is measured before its gain is applied, so it is kept until then.
+	total := int(int64(*duration) * int64(*sampleRate) / int64(time.Second))
+	pending := []float64{}
+	for rendered := 0; rendered < total; {
+		count := *sampleRate
+		if rendered+count > total {
+			count = total - rendered
+		}
+		frames := audio.ParallelFrameRange(src, *sampleRate, rendered, count, workers)
+		if normalize {
+			pending = append(pending, frames...)
+		} else if limiter != nil {
+			frames = limiter.Process(frames)
+		}
+		if !normalize {
+			if err := write(frames); err != nil {
+				return err
+			}
+		}
+		rendered += count
+		printProgress(os.Stderr, rendered, total)
+	}
+	fmt.Fprintln(os.Stderr)
+	if normalize {
+		gain := audio.NormalizeLoudness(pending, *sampleRate, 1, target)
+		fmt.Fprintf(os.Stderr, "normalized loudness to %.1f LUFS (%+.1f dB)\n", target, 20*math.Log10(gain))
+		if limiter != nil {
+			pending = limiter.Process(pending)
+		}
+		if err := write(pending); err != nil {
+			return err
+		}
+	}
+	if limiter != nil {
+		if err := write(limiter.Flush()); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("encode WAV header: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file: %s: %w", *out, err)
+	}
+	fmt.Fprintf(os.Stderr, "rendered %s to %s\n", *duration, *out)
+	levels := meter.Levels()
+	fmt.Fprintf(os.Stderr, "peak %.1f dBFS, RMS %.1f dBFS, loudness %.1f LUFS\n", levels.PeakDecibels(), levels.RMSDecibels(), levels.LUFS)
+	report := clipping.Report()
+	fmt.Fprintln(os.Stderr, report)
+	if report.Samples > 0 && *failOnClip {
+		return errors.New("render clips")
+	}
+
+	if *waveform != "" {
+		img, err := viz.WaveformPNG(all, 1200, 200)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*waveform, img, 0o644); err != nil {
+			return fmt.Errorf("write file: %s: %w", *waveform, err)
+		}
+		fmt.Fprintf(os.Stderr, "drew waveform to %s\n", *waveform)
+	}
+	if *spectrogram != "" {
+		img, err := viz.SpectrogramPNG(all, 1200, 400, viz.SpectrogramConfig{SampleRate: *sampleRate, LogFrequency: true})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*spectrogram, img, 0o644); err != nil {
+			return fmt.Errorf("write file: %s: %w", *spectrogram, err)
+		}
+		fmt.Fprintf(os.Stderr, "drew spectrogram to %s\n", *spectrogram)
+	}
+	return nil
+}
+
+// parseLoudness parses a loudness target in LUFS, or the name of a preset.
+func parseLoudness(s string) (float64, error) {
+	switch s {
+	case "streaming":
+		return audio.LoudnessStreaming, nil
+	case "podcast":
+		return audio.LoudnessPodcast, nil
+	}
+	target, err := strconv.ParseFloat(s, 64)
+	if err != nil || target >= 0 {
+		return 0, fmt.Errorf("invalid loudness target: %q", s)
+	}
+	return target, nil
+}
+
+// printProgress prints a progress bar on a single (overwritten) line.
+func printProgress(w io.Writer, done, total int) {
+	const width = 30
+	filled := done * width / total
+	fmt.Fprintf(w, "\r[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat(".", width-filled), done*100/total)
+}
+
+// isFlagSet reports whether a flag was explicitly provided.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) { found = found || f.Name == name })
+	return found
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/ejuju/ziq/pkg/audio"
+)
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8000", "HTTP address to listen on")
+	format := fs.String("format", audio.StreamWav, "stream format: wav, or ogg (encoded with ffmpeg)")
+	sampleRate := fs.Int("sample-rate", 44100, "sample rate in hertz")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ziq serve [flags] <composition or audio file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one composition or audio file")
+	}
+
+	src, _, err := loadPlayable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	stream, err := audio.NewStreamServer(src, *sampleRate, *format)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("listen: %s: %w", *addr, err)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	srv := &http.Server{Handler: stream}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go stream.Run(ctx)
+
+	fmt.Fprintf(os.Stderr, "streaming on http://%s\n", l.Addr())
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
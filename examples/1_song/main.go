@@ -4,24 +4,37 @@ import (
 	"time"
 
 	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/drums"
+	"github.com/ejuju/ziq/pkg/mixer"
+	"github.com/ejuju/ziq/pkg/pattern"
 	"github.com/ejuju/ziq/pkg/wave"
 )
 
 func main() {
 	totalDuration := 10 * time.Second
 
-	kick := wave.MustImportWav("audio_files/kick2.wav")
-	kick = wave.Amplitude(kick, wave.Const(0.5))
-	kick = wave.Loop(kick, time.Second/2)
+	kick, err := drums.SamplePad("audio_files/kick2.wav")
+	if err != nil {
+		panic(err)
+	}
+	kit := drums.Kit{"kick": kick, "hat": drums.HiHat(50 * time.Millisecond)}
+	beat := drums.Sequence(map[string]pattern.Pattern{
+		"kick": pattern.MustParseTriggers("x---x---"),
+		"hat":  pattern.MustParseTriggers("--x---x-"),
+	}, time.Second/8, 10)
 
 	freq := wave.Lerp(440, 880, totalDuration/3)
-	sine1 := wave.Amplitude(wave.OscillateSine(freq), wave.Const(0.5))
+	sine1 := wave.OscillateSine(freq)
 
-	mix := wave.Combine(sine1, kick)
-	mix = wave.Loop(mix, totalDuration/2)
+	mix := mixer.Mixer{Tracks: []mixer.Track{
+		{Name: "sine", Wave: sine1, Gain: wave.Const(0.5), Pan: wave.Const(-0.3)},
+		{Name: "drums", Wave: kit.Play(beat), Gain: wave.Const(0.5)},
+	}}
+	stereo := mix.Output()
+	out := wave.StereoWave{Left: wave.Loop(stereo.Left, totalDuration/2), Right: wave.Loop(stereo.Right, totalDuration/2)}
 
-	// Play wave with ffplay.
-	config := audio.FFPlayPlayerConfig{Wave: mix, Duration: totalDuration}
+	// Play the stereo wave with ffplay.
+	config := audio.FFPlayPlayerConfig{Routes: audio.StereoRoutes(out), Duration: totalDuration}
 	player, err := audio.NewFFPlayPlayer(config)
 	if err != nil {
 		panic(err)
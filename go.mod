@@ -2,9 +2,15 @@ module github.com/ejuju/ziq
 
 go 1.18
 
-require github.com/go-audio/wav v1.1.0
+require (
+	github.com/ebitengine/oto/v3 v3.3.0
+	github.com/go-audio/audio v1.0.0
+	github.com/go-audio/wav v1.1.0
+	github.com/gordonklaus/portaudio v0.0.0-20260203164431-765aa7dfa631
+)
 
 require (
-	github.com/go-audio/audio v1.0.0 // indirect
+	github.com/ebitengine/purego v0.8.0 // indirect
 	github.com/go-audio/riff v1.0.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
 )
@@ -0,0 +1,116 @@
+package analyze
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Maximum number of clipping events kept by a report (all of them are still counted),
+// and listed by its summary.
+const (
+	maxClipEvents   = 100
+	clipEventsShown = 5
+)
+
+// ClipEvent is a run of consecutive frames with samples outside of [-1, 1] (distorted when played or exported).
+type ClipEvent struct {
+	Start    time.Duration
+	Duration time.Duration
+	// Samples is the number of clipped samples of the run.
+	Samples int
+	// Peak is the highest absolute sample value of the run.
+	Peak float64
+}
+
+// ClippingReport lists where frames clip.
+type ClippingReport struct {
+	// Samples is the number of clipped samples.
+	Samples int
+	// Runs is the number of clipped runs.
+	Runs int
+	// Events are the first clipped runs (at most 100).
+	Events []ClipEvent
+}
+
+// String summarizes the report with its first events,
+// for example: "120 clipped samples in 2 places: 1.5s (80 samples, +1.2dB), 3s (40 samples, +0.4dB)".
+func (r ClippingReport) String() string {
+	if r.Samples == 0 {
+		return "no clipping"
+	}
+	places := []string{}
+	for i, e := range r.Events {
+		if i == clipEventsShown {
+			places = append(places, "...")
+			break
+		}
+		places = append(places, fmt.Sprintf("%s (%d samples, %+.1fdB)", e.Start.Round(time.Millisecond), e.Samples, 20*math.Log10(e.Peak)))
+	}
+	return fmt.Sprintf("%d clipped samples in %d places: %s", r.Samples, r.Runs, strings.Join(places, ", "))
+}
+
+// DetectClipping reports the samples of interleaved frames outside of [-1, 1].
+func DetectClipping(frames []float64, sampleRate, channels int) ClippingReport {
+	d := NewClipDetector(sampleRate, channels)
+	d.Write(frames)
+	return d.Report()
+}
+
+// ClipDetector detects clipping in interleaved frames as they are rendered.
+type ClipDetector struct {
+	sampleRate, channels int
+	report               ClippingReport
+	next                 int // index of the next frame
+	run                  int // number of frames of the current run (0 when not clipping)
+}
+
+// NewClipDetector creates a detector for frames with the given number of channels.
+func NewClipDetector(sampleRate, channels int) *ClipDetector {
+	if channels <= 0 {
+		channels = 1
+	}
+	return &ClipDetector{sampleRate: sampleRate, channels: channels}
+}
+
+// Write checks the next frames.
+func (d *ClipDetector) Write(frames []float64) {
+	for i := 0; i+d.channels <= len(frames); i += d.channels {
+		peak, clipped := 0.0, 0
+		for _, v := range frames[i : i+d.channels] {
+			if v = math.Abs(v); v > 1 {
+				clipped++
+				peak = math.Max(peak, v)
+			}
+		}
+
+		d.report.Samples += clipped
+		switch {
+		case peak > 0 && d.run == 0:
+			if d.report.Runs < maxClipEvents {
+				d.report.Events = append(d.report.Events, ClipEvent{Start: d.frameTime(d.next)})
+			}
+			d.report.Runs++
+			d.run = 1
+		case peak > 0:
+			d.run++
+		default:
+			d.run = 0
+		}
+		if peak > 0 && d.report.Runs <= maxClipEvents {
+			e := &d.report.Events[len(d.report.Events)-1]
+			e.Duration = d.frameTime(d.run)
+			e.Samples += clipped
+			e.Peak = math.Max(e.Peak, peak)
+		}
+		d.next++
+	}
+}
+
+// Report returns the clipping found in the frames written so far.
+func (d *ClipDetector) Report() ClippingReport { return d.report }
+
+func (d *ClipDetector) frameTime(i int) time.Duration {
+	return time.Duration(int64(i) * int64(time.Second) / int64(d.sampleRate))
+}
@@ -0,0 +1,153 @@
+package analyze
+
+import "math"
+
+// Levels are the loudness measurements of a render.
+type Levels struct {
+	// Peak is the highest absolute sample value (1 is 0 dBFS).
+	Peak float64
+	// RMS is the root mean square of all samples.
+	RMS float64
+	// LUFS is the integrated loudness (ITU-R BS.1770), used by streaming platforms to normalize tracks
+	// (-Inf for silence).
+	LUFS float64
+}
+
+// PeakDecibels returns the peak in dBFS.
+func (l Levels) PeakDecibels() float64 { return 20 * math.Log10(l.Peak) }
+
+// RMSDecibels returns the RMS level in dBFS.
+func (l Levels) RMSDecibels() float64 { return 20 * math.Log10(l.RMS) }
+
+// Measure computes the levels of interleaved frames.
+func Measure(frames []float64, sampleRate, channels int) Levels {
+	m := NewMeter(sampleRate, channels)
+	m.Write(frames)
+	return m.Levels()
+}
+
+// Loudness returns the integrated loudness of interleaved frames in LUFS (see Meter).
+func Loudness(frames []float64, sampleRate, channels int) float64 {
+	return Measure(frames, sampleRate, channels).LUFS
+}
+
+// Meter measures the levels of interleaved frames as they are rendered,
+// so long renders don't have to be kept in memory.
+//
+// Loudness is integrated as specified by ITU-R BS.1770-4:
+// the frames are K-weighted (to follow the sensitivity of human hearing),
+// and quiet parts are ignored (gated) so silence between songs or sections doesn't lower the result.
+// All channels are weighted as front channels.
+type Meter struct {
+	sampleRate, channels int
+	filters              [][2]*biquad // K-weighting of each channel
+
+	peak, sum float64 // of all samples
+	count     int     // number of frames
+
+	hop      int       // number of frames of a 100ms step
+	hopSum   float64   // sum of the K-weighted squares of the current step
+	hopCount int       // number of frames of the current step
+	hops     []float64 // mean squares of the completed steps
+}
+
+// NewMeter creates a meter for frames with the given number of channels.
+func NewMeter(sampleRate, channels int) *Meter {
+	if channels <= 0 {
+		channels = 1
+	}
+	m := &Meter{sampleRate: sampleRate, channels: channels, hop: sampleRate / 10}
+	for c := 0; c < channels; c++ {
+		shelf, highPass := kWeighting(sampleRate)
+		m.filters = append(m.filters, [2]*biquad{shelf, highPass})
+	}
+	return m
+}
+
+// Write measures the next frames.
+func (m *Meter) Write(frames []float64) {
+	for i := 0; i+m.channels <= len(frames); i += m.channels {
+		for c, f := range m.filters {
+			v := frames[i+c]
+			m.peak = math.Max(m.peak, math.Abs(v))
+			m.sum += v * v
+			w := f[1].process(f[0].process(v))
+			m.hopSum += w * w
+		}
+		m.count++
+		if m.hopCount++; m.hopCount == m.hop {
+			m.hops = append(m.hops, m.hopSum/float64(m.hop))
+			m.hopSum, m.hopCount = 0, 0
+		}
+	}
+}
+
+// Levels returns the levels of the frames written so far.
+// Loudness is -Inf for silence (and less than 400ms of frames).
+func (m *Meter) Levels() Levels {
+	l := Levels{Peak: m.peak, LUFS: math.Inf(-1)}
+	if m.count > 0 {
+		l.RMS = math.Sqrt(m.sum / float64(m.count*m.channels))
+	}
+
+	// Mean squares of overlapping blocks of 400ms (every 100ms)
+	powers := []float64{}
+	for i := 0; i+4 <= len(m.hops); i++ {
+		powers = append(powers, (m.hops[i]+m.hops[i+1]+m.hops[i+2]+m.hops[i+3])/4)
+	}
+	loudness := func(power float64) float64 { return -0.691 + 10*math.Log10(power) }
+	gated := func(threshold float64) float64 {
+		sum, n := 0.0, 0
+		for _, p := range powers {
+			if loudness(p) > threshold {
+				sum += p
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		return sum / float64(n)
+	}
+	if absolute := gated(-70); absolute > 0 {
+		if relative := gated(loudness(absolute) - 10); relative > 0 {
+			l.LUFS = loudness(relative)
+		}
+	}
+	return l
+}
+
+// biquad is a second-order IIR filter.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x1, f.x2, f.y1, f.y2 = x, f.x1, y, f.y1
+	return y
+}
+
+// kWeighting returns the filters of the K-weighting at the sample rate
+// (the coefficients of BS.1770 are for 48kHz, they are derived for other rates like libebur128 does).
+func kWeighting(sampleRate int) (shelf, highPass *biquad) {
+	k := math.Tan(math.Pi * 1681.974450955533 / float64(sampleRate))
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf = &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * 38.13547087602444 / float64(sampleRate))
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass = &biquad{b0: 1, b1: -2, b2: 1, a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0}
+	return shelf, highPass
+}
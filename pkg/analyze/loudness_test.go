@@ -0,0 +1,100 @@
+package analyze
+
+import (
+	"math"
+	"testing"
+)
+
+// interleave returns the frames of each channel, interleaved.
+func interleave(channels ...[]float64) []float64 {
+	out := make([]float64, 0, len(channels)*len(channels[0]))
+	for i := range channels[0] {
+		for _, c := range channels {
+			out = append(out, c[i])
+		}
+	}
+	return out
+}
+
+func TestMeasure(t *testing.T) {
+	const sampleRate = 48000
+	tone := sine(997, 0.1, sampleRate, 2*sampleRate) // -20 dBFS
+	quiet := sine(997, 0.0001, sampleRate, 2*sampleRate)
+	silence := make([]float64, 2*sampleRate)
+	tests := []struct {
+		name       string
+		frames     []float64
+		sampleRate int
+		channels   int
+		peak, rms  float64 // in dBFS
+		lufs       float64
+	}{
+		{name: "mono sine", frames: tone, sampleRate: sampleRate, channels: 1, peak: -20, rms: -23.01, lufs: -23},
+		{name: "mono sine at 44.1kHz", frames: sine(997, 0.1, 44100, 88200), sampleRate: 44100, channels: 1, peak: -20, rms: -23.01, lufs: -23},
+		{name: "stereo sine", frames: interleave(tone, tone), sampleRate: sampleRate, channels: 2, peak: -20, rms: -23.01, lufs: -20},
+		{name: "sine on one channel", frames: interleave(tone, silence), sampleRate: sampleRate, channels: 2, peak: -20, rms: -26.02, lufs: -23},
+		// Only the 3 blocks overlapping the end of the sine lower the loudness (by 10*log10(18.5/20) dB)
+		{name: "silence is gated", frames: append(append([]float64(nil), tone...), silence...), sampleRate: sampleRate, channels: 1, peak: -20, rms: -26.02, lufs: -23.34},
+		{name: "under the absolute gate", frames: quiet, sampleRate: sampleRate, channels: 1, peak: -80, rms: -83.01, lufs: math.Inf(-1)},
+		{name: "shorter than a block", frames: tone[:sampleRate/4], sampleRate: sampleRate, channels: 1, peak: -20, rms: -23.01, lufs: math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Measure(tt.frames, tt.sampleRate, tt.channels)
+			if math.Abs(l.PeakDecibels()-tt.peak) > 0.01 {
+				t.Errorf("peak = %v dBFS, want %v", l.PeakDecibels(), tt.peak)
+			}
+			if math.Abs(l.RMSDecibels()-tt.rms) > 0.01 {
+				t.Errorf("RMS = %v dBFS, want %v", l.RMSDecibels(), tt.rms)
+			}
+			if math.IsInf(tt.lufs, -1) != math.IsInf(l.LUFS, -1) || (!math.IsInf(tt.lufs, -1) && math.Abs(l.LUFS-tt.lufs) > 0.1) {
+				t.Errorf("loudness = %v LUFS, want %v", l.LUFS, tt.lufs)
+			}
+		})
+	}
+}
+
+func TestMeterWrites(t *testing.T) {
+	frames := interleave(sine(440, 0.5, 44100, 44100), sine(1000, 0.25, 44100, 44100))
+	want := Measure(frames, 44100, 2)
+
+	m := NewMeter(44100, 2)
+	for i := 0; i < len(frames); i += 2 * 300 {
+		end := i + 2*300
+		if end > len(frames) {
+			end = len(frames)
+		}
+		m.Write(frames[i:end])
+	}
+	if got := m.Levels(); got != want {
+		t.Errorf("levels written in blocks = %+v, want %+v", got, want)
+	}
+}
+
+func TestKWeighting(t *testing.T) {
+	// The filters of BS.1770 at 48kHz
+	shelf, highPass := kWeighting(48000)
+	tests := []struct {
+		name string
+		got  []float64
+		want []float64
+	}{
+		{
+			name: "shelf",
+			got:  []float64{shelf.b0, shelf.b1, shelf.b2, shelf.a1, shelf.a2},
+			want: []float64{1.53512485958697, -2.69169618940638, 1.19839281085285, -1.69065929318241, 0.73248077421585},
+		},
+		{
+			name: "high-pass",
+			got:  []float64{highPass.b0, highPass.b1, highPass.b2, highPass.a1, highPass.a2},
+			want: []float64{1, -2, 1, -1.99004745483398, 0.99007225036621},
+		},
+	}
+	for _, tt := range tests {
+		for i := range tt.got {
+			if math.Abs(tt.got[i]-tt.want[i]) > 1e-6 {
+				t.Errorf("%s coefficient %d = %v, want %v", tt.name, i, tt.got[i], tt.want[i])
+			}
+		}
+	}
+}
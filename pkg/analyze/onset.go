@@ -0,0 +1,117 @@
+package analyze
+
+import (
+	"math"
+	"time"
+)
+
+// Analysis frames of onset detection (about 23ms at 44100Hz), overlapping by half.
+const (
+	onsetWindow = 1024
+	onsetHop    = onsetWindow / 2
+)
+
+// OnsetConfig tunes onset detection.
+type OnsetConfig struct {
+	// Sensitivity is between 0 and 1 (default: 0.5), higher values detect softer hits.
+	Sensitivity float64
+	// MinInterval is the shortest time between two onsets (default: 50ms), to ignore the rebounds of a hit.
+	MinInterval time.Duration
+}
+
+func (c *OnsetConfig) setDefaults() {
+	if c.Sensitivity <= 0 || c.Sensitivity > 1 {
+		c.Sensitivity = 0.5
+	}
+	if c.MinInterval <= 0 {
+		c.MinInterval = 50 * time.Millisecond
+	}
+}
+
+// Onsets returns when the hits (or notes) of (mono) frames start,
+// for example to slice a drum loop of unknown tempo and re-sequence its hits.
+//
+// Hits are found where the spectrum gets louder (spectral flux) much faster than around them,
+// then placed precisely at the start of their attack.
+func Onsets(frames []float64, sampleRate int, config OnsetConfig) []time.Duration {
+	config.setDefaults()
+	if len(frames) < onsetWindow {
+		return nil
+	}
+
+	// Spectral flux: how much louder each analysis frame is than the previous one
+	count := (len(frames)-onsetWindow)/onsetHop + 1
+	flux := make([]float64, count)
+	prev, buf := make([]float64, onsetWindow/2+1), make([]complex128, onsetWindow)
+	for t := 0; t < count; t++ {
+		for i := range buf {
+			buf[i] = complex(frames[t*onsetHop+i]*Hann(i, onsetWindow), 0)
+		}
+		FFT(buf)
+		for k := range prev {
+			// Amplitude (1 for a full-scale sine), compressed so soft hits count
+			magnitude := math.Log1p(100 * 4 * math.Hypot(real(buf[k]), imag(buf[k])) / onsetWindow)
+			if t > 0 && magnitude > prev[k] {
+				flux[t] += magnitude - prev[k]
+			}
+			prev[k] = magnitude
+		}
+	}
+
+	// Peaks above an adaptive threshold (the local mean, scaled by the sensitivity)
+	const around = 8 // analysis frames around a peak (about 100ms each way)
+	scale := 1 + 2*(1-config.Sensitivity)
+	floor := 0.0
+	for _, f := range flux {
+		floor = math.Max(floor, f)
+	}
+	// Ignore the noise of quiet parts, and the small variations of steady sounds
+	floor = math.Max(floor*0.02*(1-config.Sensitivity), 1-config.Sensitivity)
+
+	minGap := int(config.MinInterval.Seconds() * float64(sampleRate))
+	onsets := []time.Duration{}
+	last := -minGap
+	for t := 1; t < count; t++ {
+		if flux[t] <= floor || flux[t] < flux[t-1] || (t+1 < count && flux[t] < flux[t+1]) {
+			continue
+		}
+		sum, n := 0.0, 0
+		for i := t - around; i <= t+around; i++ {
+			if i >= 0 && i < count {
+				sum += flux[i]
+				n++
+			}
+		}
+		if flux[t] < scale*sum/float64(n) {
+			continue
+		}
+
+		at := attackStart(frames, t*onsetHop+onsetWindow/2-onsetHop, t*onsetHop+onsetWindow/2+onsetHop)
+		if at-last < minGap {
+			continue
+		}
+		last = at
+		onsets = append(onsets, time.Duration(int64(at)*int64(time.Second)/int64(sampleRate)))
+	}
+	return onsets
+}
+
+// attackStart returns the first frame between start and end reaching half of the peak level of the range.
+func attackStart(frames []float64, start, end int) int {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(frames) {
+		end = len(frames)
+	}
+	peak := 0.0
+	for _, v := range frames[start:end] {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	for i := start; i < end; i++ {
+		if math.Abs(frames[i]) >= peak/2 {
+			return i
+		}
+	}
+	return start
+}
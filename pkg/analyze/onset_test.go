@@ -0,0 +1,63 @@
+package analyze
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// hits returns a second of decaying noise bursts (drum-like hits) starting at the given times.
+func hits(sampleRate int, at ...time.Duration) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	frames := make([]float64, sampleRate)
+	for _, start := range at {
+		first := int(start.Seconds() * float64(sampleRate))
+		for i := first; i < len(frames); i++ {
+			frames[i] += (2*rng.Float64() - 1) * 0.8 * math.Exp(-float64(i-first)/float64(sampleRate)/0.015)
+		}
+	}
+	return frames
+}
+
+func TestOnsets(t *testing.T) {
+	const sampleRate = 44100
+	tests := []struct {
+		name   string
+		frames []float64
+		config OnsetConfig
+		want   []time.Duration
+	}{
+		{
+			name:   "hits",
+			frames: hits(sampleRate, 100*time.Millisecond, 350*time.Millisecond, 600*time.Millisecond, 850*time.Millisecond),
+			want:   []time.Duration{100 * time.Millisecond, 350 * time.Millisecond, 600 * time.Millisecond, 850 * time.Millisecond},
+		},
+		{
+			name:   "close hits",
+			frames: hits(sampleRate, 200*time.Millisecond, 280*time.Millisecond),
+			want:   []time.Duration{200 * time.Millisecond, 280 * time.Millisecond},
+		},
+		{
+			name:   "hits closer than the minimum interval",
+			frames: hits(sampleRate, 200*time.Millisecond, 280*time.Millisecond),
+			config: OnsetConfig{MinInterval: 150 * time.Millisecond},
+			want:   []time.Duration{200 * time.Millisecond},
+		},
+		{name: "silence", frames: make([]float64, sampleRate), want: []time.Duration{}},
+		{name: "shorter than an analysis frame", frames: make([]float64, 100), want: nil},
+	}
+	const tolerance = 3 * time.Millisecond
+	for _, tt := range tests {
+		got := Onsets(tt.frames, sampleRate, tt.config)
+		if len(got) != len(tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("%s: onsets = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if d := got[i] - tt.want[i]; d < -tolerance || d > tolerance {
+				t.Errorf("%s: onset %d at %s, want %s", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
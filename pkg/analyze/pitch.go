@@ -0,0 +1,129 @@
+package analyze
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// PitchConfig limits the pitches that can be detected.
+type PitchConfig struct {
+	// MinFrequency and MaxFrequency are the detected range (default: 50Hz to 2000Hz).
+	// Lower minimum frequencies need longer analyses (at least two periods).
+	MinFrequency, MaxFrequency float64
+	// Threshold is the highest aperiodicity of a detected pitch, between 0 and 1 (default: 0.15):
+	// higher values detect pitch in noisier sounds, but make more mistakes (usually octave errors).
+	Threshold float64
+}
+
+func (c *PitchConfig) setDefaults() {
+	if c.MinFrequency <= 0 {
+		c.MinFrequency = 50
+	}
+	if c.MaxFrequency <= c.MinFrequency {
+		c.MaxFrequency = math.Max(2000, 2*c.MinFrequency)
+	}
+	if c.Threshold <= 0 {
+		c.Threshold = 0.15
+	}
+}
+
+// Pitch is a detected fundamental frequency.
+type Pitch struct {
+	Frequency float64
+	// Confidence is between 0 and 1 (1 for a perfectly periodic sound).
+	Confidence float64
+}
+
+// Note returns the closest note and the deviation from it in cents (between -50 and 50),
+// for example to tune a sample to the key of a song.
+func (p Pitch) Note() (note.Note, float64) {
+	semitones := 69 + 12*math.Log2(p.Frequency/440)
+	n := math.Round(semitones)
+	return note.Note(n), 100 * (semitones - n)
+}
+
+// DetectPitch estimates the fundamental frequency of (mono) frames with the YIN algorithm.
+// It returns false if no pitch was found (silence, noise, or fewer frames than two periods of the minimum frequency).
+func DetectPitch(frames []float64, sampleRate int, config PitchConfig) (Pitch, bool) {
+	config.setDefaults()
+	tauMin := int(float64(sampleRate) / config.MaxFrequency)
+	tauMax := int(math.Ceil(float64(sampleRate) / config.MinFrequency))
+	if tauMin < 2 {
+		tauMin = 2
+	}
+	size := len(frames) - tauMax // number of frames compared for each lag
+	if size < tauMax {
+		return Pitch{}, false
+	}
+
+	// Cumulative mean normalized difference of the frames with their lagged version
+	diff := make([]float64, tauMax+2)
+	sum := 0.0
+	for tau := 1; tau < len(diff); tau++ {
+		d := 0.0
+		for j := 0; j < size && j+tau < len(frames); j++ {
+			delta := frames[j] - frames[j+tau]
+			d += delta * delta
+		}
+		sum += d
+		if sum == 0 {
+			return Pitch{}, false // silence
+		}
+		diff[tau] = d * float64(tau) / sum
+	}
+
+	// First dip under the threshold (its lowest point), the shortest periodic lag
+	tau := -1
+	for t := tauMin; t <= tauMax; t++ {
+		if diff[t] < config.Threshold {
+			for t+1 <= tauMax && diff[t+1] < diff[t] {
+				t++
+			}
+			tau = t
+			break
+		}
+	}
+	if tau < 0 {
+		return Pitch{}, false
+	}
+
+	// Refine the lag between frames with a parabola through the neighbouring values
+	period := float64(tau)
+	if a, b, c := diff[tau-1], diff[tau], diff[tau+1]; a+c-2*b != 0 {
+		period += (a - c) / (2 * (a + c - 2*b))
+	}
+	return Pitch{Frequency: float64(sampleRate) / period, Confidence: 1 - diff[tau]}, true
+}
+
+// PitchPoint is a pitch detected at a given time.
+type PitchPoint struct {
+	Time time.Duration
+	Pitch
+}
+
+// TrackPitch follows the pitch of a wave during d, with analyses of the given length every hop.
+// The wave is rendered once, in order, so it can be stateful.
+// Analyses without pitch are omitted.
+func TrackPitch(src wave.Wave, sampleRate int, d, length, hop time.Duration, config PitchConfig) []PitchPoint {
+	size := int(length.Seconds() * float64(sampleRate))
+	step := int(hop.Seconds() * float64(sampleRate))
+	total := int(d.Seconds() * float64(sampleRate))
+	if size <= 0 || step <= 0 {
+		return nil
+	}
+
+	frames := make([]float64, 0, total)
+	points := []PitchPoint{}
+	for start := 0; start+size <= total; start += step {
+		for i := len(frames); i < start+size; i++ {
+			frames = append(frames, src(time.Duration(int64(i)*int64(time.Second)/int64(sampleRate))))
+		}
+		if p, ok := DetectPitch(frames[start:start+size], sampleRate, config); ok {
+			points = append(points, PitchPoint{Time: time.Duration(int64(start) * int64(time.Second) / int64(sampleRate)), Pitch: p})
+		}
+	}
+	return points
+}
@@ -0,0 +1,105 @@
+package analyze
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestDetectPitch(t *testing.T) {
+	const sampleRate = 44100
+	harmonics := sine(220, 0.5, sampleRate, 4096)
+	for i, v := range sine(440, 0.3, sampleRate, 4096) {
+		harmonics[i] += v
+	}
+	noise := make([]float64, 4096)
+	rng := rand.New(rand.NewSource(1))
+	for i := range noise {
+		noise[i] = 2*rng.Float64() - 1
+	}
+
+	tests := []struct {
+		name      string
+		frames    []float64
+		config    PitchConfig
+		frequency float64 // 0 when no pitch should be found
+	}{
+		{name: "A4", frames: sine(440, 1, sampleRate, 4096), frequency: 440},
+		{name: "A2", frames: sine(110, 0.5, sampleRate, 4096), frequency: 110},
+		{name: "between bins", frames: sine(261.63, 1, sampleRate, 4096), frequency: 261.63},
+		{name: "with a harmonic", frames: harmonics, frequency: 220},
+		{name: "below the range", frames: sine(30, 1, sampleRate, 4096)},
+		{name: "subharmonic in range", frames: sine(3000, 1, sampleRate, 4096), config: PitchConfig{MaxFrequency: 1000}, frequency: 1000},
+		{name: "silence", frames: make([]float64, 4096)},
+		{name: "noise", frames: noise},
+		{name: "too short", frames: sine(440, 1, sampleRate, 1000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := DetectPitch(tt.frames, sampleRate, tt.config)
+			if ok != (tt.frequency != 0) {
+				t.Fatalf("found = %v (%+v), want %v", ok, p, tt.frequency != 0)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(p.Frequency-tt.frequency) > tt.frequency*0.001 {
+				t.Errorf("frequency = %v, want %v", p.Frequency, tt.frequency)
+			}
+			if p.Confidence < 0.9 || p.Confidence > 1 {
+				t.Errorf("confidence = %v, want between 0.9 and 1", p.Confidence)
+			}
+		})
+	}
+}
+
+func TestPitchNote(t *testing.T) {
+	tests := []struct {
+		frequency float64
+		note      string
+		cents     float64
+	}{
+		{frequency: 440, note: "a4", cents: 0},
+		{frequency: 261.6256, note: "c4", cents: 0},
+		{frequency: 440 * math.Pow(2, 0.1/12), note: "a4", cents: 10},
+		{frequency: 440 * math.Pow(2, -0.3/12), note: "a4", cents: -30},
+		{frequency: 440 * math.Pow(2, 0.7/12), note: "a#4", cents: -30},
+	}
+	for _, tt := range tests {
+		n, cents := Pitch{Frequency: tt.frequency}.Note()
+		if n != note.MustParse(tt.note) || math.Abs(cents-tt.cents) > 0.01 {
+			t.Errorf("Note(%vHz) = %v, %v cents, want %s, %v cents", tt.frequency, n, cents, tt.note, tt.cents)
+		}
+	}
+}
+
+func TestTrackPitch(t *testing.T) {
+	const sampleRate = 44100
+	// 220Hz during the first half second, then 330Hz
+	src := func(x time.Duration) float64 {
+		if x < 500*time.Millisecond {
+			return math.Sin(2 * math.Pi * 220 * x.Seconds())
+		}
+		return math.Sin(2 * math.Pi * 330 * x.Seconds())
+	}
+	points := TrackPitch(src, sampleRate, time.Second, 50*time.Millisecond, 100*time.Millisecond, PitchConfig{})
+	if len(points) != 10 {
+		t.Fatalf("%d points, want 10", len(points))
+	}
+	for i, p := range points {
+		want := 220.0
+		if p.Time >= 500*time.Millisecond {
+			want = 330
+		}
+		if p.Time != time.Duration(i)*100*time.Millisecond || math.Abs(p.Frequency-want) > 0.5 {
+			t.Errorf("point %d = %vHz at %s, want %vHz at %s", i, p.Frequency, p.Time, want, time.Duration(i)*100*time.Millisecond)
+		}
+	}
+	if points := TrackPitch(wave.Const(0), sampleRate, time.Second, 50*time.Millisecond, 100*time.Millisecond, PitchConfig{}); len(points) != 0 {
+		t.Errorf("%d points in silence, want 0", len(points))
+	}
+}
@@ -0,0 +1,112 @@
+// Package analyze extracts information from rendered frames (spectrum, pitch, onsets, levels).
+package analyze
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Window weights the frames of an analysis (i between 0 and n-1),
+// to reduce the spectral leakage caused by cutting the signal at the edges of the analyzed frames.
+type Window func(i, n int) float64
+
+// Rectangular doesn't weight the frames: the best frequency resolution, but the most leakage.
+func Rectangular(i, n int) float64 { return 1 }
+
+// Hann is a good default for music.
+func Hann(i, n int) float64 { return 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)) }
+
+// Hamming leaks less than Hann next to a peak, but more far away from it.
+func Hamming(i, n int) float64 { return 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(n)) }
+
+// Blackman leaks the least, at the cost of wider peaks.
+func Blackman(i, n int) float64 {
+	x := 2 * math.Pi * float64(i) / float64(n)
+	return 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+}
+
+// Bin is a frequency band of a spectrum.
+type Bin struct {
+	// Frequency is the center of the band, in Hz.
+	Frequency float64
+	// Magnitude is the amplitude of the band (1 for a full-scale sine at the center of the band).
+	Magnitude float64
+	// Phase is in radians (between -π and π), relative to the first analyzed frame.
+	Phase float64
+}
+
+// Spectrum returns the frequency content of the frames (with a Hann window),
+// from 0Hz to half the sample rate.
+// Frames are padded with silence to a power of 2, there are len/2+1 bins of sampleRate/len Hz.
+func Spectrum(frames []float64, sampleRate int) []Bin {
+	return SpectrumWindow(frames, sampleRate, Hann)
+}
+
+// SpectrumWindow is like Spectrum, with another window.
+func SpectrumWindow(frames []float64, sampleRate int, window Window) []Bin {
+	n := 1
+	for n < len(frames) {
+		n <<= 1
+	}
+	x := make([]complex128, n)
+	gain := 0.0 // sum of the window weights, to correct the magnitudes
+	for i, v := range frames {
+		w := window(i, len(frames))
+		x[i] = complex(v*w, 0)
+		gain += w
+	}
+	if gain == 0 {
+		gain = 1
+	}
+	FFT(x)
+
+	bins := make([]Bin, n/2+1)
+	for k := range bins {
+		magnitude := cmplx.Abs(x[k]) / gain
+		if k != 0 && k != n/2 {
+			magnitude *= 2 // the energy is shared with the negative frequency
+		}
+		bins[k] = Bin{
+			Frequency: float64(k) * float64(sampleRate) / float64(n),
+			Magnitude: magnitude,
+			Phase:     cmplx.Phase(x[k]),
+		}
+	}
+	return bins
+}
+
+// FFT computes the discrete Fourier transform in place (iterative radix-2: the length must be a power of 2).
+func FFT(x []complex128) { transform(x, -1) }
+
+// IFFT computes the inverse discrete Fourier transform in place (the length must be a power of 2).
+func IFFT(x []complex128) {
+	transform(x, 1)
+	for i := range x {
+		x[i] /= complex(float64(len(x)), 0)
+	}
+}
+
+func transform(x []complex128, sign float64) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, sign*2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package analyze
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// sine returns n frames of a sine wave.
+func sine(frequency, amplitude float64, sampleRate, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = amplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate))
+	}
+	return out
+}
+
+func TestFFT(t *testing.T) {
+	const n = 8
+	cosine := make([]complex128, n)
+	for i := range cosine {
+		cosine[i] = complex(math.Cos(2*math.Pi*2*float64(i)/n), 0)
+	}
+	tests := []struct {
+		name string
+		in   []complex128
+		want []complex128
+	}{
+		{name: "impulse", in: []complex128{1, 0, 0, 0, 0, 0, 0, 0}, want: []complex128{1, 1, 1, 1, 1, 1, 1, 1}},
+		{name: "DC", in: []complex128{1, 1, 1, 1, 1, 1, 1, 1}, want: []complex128{8, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "cosine at bin 2", in: cosine, want: []complex128{0, 0, 4, 0, 0, 0, 4, 0}},
+		{name: "alternating", in: []complex128{1, -1, 1, -1}, want: []complex128{0, 0, 4, 0}},
+		{name: "ramp", in: []complex128{0, 1, 2, 3}, want: []complex128{6, -2 + 2i, -2, -2 - 2i}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := append([]complex128(nil), tt.in...)
+			FFT(x)
+			for k := range x {
+				if cmplx.Abs(x[k]-tt.want[k]) > 1e-9 {
+					t.Errorf("bin %d = %v, want %v", k, x[k], tt.want[k])
+				}
+			}
+			IFFT(x)
+			for i := range x {
+				if cmplx.Abs(x[i]-tt.in[i]) > 1e-9 {
+					t.Errorf("inverse %d = %v, want %v", i, x[i], tt.in[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSpectrum(t *testing.T) {
+	const sampleRate = 8000
+	tests := []struct {
+		name      string
+		window    Window
+		frequency float64 // at the center of bin 64 (1024 frames)
+		amplitude float64
+	}{
+		{name: "rectangular", window: Rectangular, frequency: 500, amplitude: 1},
+		{name: "hann", window: Hann, frequency: 500, amplitude: 0.5},
+		{name: "hamming", window: Hamming, frequency: 500, amplitude: 0.25},
+		{name: "blackman", window: Blackman, frequency: 500, amplitude: 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bins := SpectrumWindow(sine(tt.frequency, tt.amplitude, sampleRate, 1024), sampleRate, tt.window)
+			if len(bins) != 513 {
+				t.Fatalf("%d bins, want 513", len(bins))
+			}
+			peak := 0
+			for k := range bins {
+				if bins[k].Magnitude > bins[peak].Magnitude {
+					peak = k
+				}
+			}
+			if peak != 64 || bins[peak].Frequency != tt.frequency {
+				t.Errorf("peak at bin %d (%vHz), want 64 (%vHz)", peak, bins[peak].Frequency, tt.frequency)
+			}
+			if got := bins[peak].Magnitude; math.Abs(got-tt.amplitude) > 1e-6 {
+				t.Errorf("peak magnitude = %v, want %v", got, tt.amplitude)
+			}
+			if got := bins[peak].Phase; math.Abs(got+math.Pi/2) > 1e-6 {
+				t.Errorf("peak phase = %v, want -π/2 (a sine)", got)
+			}
+		})
+	}
+}
+
+func TestSpectrumPadding(t *testing.T) {
+	bins := Spectrum(make([]float64, 1000), 44100)
+	if len(bins) != 513 {
+		t.Errorf("%d bins for 1000 frames, want 513 (padded to 1024)", len(bins))
+	}
+	for _, b := range bins {
+		if b.Magnitude != 0 {
+			t.Fatalf("silence has a magnitude of %v at %vHz", b.Magnitude, b.Frequency)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Default number of frames rendered per block written to aplay (about 23ms at 44100Hz),
+// and default number of blocks buffered by aplay.
+const (
+	alsaFramesPerBlock = 1024
+	alsaBufferBlocks   = 4
+)
+
+// AlsaPlayer plays audio directly on an ALSA device (Linux) by streaming PCM to aplay,
+// without cgo nor the SDL window and dependencies of ffplay (for example: on a headless Raspberry Pi).
+// Frames are rendered in real time, aplay blocks while its buffer is full.
+type AlsaPlayer struct {
+	*realtimeRenderer
+}
+
+var _ Player = (*AlsaPlayer)(nil)
+
+func NewAlsaPlayer(config PlayerConfig) (*AlsaPlayer, error) {
+	_, err := exec.LookPath("aplay")
+	if err != nil {
+		return nil, fmt.Errorf("aplay executable lookup (provided by alsa-utils): %w", err)
+	}
+	if err := config.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &AlsaPlayer{realtimeRenderer: newRealtimeRenderer(config)}, nil
+}
+
+func (p *AlsaPlayer) PlayContext(ctx context.Context) error { return playContext(ctx, p) }
+
+func (p *AlsaPlayer) Play() error {
+	reportProgress(p, p.config)
+	return p.record(p.play)
+}
+
+func (p *AlsaPlayer) play() error {
+	cmd := exec.Command("aplay", "-q",
+		"-t", "raw",
+		"-f", "FLOAT64_LE",
+		"-r", strconv.Itoa(p.config.SampleRate),
+		"-c", strconv.Itoa(p.config.Channels),
+	)
+	block := p.config.blockSize(alsaFramesPerBlock)
+	cmd.Args = append(cmd.Args,
+		"--period-size", strconv.Itoa(block),
+		"--buffer-size", strconv.Itoa(block*p.config.bufferBlocks(alsaBufferBlocks)),
+	)
+	if p.config.Device != "" {
+		cmd.Args = append(cmd.Args, "-D", p.config.Device)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("get aplay stdin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start aplay: %w", err)
+	}
+	go func() {
+		<-p.Done()
+		if p.isStopped() {
+			_ = cmd.Process.Kill()
+		}
+	}()
+
+	w := bufio.NewWriter(stdin)
+	frames := make([]float64, block*p.config.Channels)
+	encoded := []byte{}
+	for p.render(frames) {
+		encoded = EncodePCM(encoded[:0], frames)
+		if _, err := w.Write(encoded); err != nil {
+			break // aplay was killed (stopped) or failed, see its exit status
+		}
+	}
+	if err := w.Flush(); err == nil {
+		_ = stdin.Close()
+	}
+	if err := cmd.Wait(); err != nil && !p.isStopped() {
+		return fmt.Errorf("play PCM using aplay: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// CaptureConfig describes the input device of a capture.
+type CaptureConfig struct {
+	// SampleRate defaults to 44100.
+	SampleRate int
+	// Format is the input format of ffmpeg, it defaults to the audio system of the platform:
+	// "alsa" on Linux, "avfoundation" on macOS and "dshow" on Windows.
+	Format string
+	// Device is the input device, in the syntax of the format: "default" or "hw:1,0" for ALSA (default: "default"),
+	// ":0" for AVFoundation (default: ":0"), "audio=Microphone (USB Audio)" for DirectShow (required).
+	Device string
+	// Buffer is how much captured audio is kept available to waves (default: 10s).
+	Buffer time.Duration
+}
+
+// Capture records live audio input (a microphone, a guitar on a line input, etc.) with ffmpeg,
+// so it can be processed by waves in real time.
+type Capture struct {
+	config CaptureConfig
+	cmd    *exec.Cmd
+
+	mu       sync.Mutex
+	buf      []float64 // ring buffer of the last captured frames
+	captured int       // total number of captured frames
+	stopped  bool
+	err      error
+	done     chan struct{}
+}
+
+// StartCapture starts capturing the input device (mono).
+func StartCapture(config CaptureConfig) (*Capture, error) {
+	if config.SampleRate <= 0 {
+		config.SampleRate = 44100
+	}
+	if config.Buffer <= 0 {
+		config.Buffer = 10 * time.Second
+	}
+	if config.Format == "" {
+		switch runtime.GOOS {
+		case "linux":
+			config.Format = "alsa"
+		case "darwin":
+			config.Format = "avfoundation"
+		case "windows":
+			config.Format = "dshow"
+		default:
+			return nil, fmt.Errorf("no default input format on %s", runtime.GOOS)
+		}
+	}
+	if config.Device == "" {
+		switch config.Format {
+		case "avfoundation":
+			config.Device = ":0"
+		case "dshow":
+			return nil, errors.New("no input device was provided")
+		default:
+			config.Device = "default"
+		}
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("ffmpeg executable lookup: %w", err)
+	}
+
+	c := &Capture{
+		config: config,
+		buf:    make([]float64, int(config.Buffer.Seconds()*float64(config.SampleRate))+1),
+		done:   make(chan struct{}),
+	}
+	c.cmd = exec.Command("ffmpeg", "-loglevel", "error",
+		"-f", config.Format, "-i", config.Device,
+		"-ac", "1", "-ar", strconv.Itoa(config.SampleRate), "-f", "f64le", "pipe:1",
+	)
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("get ffmpeg stdout: %w", err)
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ffmpeg: %w", err)
+	}
+	go c.read(stdout)
+	return c, nil
+}
+
+// read stores the captured frames until ffmpeg exits.
+func (c *Capture) read(r io.Reader) {
+	encoded, pending := make([]byte, 8*256), 0
+	for {
+		n, err := r.Read(encoded[pending:])
+		n += pending
+		whole := n - n%8 // a frame split between two reads is completed by the next one
+		c.mu.Lock()
+		for i := 0; i < whole; i += 8 {
+			c.buf[c.captured%len(c.buf)] = math.Float64frombits(binary.LittleEndian.Uint64(encoded[i:]))
+			c.captured++
+		}
+		c.mu.Unlock()
+		pending = copy(encoded, encoded[whole:n])
+		if err != nil {
+			break
+		}
+	}
+	err := c.cmd.Wait()
+	c.mu.Lock()
+	if err != nil && !c.stopped {
+		c.err = fmt.Errorf("capture with ffmpeg: %w", err)
+	}
+	close(c.done)
+	c.mu.Unlock()
+}
+
+// Wave returns a wave producing the captured audio.
+//
+// The render loop of a player renders its frames a bit before they are played,
+// so when it is first evaluated, the wave is aligned with the audio captured latency ago
+// (latency should be at least the buffering of the player, 100ms by default for ffplay).
+// Frames that haven't been captured yet (or are no longer buffered) are silent.
+// The wave is stateful (see wave.Wave).
+func (c *Capture) Wave(latency time.Duration) wave.Wave {
+	start, anchor := time.Duration(-1), 0
+	rate := int64(c.config.SampleRate)
+	return func(x time.Duration) float64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if start < 0 {
+			start, anchor = x, c.captured-int(int64(latency)*rate/int64(time.Second))
+		}
+		i := anchor + int(int64(x-start)*rate/int64(time.Second))
+		if i < 0 || i >= c.captured || i < c.captured-len(c.buf) {
+			return 0
+		}
+		return c.buf[i%len(c.buf)]
+	}
+}
+
+// Captured returns how much audio has been captured since the capture started.
+func (c *Capture) Captured() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return frameTime(c.captured, c.config.SampleRate)
+}
+
+// Stop stops capturing.
+func (c *Capture) Stop() error {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	if err := c.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("kill ffmpeg: %w", err)
+	}
+	<-c.done
+	return nil
+}
+
+// Done is closed once capturing has stopped (see Err).
+func (c *Capture) Done() <-chan struct{} { return c.done }
+
+// Err returns the error of ffmpeg, if capturing stopped unexpectedly.
+func (c *Capture) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
@@ -0,0 +1,50 @@
+package audio
+
+import (
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// CueSource is a source of a CueMix with its level in the main mix and in the cue mix.
+// A nil level excludes the source from the mix
+// (for example: a talkback microphone or a click track only belong in the cue mix).
+type CueSource struct {
+	Wave wave.Wave
+	Main wave.Wave
+	Cue  wave.Wave
+}
+
+// CueMix builds two mixes of the same sources with independent levels:
+// the main mix (for the audience or the recording) and the cue mix (for the performer's headphones).
+type CueMix []CueSource
+
+// Main returns the main mix (sum of the sources at their main level).
+func (m CueMix) Main() wave.Wave {
+	return m.mix(func(s CueSource) wave.Wave { return s.Main })
+}
+
+// Cue returns the cue mix (sum of the sources at their cue level).
+func (m CueMix) Cue() wave.Wave {
+	return m.mix(func(s CueSource) wave.Wave { return s.Cue })
+}
+
+// Routes returns the routes sending each mix to its own output channels.
+func (m CueMix) Routes(mainChannels, cueChannels []int) []Route {
+	return []Route{
+		{Wave: m.Main(), Channels: mainChannels},
+		{Wave: m.Cue(), Channels: cueChannels},
+	}
+}
+
+func (m CueMix) mix(level func(CueSource) wave.Wave) wave.Wave {
+	return func(x time.Duration) float64 {
+		sum := 0.0
+		for _, s := range m {
+			if l := level(s); l != nil {
+				sum += s.Wave(x) * l(x)
+			}
+		}
+		return sum
+	}
+}
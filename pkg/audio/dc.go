@@ -0,0 +1,19 @@
+package audio
+
+// RemoveDC subtracts the mean value of the frames (in place) and returns it.
+// Unlike wave.DCBlock, it doesn't alter low frequencies,
+// but it only removes a constant offset (for example: the offset of an imported sample).
+func RemoveDC(frames []float64) float64 {
+	if len(frames) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range frames {
+		mean += v
+	}
+	mean /= float64(len(frames))
+	for i := range frames {
+		frames[i] -= mean
+	}
+	return mean
+}
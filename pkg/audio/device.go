@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OutputDevice is an audio output device.
+type OutputDevice struct {
+	// Name identifies the device in the player config (for example: "plughw:1,0").
+	Name        string
+	Description string
+}
+
+// OutputDevices lists the ALSA playback devices (Linux), as listed in /proc/asound/pcm.
+// Their names can be used as the Device of a player config
+// ("plughw" devices convert the sample format and rate when needed).
+func OutputDevices() ([]OutputDevice, error) {
+	f, err := os.Open("/proc/asound/pcm")
+	if err != nil {
+		return nil, fmt.Errorf("open ALSA devices list: %w", err)
+	}
+	defer f.Close()
+
+	// Lines look like: "01-00: USB Audio : USB Audio : playback 1 : capture 1"
+	devices := []OutputDevice{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || !strings.Contains(scanner.Text(), "playback") {
+			continue
+		}
+		ids := strings.SplitN(strings.TrimSpace(fields[0]), "-", 2)
+		if len(ids) != 2 {
+			continue
+		}
+		card, err1 := strconv.Atoi(ids[0])
+		device, err2 := strconv.Atoi(ids[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		devices = append(devices, OutputDevice{
+			Name:        fmt.Sprintf("plughw:%d,%d", card, device),
+			Description: strings.TrimSpace(fields[1]),
+		})
+	}
+	return devices, scanner.Err()
+}
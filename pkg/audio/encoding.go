@@ -1,12 +1,13 @@
 package audio
 
 import (
-	"encoding/binary"
 	"errors"
 	"io"
-	"math"
 )
 
+// Number of frames encoded at once by WritePCM (so long renders aren't encoded in memory all at once).
+const pcmChunkFrames = 4096
+
 // WritePCM encodes a sound's PCM representation to an io.Writer
 func WritePCM(w io.Writer, frames []float64) error {
 	if len(frames) == 0 {
@@ -16,15 +17,16 @@ func WritePCM(w io.Writer, frames []float64) error {
 		return errors.New("no io.Writer was provided")
 	}
 
-	// encode each pulse to writer
-	for _, pulse := range frames {
-		var buf [8]byte
-		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(pulse))
-		_, err := w.Write(buf[:])
-		if err != nil {
+	buf := make([]byte, 0, 8*pcmChunkFrames)
+	for len(frames) > 0 {
+		n := pcmChunkFrames
+		if n > len(frames) {
+			n = len(frames)
+		}
+		if _, err := w.Write(EncodePCM(buf[:0], frames[:n])); err != nil {
 			return err
 		}
+		frames = frames[n:]
 	}
-
 	return nil
 }
@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ffplayFilePlayer renders the whole duration to a temporary .pcm file, then plays it with ffplay.
+//
+// Pause and Resume suspend and resume the ffplay process (on Unix systems only),
+// Seek restarts ffplay at the new position.
+type ffplayFilePlayer struct {
+	config PlayerConfig
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	paused  bool
+	stopped bool
+	seeking bool
+	seekTo  time.Duration
+	done    chan struct{}
+	closed  bool
+	// The play position is estimated with the wall clock:
+	// base is the position when ffplay was (re)started or paused, since is when it started playing from base.
+	base  time.Duration
+	since time.Time
+}
+
+func (p *ffplayFilePlayer) Play() error {
+	defer p.finish()
+
+	// get output frames
+	frames := RoutedFrames(p.config.routes(), p.config.Channels, p.config.SampleRate, 0, p.config.Duration)
+
+	// Create tmp file
+	f, err := os.CreateTemp(os.TempDir(), "audio_*.pcm")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Encode PCM output to file
+	err = WritePCM(f, frames)
+	if err != nil {
+		return fmt.Errorf("encode PCM pulses: %w", err)
+	}
+
+	// Read output file with ffplay (by launching ffplay from the CLI),
+	// ffplay is started again from the new position after each seek.
+	start := time.Duration(0)
+	for {
+		args := strings.Split(newFFPlayCommand(p.config.SampleRate, p.config.Channels, f.Name(), true), " ")
+		args = args[:len(args)-1]
+		if start > 0 {
+			args = append(args, "-ss", strconv.FormatFloat(start.Seconds(), 'f', -1, 64))
+		}
+		if loops := p.config.Loop; loops < 0 || loops > 1 {
+			if loops < 0 {
+				loops = 0 // ffplay loops forever
+			}
+			args = append(args, "-loop", strconv.Itoa(loops))
+		}
+		args = append(args, f.Name())
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = ffplayEnv(p.config)
+
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			return nil
+		}
+		if err := cmd.Start(); err != nil {
+			p.mu.Unlock()
+			return fmt.Errorf("start ffplay: %w", err)
+		}
+		p.cmd, p.seeking = cmd, false
+		p.base, p.since = start, time.Now()
+		if p.paused {
+			_ = suspendProcess(cmd.Process)
+			p.since = time.Time{}
+		}
+		p.mu.Unlock()
+
+		err := cmd.Wait()
+
+		p.mu.Lock()
+		p.cmd = nil
+		p.base, p.since = p.position(), time.Time{}
+		stopped, seeking, seekTo := p.stopped, p.seeking, p.seekTo
+		p.mu.Unlock()
+		switch {
+		case stopped:
+			return nil
+		case seeking:
+			start = seekTo
+		case err != nil:
+			return fmt.Errorf("play PCM file using ffplay: %w", err)
+		default:
+			p.mu.Lock()
+			p.base = p.config.Duration
+			p.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+func (p *ffplayFilePlayer) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+	if p.cmd != nil {
+		_ = suspendProcess(p.cmd.Process)
+		p.base, p.since = p.position(), time.Time{}
+	}
+}
+
+func (p *ffplayFilePlayer) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = false
+	if p.cmd != nil {
+		_ = resumeProcess(p.cmd.Process)
+		if p.since.IsZero() {
+			p.since = time.Now()
+		}
+	}
+}
+
+func (p *ffplayFilePlayer) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	if p.cmd != nil {
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+// Seek restarts playback from the position (clamped between 0 and the duration).
+func (p *ffplayFilePlayer) Seek(position time.Duration) {
+	if position < 0 {
+		position = 0
+	} else if position > p.config.Duration {
+		position = p.config.Duration
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seeking, p.seekTo = true, position
+	if p.cmd != nil {
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+func (p *ffplayFilePlayer) Done() <-chan struct{} { return p.done }
+
+// Position returns the play position, estimated from the time elapsed since ffplay was started.
+func (p *ffplayFilePlayer) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+// position must be called with the mutex locked.
+// When looping, it is the position in the current repetition.
+func (p *ffplayFilePlayer) position() time.Duration {
+	position := p.base
+	if !p.since.IsZero() {
+		position += time.Since(p.since)
+	}
+	if loops := p.config.Loop; position > p.config.Duration && (loops < 0 || position < time.Duration(loops)*p.config.Duration) {
+		position %= p.config.Duration
+	}
+	if position > p.config.Duration {
+		position = p.config.Duration
+	}
+	return position
+}
+
+func (p *ffplayFilePlayer) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+}
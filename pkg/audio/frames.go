@@ -1,17 +1,74 @@
 package audio
 
 import (
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/ejuju/ziq/pkg/wave"
 )
 
 func Frames(src wave.Wave, framesPerSec int, start, end time.Duration) []float64 {
-	frames := []float64{}
 	step := float64(time.Second) / float64(framesPerSec) // step == time per frame
+	frames := make([]float64, 0, int(float64(end)/step)+1)
 	for i := float64(start); i < float64(start+end); i += step {
 		val := src(time.Duration(i))
 		frames = append(frames, val)
 	}
 	return frames
 }
+
+// FrameRange renders count frames, starting at the frame with the index first.
+// Frame positions are computed from their index (instead of being accumulated),
+// so consecutive ranges join seamlessly (for example when rendering chunk by chunk).
+func FrameRange(src wave.Wave, framesPerSec int, first, count int) []float64 {
+	frames := make([]float64, count)
+	RenderFrames(frames, src, framesPerSec, first)
+	return frames
+}
+
+// RenderFrames is like FrameRange, but renders into the provided buffer (len(dst) frames)
+// instead of allocating a new one, for example to reuse a buffer from GetBuffer.
+func RenderFrames(dst []float64, src wave.Wave, framesPerSec int, first int) {
+	for i := range dst {
+		dst[i] = src(frameTime(first+i, framesPerSec))
+	}
+}
+
+// ParallelFrameRange is like FrameRange, but splits the frames across several goroutines
+// (one per CPU when workers isn't positive) and stitches their results.
+// It should only be used with stateless waves: waves keeping state between evaluations
+// (for example: filters, smoothed parameters or a transport) would be evaluated out of order and concurrently.
+// A single worker renders sequentially.
+func ParallelFrameRange(src wave.Wave, framesPerSec int, first, count, workers int) []float64 {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers == 1 || count < workers {
+		return FrameRange(src, framesPerSec, first, count)
+	}
+
+	frames := make([]float64, count)
+	size := (count + workers - 1) / workers
+	wg := sync.WaitGroup{}
+	for start := 0; start < count; start += size {
+		end := start + size
+		if end > count {
+			end = count
+		}
+		wg.Add(1)
+		go func(part []float64, offset int) {
+			defer wg.Done()
+			for i := range part {
+				part[i] = src(frameTime(first+offset+i, framesPerSec))
+			}
+		}(frames[start:end], start)
+	}
+	wg.Wait()
+	return frames
+}
+
+// frameTime returns the position of the frame with the provided index.
+func frameTime(index, framesPerSec int) time.Duration {
+	return time.Duration(int64(index) * int64(time.Second) / int64(framesPerSec))
+}
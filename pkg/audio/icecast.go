@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// IcecastConfig describes the mount point of an Icecast server (version 2.4 or later, with HTTP PUT sources).
+type IcecastConfig struct {
+	// URL is the address of the mount point (for example: "http://radio.example.com:8000/ziq.ogg").
+	URL string
+	// User defaults to "source".
+	User     string
+	Password string
+	// Name, Description and Genre are shown in the stream directory of the server.
+	Name, Description, Genre string
+	// Public lists the stream in public directories (like dir.xiph.org).
+	Public bool
+}
+
+// StreamIcecast renders the wave in real time and sends it to an Icecast server as a source (encoded as OGG/Vorbis with ffmpeg),
+// until the context is cancelled or the connection is lost, for example to run a 24/7 generative net-radio.
+func StreamIcecast(ctx context.Context, src wave.Wave, sampleRate int, config IcecastConfig) error {
+	if config.URL == "" {
+		return fmt.Errorf("no icecast URL was provided")
+	}
+	if config.User == "" {
+		config.User = "source"
+	}
+	s, err := NewStreamServer(src, sampleRate, StreamOgg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	l, _ := s.listen() // the server isn't running yet
+	go s.Run(ctx)
+
+	pr, pw := io.Pipe()
+	go func() {
+		<-ctx.Done()
+		pr.Close() // unblocks the encoder if the request is gone
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		err := s.serveOgg(ctx, pw, l)
+		pw.CloseWithError(io.EOF)
+		errc <- err
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, config.URL, pr)
+	if err != nil {
+		return fmt.Errorf("create icecast request: %w", err)
+	}
+	req.SetBasicAuth(config.User, config.Password)
+	req.Header.Set("Content-Type", "audio/ogg")
+	req.Header.Set("Ice-Name", config.Name)
+	req.Header.Set("Ice-Description", config.Description)
+	req.Header.Set("Ice-Genre", config.Genre)
+	req.Header.Set("Ice-Audio-Info", fmt.Sprintf("samplerate=%d;channels=1", sampleRate))
+	if config.Public {
+		req.Header.Set("Ice-Public", "1")
+	} else {
+		req.Header.Set("Ice-Public", "0")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("connect to icecast server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("icecast server refused the source: %s", resp.Status)
+	}
+
+	// The server answers as soon as the source is accepted, then keeps receiving the stream
+	err = <-errc
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("encode stream: %w", err)
+	}
+	return fmt.Errorf("connection to icecast server lost")
+}
@@ -0,0 +1,52 @@
+package audio
+
+// Hot loops of rendering: mixing, scaling and PCM encoding.
+// Loops are unrolled by 4 to reduce bounds checks and loop overhead,
+// and PCM encoding is a plain memory copy on little-endian architectures (see pcm_le.go).
+
+// Mix adds the frames of the sources to dst (frames beyond the length of dst are ignored).
+func Mix(dst []float64, srcs ...[]float64) {
+	for _, src := range srcs {
+		if len(src) > len(dst) {
+			src = src[:len(dst)]
+		}
+		d := dst[:len(src)]
+		i := 0
+		for ; i+4 <= len(src); i += 4 {
+			d[i] += src[i]
+			d[i+1] += src[i+1]
+			d[i+2] += src[i+2]
+			d[i+3] += src[i+3]
+		}
+		for ; i < len(src); i++ {
+			d[i] += src[i]
+		}
+	}
+}
+
+// Scale multiplies the frames by the gain (in place).
+func Scale(buf []float64, gain float64) {
+	i := 0
+	for ; i+4 <= len(buf); i += 4 {
+		buf[i] *= gain
+		buf[i+1] *= gain
+		buf[i+2] *= gain
+		buf[i+3] *= gain
+	}
+	for ; i < len(buf); i++ {
+		buf[i] *= gain
+	}
+}
+
+// EncodePCM appends the frames encoded as PCM (f64le) to dst and returns the extended buffer.
+func EncodePCM(dst []byte, frames []float64) []byte {
+	start := len(dst)
+	if n := start + 8*len(frames); cap(dst) < n {
+		grown := make([]byte, start, n)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:start+8*len(frames)]
+	encodePCM(dst[start:], frames)
+	return dst
+}
@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMix(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  []float64
+		srcs [][]float64
+		want []float64
+	}{
+		{name: "no sources", dst: []float64{1, 2}, want: []float64{1, 2}},
+		{
+			name: "unrolled and remaining frames",
+			dst:  []float64{1, 1, 1, 1, 1, 1},
+			srcs: [][]float64{{1, 2, 3, 4, 5, 6}, {0.5, 0.5, 0.5, 0.5, 0.5, 0.5}},
+			want: []float64{2.5, 3.5, 4.5, 5.5, 6.5, 7.5},
+		},
+		{name: "shorter source", dst: []float64{0, 0, 0}, srcs: [][]float64{{1}}, want: []float64{1, 0, 0}},
+		{name: "longer source", dst: []float64{0, 0}, srcs: [][]float64{{1, 2, 3, 4, 5}}, want: []float64{1, 2}},
+	}
+	for _, tt := range tests {
+		Mix(tt.dst, tt.srcs...)
+		if !reflect.DeepEqual(tt.dst, tt.want) {
+			t.Errorf("%s: Mix = %v, want %v", tt.name, tt.dst, tt.want)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		buf  []float64
+		gain float64
+		want []float64
+	}{
+		{buf: []float64{}, gain: 2, want: []float64{}},
+		{buf: []float64{1, -2, 3, -4, 5}, gain: 0.5, want: []float64{0.5, -1, 1.5, -2, 2.5}},
+		{buf: []float64{1, 2, 3}, gain: 0, want: []float64{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		Scale(tt.buf, tt.gain)
+		if !reflect.DeepEqual(tt.buf, tt.want) {
+			t.Errorf("Scale = %v, want %v", tt.buf, tt.want)
+		}
+	}
+}
+
+// f64le encodes frames with the standard library, as a reference.
+func f64le(frames []float64) []byte {
+	out := make([]byte, 8*len(frames))
+	for i, f := range frames {
+		binary.LittleEndian.PutUint64(out[8*i:], math.Float64bits(f))
+	}
+	return out
+}
+
+func TestEncodePCM(t *testing.T) {
+	frames := []float64{0, 1, -1, 0.5, math.Pi, -1e-300}
+	tests := []struct {
+		name string
+		dst  []byte
+	}{
+		{name: "nil buffer"},
+		{name: "appended", dst: []byte{1, 2, 3}},
+		{name: "large enough buffer", dst: make([]byte, 2, 1024)},
+	}
+	for _, tt := range tests {
+		prefix := append([]byte(nil), tt.dst...)
+		got := EncodePCM(tt.dst, frames)
+		if want := append(prefix, f64le(frames)...); !bytes.Equal(got, want) {
+			t.Errorf("%s: EncodePCM = % x, want % x", tt.name, got, want)
+		}
+	}
+}
+
+func TestWritePCM(t *testing.T) {
+	for _, n := range []int{1, pcmChunkFrames, 2*pcmChunkFrames + 3} {
+		frames := make([]float64, n)
+		for i := range frames {
+			frames[i] = math.Sin(float64(i))
+		}
+		buf := &bytes.Buffer{}
+		if err := WritePCM(buf, frames); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), f64le(frames)) {
+			t.Errorf("%d frames: encoded frames differ", n)
+		}
+	}
+	if err := WritePCM(&bytes.Buffer{}, nil); err == nil {
+		t.Error("no error without frames")
+	}
+}
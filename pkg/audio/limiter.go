@@ -0,0 +1,204 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// Oversampling of true-peak detection (as recommended by ITU-R BS.1770),
+// and number of taps of the interpolation filter (per phase).
+const (
+	truePeakOversampling = 4
+	truePeakTaps         = 12
+)
+
+// LimiterConfig describes how a Limiter reduces peaks.
+type LimiterConfig struct {
+	// Ceiling is the highest true-peak level of the output (default: -1 dBTP, about 0.89),
+	// it is linear like the target of Normalize.
+	// Streaming platforms usually ask for -1 dBTP, so lossy encoding doesn't clip.
+	Ceiling float64
+	// Lookahead is how early the gain starts decreasing before a peak (default: 5ms),
+	// shorter values are more transparent on transients but distort low frequencies more.
+	Lookahead time.Duration
+	// Release is how long the gain takes to (mostly) recover after a peak (default: 100ms).
+	Release time.Duration
+}
+
+func (c *LimiterConfig) setDefaults() {
+	if c.Ceiling <= 0 {
+		c.Ceiling = math.Pow(10, -1.0/20)
+	}
+	if c.Lookahead <= 0 {
+		c.Lookahead = 5 * time.Millisecond
+	}
+	if c.Release <= 0 {
+		c.Release = 100 * time.Millisecond
+	}
+}
+
+// Limiter keeps the true peaks of interleaved frames under a ceiling,
+// as the last stage of a render (so exported files meet the specifications of streaming platforms).
+//
+// True peaks are the peaks of the analog signal rebuilt from the frames (by a converter or a decoder),
+// they can be higher than the highest sample, so they are measured on frames oversampled 4 times.
+// The gain is the same for all channels (so the stereo image doesn't move),
+// it smoothly decreases ahead of peaks (the output is delayed) and recovers after them.
+type Limiter struct {
+	channels int
+	ceiling  float64
+	release  float64 // gain recovered at each frame (part of the remaining reduction)
+	length   int     // lookahead in frames
+
+	history [][]float64 // last input frames of each channel (for interpolation)
+	filter  [truePeakOversampling][truePeakTaps]float64
+
+	delay      []float64 // delayed input frames (interleaved ring)
+	delayIndex int       // next frame of the delay ring
+	skip       int       // number of output frames still to be skipped (the initial delay)
+
+	minimums []limiterGain // sliding minimum of the required gains (increasing gains)
+	released float64       // required gain with release
+	gains    []float64     // ring of the released gains (averaged)
+	gainSum  float64
+	frame    int // index of the next input frame
+}
+
+type limiterGain struct {
+	frame int
+	gain  float64
+}
+
+// NewLimiter creates a limiter for frames with the given number of channels.
+func NewLimiter(sampleRate, channels int, config LimiterConfig) *Limiter {
+	config.setDefaults()
+	if channels <= 0 {
+		channels = 1
+	}
+	l := &Limiter{
+		channels: channels,
+		ceiling:  config.Ceiling,
+		release:  1 - math.Exp(-4.6/(config.Release.Seconds()*float64(sampleRate))),
+		length:   int(math.Max(1, math.Round(config.Lookahead.Seconds()*float64(sampleRate)))),
+		released: 1,
+	}
+	for c := 0; c < channels; c++ {
+		l.history = append(l.history, make([]float64, truePeakTaps))
+	}
+
+	// Windowed sinc, each phase interpolates at a quarter frame after the previous one.
+	// The first phase is the frame at the center of the history (truePeakTaps/2 frames ago).
+	center := float64(truePeakOversampling*truePeakTaps) / 2
+	for p := range l.filter {
+		sum := 0.0
+		for j := range l.filter[p] {
+			m := float64(truePeakOversampling*j + p)
+			x := (m - center) / truePeakOversampling
+			v := 1.0
+			if x != 0 {
+				v = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			v *= 0.5 - 0.5*math.Cos(2*math.Pi*m/(2*center))
+			l.filter[p][j] = v
+			sum += v
+		}
+		for j := range l.filter[p] {
+			l.filter[p][j] /= sum
+		}
+	}
+
+	// Interpolated peaks are known truePeakTaps/2 frames late, so the input is delayed by this much more.
+	l.skip = l.length - 1 + truePeakTaps/2
+	l.delay = make([]float64, l.skip*channels)
+	l.gains = make([]float64, l.length)
+	for i := range l.gains {
+		l.gains[i] = 1
+	}
+	l.gainSum = float64(l.length)
+	return l
+}
+
+// Process limits the next frames and returns the limited frames available so far:
+// since the output is delayed (by the lookahead), the first frames are only returned by later calls,
+// and the last ones by Flush.
+func (l *Limiter) Process(frames []float64) []float64 {
+	out := make([]float64, 0, len(frames))
+	for i := 0; i+l.channels <= len(frames); i += l.channels {
+		out = l.processFrame(frames[i:i+l.channels], out)
+	}
+	return out
+}
+
+// Flush returns the limited frames still delayed, once all frames were processed.
+func (l *Limiter) Flush() []float64 {
+	silence := make([]float64, l.channels)
+	out := []float64{}
+	for n := len(l.delay) / l.channels; n > 0; n-- {
+		out = l.processFrame(silence, out)
+	}
+	return out
+}
+
+func (l *Limiter) processFrame(frame, out []float64) []float64 {
+	// True peak of the frame at the center of the history (and of the interpolated points following it)
+	peak := 0.0
+	for c, v := range frame {
+		h := l.history[c]
+		copy(h, h[1:])
+		h[len(h)-1] = v
+		for p := range l.filter {
+			sum := 0.0
+			for j, coefficient := range l.filter[p] {
+				sum += h[len(h)-1-j] * coefficient
+			}
+			peak = math.Max(peak, math.Abs(sum))
+		}
+	}
+	gain := 1.0
+	if peak > l.ceiling {
+		gain = l.ceiling / peak
+	}
+
+	// Lowest required gain of the lookahead (and the frame before it)
+	for len(l.minimums) > 0 && l.minimums[len(l.minimums)-1].gain >= gain {
+		l.minimums = l.minimums[:len(l.minimums)-1]
+	}
+	l.minimums = append(l.minimums, limiterGain{frame: l.frame, gain: gain})
+	if l.minimums[0].frame < l.frame-l.length {
+		l.minimums = l.minimums[1:]
+	}
+	l.released = math.Min(l.minimums[0].gain, l.released+(1-l.released)*l.release)
+
+	// Averaging the gains over the lookahead turns steps into ramps that reach the required gain on time
+	i := l.frame % l.length
+	l.gainSum += l.released - l.gains[i]
+	l.gains[i] = l.released
+	if i == 0 {
+		// Avoid accumulating rounding errors
+		l.gainSum = 0
+		for _, g := range l.gains {
+			l.gainSum += g
+		}
+	}
+	l.frame++
+
+	// Delayed output
+	d := l.delay[l.delayIndex*l.channels : (l.delayIndex+1)*l.channels]
+	if l.skip > 0 {
+		l.skip--
+	} else {
+		average := l.gainSum / float64(l.length)
+		for _, v := range d {
+			out = append(out, v*average)
+		}
+	}
+	copy(d, frame)
+	l.delayIndex = (l.delayIndex + 1) % (len(l.delay) / l.channels)
+	return out
+}
+
+// LimitTruePeak limits interleaved frames with a new limiter (see Limiter) and returns the limited frames.
+func LimitTruePeak(frames []float64, sampleRate, channels int, config LimiterConfig) []float64 {
+	l := NewLimiter(sampleRate, channels, config)
+	return append(l.Process(frames), l.Flush()...)
+}
@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// sineFrames returns interleaved frames of a sine wave on all channels
+// (phase is the initial phase, in radians).
+func sineFrames(frequency, amplitude, phase float64, sampleRate, channels, n int) []float64 {
+	out := make([]float64, 0, n*channels)
+	for i := 0; i < n; i++ {
+		v := amplitude * math.Sin(phase+2*math.Pi*frequency*float64(i)/float64(sampleRate))
+		for c := 0; c < channels; c++ {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// truePeak estimates the true peak of interleaved frames, oversampled 16 times with a long sinc interpolation.
+func truePeak(frames []float64, channels int) float64 {
+	const oversampling, taps = 16, 64
+	peak := 0.0
+	n := len(frames) / channels
+	for c := 0; c < channels; c++ {
+		for i := taps; i < n-taps; i++ {
+			for p := 0; p < oversampling; p++ {
+				x := float64(i) + float64(p)/oversampling
+				sum := 0.0
+				for j := i - taps; j <= i+taps; j++ {
+					d := x - float64(j)
+					v := 1.0
+					if d != 0 {
+						v = math.Sin(math.Pi*d) / (math.Pi * d)
+					}
+					sum += frames[j*channels+c] * v
+				}
+				peak = math.Max(peak, math.Abs(sum))
+			}
+		}
+	}
+	return peak
+}
+
+func TestLimiter(t *testing.T) {
+	const sampleRate = 44100
+	ceiling := math.Pow(10, -1.0/20)
+	tests := []struct {
+		name     string
+		frames   []float64
+		channels int
+		limited  bool // whether the gain should be reduced
+	}{
+		{name: "quiet sine", frames: sineFrames(440, 0.5, 0, sampleRate, 1, sampleRate/2), channels: 1},
+		{name: "loud sine", frames: sineFrames(440, 2, 0, sampleRate, 1, sampleRate/2), channels: 1, limited: true},
+		{name: "loud stereo sine", frames: sineFrames(1000, 1.5, 0, sampleRate, 2, sampleRate/2), channels: 2, limited: true},
+		// Samples at ±0.71, between peaks of 1 (a quarter of the sample rate, 45° out of phase)
+		{name: "inter-sample peaks", frames: sineFrames(sampleRate/4, 1, math.Pi/4, sampleRate, 1, sampleRate/2), channels: 1, limited: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := LimitTruePeak(tt.frames, sampleRate, tt.channels, LimiterConfig{})
+			if len(out) != len(tt.frames) {
+				t.Fatalf("%d limited frames, want %d", len(out), len(tt.frames))
+			}
+			if !tt.limited {
+				for i := range out {
+					if out[i] != tt.frames[i] {
+						t.Fatalf("frame %d = %v, want %v (unchanged)", i, out[i], tt.frames[i])
+					}
+				}
+				return
+			}
+			// The settled gain keeps the true peak just under the ceiling
+			settled := out[len(out)/2:]
+			if peak := truePeak(settled, tt.channels); peak > ceiling*1.01 || peak < ceiling*0.95 {
+				t.Errorf("true peak = %v, want about %v", peak, ceiling)
+			}
+			for i := 0; i < len(out); i += tt.channels {
+				for c := 1; c < tt.channels; c++ {
+					if out[i+c] != out[i] {
+						t.Fatalf("frame %d: channel %d = %v, channel 0 = %v (the gain should be the same)", i/tt.channels, c, out[i+c], out[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterLookahead(t *testing.T) {
+	const sampleRate = 44100
+	// Silence, then a loud click: the gain is already reduced when the click is output
+	frames := make([]float64, 1000)
+	frames[500] = 4
+	out := LimitTruePeak(frames, sampleRate, 1, LimiterConfig{Ceiling: 0.5, Lookahead: time.Millisecond})
+	if out[500] > 0.5*1.01 {
+		t.Errorf("click = %v, want at most 0.5", out[500])
+	}
+	for i, v := range out {
+		if i != 500 && v != 0 {
+			t.Errorf("frame %d = %v, want 0", i, v)
+		}
+	}
+}
+
+func TestLimiterBlocks(t *testing.T) {
+	const sampleRate = 44100
+	frames := sineFrames(300, 1.5, 0, sampleRate, 2, sampleRate/4)
+	want := LimitTruePeak(frames, sampleRate, 2, LimiterConfig{})
+
+	l := NewLimiter(sampleRate, 2, LimiterConfig{})
+	got := []float64{}
+	for i := 0; i < len(frames); i += 2 * 333 {
+		end := i + 2*333
+		if end > len(frames) {
+			end = len(frames)
+		}
+		got = append(got, l.Process(frames[i:end])...)
+	}
+	got = append(got, l.Flush()...)
+	if len(got) != len(want) {
+		t.Fatalf("%d frames processed in blocks, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d processed in blocks = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Usual loudness targets in LUFS (see NormalizeLoudness).
+const (
+	// LoudnessStreaming is the loudness music streaming platforms play tracks at.
+	LoudnessStreaming = -14.0
+	// LoudnessPodcast is the loudness recommended for podcasts and spoken word.
+	LoudnessPodcast = -16.0
+)
+
+// Peak returns the highest absolute value of the frames.
+func Peak(frames []float64) float64 {
+	peak := 0.0
+	for _, v := range frames {
+		if v = math.Abs(v); v > peak {
+			peak = v
+		}
+	}
+	return peak
+}
+
+// Normalize scales the frames (in place) so their peak reaches the target peak,
+// and returns the applied gain.
+// The target is linear: -1 dBFS is math.Pow(10, -1.0/20) (about 0.89).
+// Silent frames are left unchanged.
+func Normalize(frames []float64, targetPeak float64) float64 {
+	peak := Peak(frames)
+	if peak == 0 {
+		return 1
+	}
+	gain := targetPeak / peak
+	Scale(frames, gain)
+	return gain
+}
+
+// NormalizeWave scans the first d of the source wave (at the given frame rate)
+// and returns it scaled so its peak reaches the target peak (see Normalize).
+// Since the source is evaluated twice (once for scanning), it shouldn't hold state.
+func NormalizeWave(src wave.Wave, framesPerSec int, d time.Duration, targetPeak float64) wave.Wave {
+	peak := 0.0
+	for i := 0; ; i++ {
+		x := frameTime(i, framesPerSec)
+		if x >= d {
+			break
+		}
+		if v := math.Abs(src(x)); v > peak {
+			peak = v
+		}
+	}
+	if peak == 0 {
+		return src
+	}
+	return wave.Amplitude(src, wave.Const(targetPeak/peak))
+}
+
+// NormalizeLoudness scales interleaved frames (in place) so their integrated loudness reaches the target (in LUFS),
+// and returns the applied gain: the frames are measured first (see analyze.Loudness), then the gain is applied.
+// Unlike peak normalization, tracks normalized this way sound equally loud.
+// Raising the loudness can push peaks over 0 dBFS, a Limiter should then be the last stage.
+// Silent frames (and frames shorter than 400ms) are left unchanged.
+func NormalizeLoudness(frames []float64, sampleRate, channels int, targetLUFS float64) float64 {
+	gain := loudnessGain(analyze.Loudness(frames, sampleRate, channels), targetLUFS)
+	Scale(frames, gain)
+	return gain
+}
+
+// NormalizeLoudnessWave measures the first d of the source wave (at the given frame rate)
+// and returns it scaled so its loudness reaches the target (see NormalizeLoudness).
+// Since the source is evaluated twice (once for measuring), it shouldn't hold state.
+func NormalizeLoudnessWave(src wave.Wave, framesPerSec int, d time.Duration, targetLUFS float64) wave.Wave {
+	meter := analyze.NewMeter(framesPerSec, 1)
+	block := make([]float64, 4096)
+	total := int(int64(d) * int64(framesPerSec) / int64(time.Second))
+	for first := 0; first < total; first += len(block) {
+		if first+len(block) > total {
+			block = block[:total-first]
+		}
+		RenderFrames(block, src, framesPerSec, first)
+		meter.Write(block)
+	}
+	gain := loudnessGain(meter.Levels().LUFS, targetLUFS)
+	if gain == 1 {
+		return src
+	}
+	return wave.Amplitude(src, wave.Const(gain))
+}
+
+func loudnessGain(loudness, target float64) float64 {
+	if math.IsInf(loudness, -1) {
+		return 1
+	}
+	return math.Pow(10, (target-loudness)/20)
+}
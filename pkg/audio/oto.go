@@ -0,0 +1,123 @@
+//go:build oto
+
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ebitengine/oto/v3"
+)
+
+// OtoPlayer plays audio with oto, a pure Go audio library, so ffplay doesn't need to be installed.
+// Frames are rendered in real time, just before they are played.
+//
+// It is only available when building with the "oto" build tag
+// (cgo and the ALSA development files, like libasound2-dev, are needed on Linux).
+type OtoPlayer struct {
+	*realtimeRenderer
+}
+
+var _ Player = (*OtoPlayer)(nil)
+
+func NewOtoPlayer(config PlayerConfig) (*OtoPlayer, error) {
+	if err := config.setDefaults(); err != nil {
+		return nil, err
+	}
+	if config.Device != "" {
+		return nil, errors.New("oto only plays on the default output device")
+	}
+	return &OtoPlayer{realtimeRenderer: newRealtimeRenderer(config)}, nil
+}
+
+func (p *OtoPlayer) PlayContext(ctx context.Context) error { return playContext(ctx, p) }
+
+func (p *OtoPlayer) Play() error {
+	reportProgress(p, p.config)
+	return p.record(p.play)
+}
+
+func (p *OtoPlayer) play() error {
+	ctx, err := otoContext(p.config)
+	if err != nil {
+		return err
+	}
+	player := ctx.NewPlayer(&otoReader{r: p.realtimeRenderer})
+	player.Play()
+
+	// Wait until all rendered frames have been played (or playback is stopped)
+	<-p.Done()
+	for player.IsPlaying() && !p.isStopped() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	player.Pause()
+	if err := player.Err(); err != nil {
+		return fmt.Errorf("play with oto: %w", err)
+	}
+	return nil
+}
+
+// oto only supports one context per process, it is created by the first player.
+var otoCtx struct {
+	once       sync.Once
+	ctx        *oto.Context
+	err        error
+	sampleRate int
+	channels   int
+}
+
+func otoContext(config PlayerConfig) (*oto.Context, error) {
+	sampleRate, channels := config.SampleRate, config.Channels
+	otoCtx.once.Do(func() {
+		options := &oto.NewContextOptions{SampleRate: sampleRate, ChannelCount: channels, Format: oto.FormatFloat32LE}
+		if config.BlockSize > 0 || config.BufferBlocks > 0 {
+			// Otherwise, oto picks a default buffer size for the platform
+			options.BufferSize = frameTime(config.blockSize(256)*config.bufferBlocks(4), sampleRate)
+		}
+		ctx, ready, err := oto.NewContext(options)
+		if err != nil {
+			otoCtx.err = fmt.Errorf("create oto context: %w", err)
+			return
+		}
+		<-ready
+		otoCtx.ctx, otoCtx.sampleRate, otoCtx.channels = ctx, sampleRate, channels
+	})
+	if otoCtx.err != nil {
+		return nil, otoCtx.err
+	}
+	if otoCtx.sampleRate != sampleRate || otoCtx.channels != channels {
+		return nil, fmt.Errorf("oto can only play one format per process (%dHz, %d channels)", otoCtx.sampleRate, otoCtx.channels)
+	}
+	return otoCtx.ctx, nil
+}
+
+// otoReader encodes the rendered frames as f32le.
+type otoReader struct {
+	r   *realtimeRenderer
+	buf []float64
+}
+
+func (o *otoReader) Read(b []byte) (int, error) {
+	channels := o.r.config.Channels
+	count := len(b) / 4 / channels * channels
+	if count == 0 {
+		return 0, nil
+	}
+	if cap(o.buf) < count {
+		o.buf = make([]float64, count)
+	}
+	frames := o.buf[:count]
+	if !o.r.render(frames) {
+		return 0, io.EOF
+	}
+	for i, v := range frames {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(float32(v)))
+	}
+	return 4 * count, nil
+}
@@ -0,0 +1,13 @@
+//go:build 386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm
+
+package audio
+
+import "unsafe"
+
+// encodePCM copies the memory of the frames directly, since float64 values are already stored as f64le.
+func encodePCM(dst []byte, frames []float64) {
+	if len(frames) == 0 {
+		return
+	}
+	copy(dst, unsafe.Slice((*byte)(unsafe.Pointer(&frames[0])), 8*len(frames)))
+}
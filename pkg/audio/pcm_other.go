@@ -0,0 +1,15 @@
+//go:build !(386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm)
+
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// encodePCM encodes the frames one by one (on big-endian architectures).
+func encodePCM(dst []byte, frames []float64) {
+	for i, v := range frames {
+		binary.LittleEndian.PutUint64(dst[8*i:], math.Float64bits(v))
+	}
+}
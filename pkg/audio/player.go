@@ -1,6 +1,8 @@
 package audio
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -12,17 +14,150 @@ import (
 	"github.com/ejuju/ziq/pkg/wave"
 )
 
-type FFPlayPlayerConfig struct {
+// Player plays audio on an output device.
+//
+// Play blocks until playback is done (the end is reached or Stop is called).
+// The other methods can be called from other goroutines while playing (for example: from UI handlers).
+type Player interface {
+	Play() error
+	// PlayContext is like Play, but playback is stopped when the context is cancelled.
+	PlayContext(ctx context.Context) error
+	// Pause suspends playback, Resume continues it.
+	Pause()
+	Resume()
+	// Stop ends playback (Play returns).
+	Stop()
+	// Seek moves the play position.
+	Seek(position time.Duration)
+	// Done is closed once playback is done.
+	Done() <-chan struct{}
+	// Position returns the play position (approximately, depending on the buffering of the backend).
+	Position() time.Duration
+}
+
+// PlayerConfig describes what a player plays, it is shared by all player backends.
+type PlayerConfig struct {
+	// Wave is played on the first output channel pair (or on the only output channel in mono).
+	// It can be nil when routes are provided.
 	Wave       wave.Wave
 	SampleRate int
-	Duration   time.Duration
+	// Duration is the duration to play, 0 means playing forever (until the player is stopped),
+	// for example for generative installations or live coding.
+	Duration time.Duration
+	// Loop is the number of times the duration is played, gaplessly (0 and 1 play it once).
+	// A negative value loops forever.
+	Loop int
+	// Channels is the number of output channels (default: 1, or as many as needed by the routes).
+	Channels int
+	// Device is the output device (default: the default device of the backend), see OutputDevices.
+	// ffplay receives it through the AUDIODEV environment variable of SDL
+	// (SDL_AUDIODRIVER=alsa may be needed for ALSA device names), aplay with its -D flag.
+	Device string
+	// BlockSize is the number of frames rendered at once by real-time backends,
+	// and BufferBlocks is the number of blocks buffered ahead of the output (defaults depend on the backend).
+	// Smaller values lower the latency (changes are heard sooner), but cost more CPU overhead
+	// and risk buffer underruns (heard as clicks) when rendering doesn't keep up:
+	// live performances favor low latency, renders of heavy compositions favor larger buffers.
+	BlockSize    int
+	BufferBlocks int
+	// Routes sends additional waves to specific output channels, for external mixing setups.
+	Routes []Route
+	// Cue is played on the second output channel pair (channels 2 and 3),
+	// for headphone monitoring during a performance (see CueMix).
+	Cue wave.Wave
+	// Click is a click track (see wave.Metronome) played on the channels of Wave by real-time players,
+	// but left out of recordings (see RecordDir), so performers can follow the tempo without it ending up in their takes.
+	// It isn't played with TempFile (which renders the composition).
+	Click wave.Wave
+	// TempFile makes FFPlayPlayer render the whole duration to a temporary file before playing,
+	// instead of streaming frames as they are rendered.
+	// Pause and resume are then done by suspending the ffplay process (Unix only) and seeking restarts it.
+	// A duration is then required.
+	TempFile bool
+	// RecordDir makes real-time players also write what they play to a WAV file of this directory,
+	// named after the time playback started (for example: "ziq-20060102-150405.wav"),
+	// so improvised takes aren't lost. It can't be used with TempFile.
+	RecordDir string
+	// Monitor is called by real-time players with the interleaved frames they render, just before they are played,
+	// to drive visualizations and meters (see the viz package). ffplay doesn't open its window when it is set.
+	// It is called from the render loop (or the audio thread): it should return quickly and not keep the slice.
+	Monitor func(frames []float64)
+	// OnProgress is called with the play position every ProgressInterval (default: 50ms) while playing,
+	// and once more when playback is done, so UIs and visuals can follow the audio.
+	// It is called from another goroutine.
+	OnProgress       func(position time.Duration)
+	ProgressInterval time.Duration
+	// Watchdog observes the render time of each block of real-time players (see NewWatchdog),
+	// so rendering degrades gracefully (see Watchdog.Luxury) when it can't keep up. It isn't used with TempFile.
+	Watchdog *Watchdog
+}
+
+// FFPlayPlayerConfig is the configuration of a FFPlayPlayer.
+type FFPlayPlayerConfig = PlayerConfig
+
+var _ Player = (*FFPlayPlayer)(nil)
+
+// setDefaults validates the config and fills in default values.
+func (c *PlayerConfig) setDefaults() error {
+	if c.Cue != nil {
+		c.Routes = append(append([]Route{}, c.Routes...), Route{Wave: c.Cue, Channels: []int{2, 3}})
+		c.Cue = nil
+	}
+	if c.Wave == nil && len(c.Routes) == 0 {
+		return errors.New("no wave was provided")
+	}
+	if c.Duration < 0 || (c.Duration == 0 && c.TempFile) {
+		return fmt.Errorf("invalid duration: %s", c.Duration)
+	}
+	if c.RecordDir != "" && c.TempFile {
+		return errors.New("recording requires a real-time player (TempFile is set)")
+	}
+	if c.Duration == 0 && c.Loop != 0 && c.Loop != 1 {
+		return errors.New("looping requires a duration")
+	}
+	if c.SampleRate <= 0 {
+		c.SampleRate = 44100
+	}
+	if c.BlockSize < 0 || c.BufferBlocks < 0 {
+		return fmt.Errorf("invalid buffer size: %d blocks of %d frames", c.BufferBlocks, c.BlockSize)
+	}
+	if c.ProgressInterval <= 0 {
+		c.ProgressInterval = 50 * time.Millisecond
+	}
+	if c.Channels <= 0 {
+		c.Channels = numChannels(c.Routes)
+		if c.Channels == 0 {
+			c.Channels = 1
+		}
+	}
+	return validateRoutes(c.Routes, c.Channels)
+}
+
+// routes returns all routes to render, including the main wave.
+func (c PlayerConfig) routes() []Route {
+	if c.Wave == nil {
+		return c.Routes
+	}
+	return append([]Route{{Wave: c.Wave, Channels: c.mainChannels()}}, c.Routes...)
+}
+
+// mainChannels returns the output channels of the main wave.
+func (c PlayerConfig) mainChannels() []int {
+	if c.Channels >= 2 {
+		return []int{0, 1}
+	}
+	return []int{0}
 }
 
 // FFplayPlayer uses ffplay to play the provided frames.
-// It produces a .pcm file under the hood to encode the output sound wave.
-// This file can be saved by setting the SaveFile field to true.
+//
+// By default, frames are rendered in real time (just before they are played) and streamed to ffplay's standard input,
+// so playback starts immediately whatever the duration.
+// With the TempFile option, the whole duration is rendered to a temporary .pcm file before playing.
+// A player plays once.
 type FFPlayPlayer struct {
-	config FFPlayPlayerConfig
+	impl   ffplayBackend // streaming or temporary file implementation
+	config PlayerConfig
 }
 
 func NewFFPlayPlayer(config FFPlayPlayerConfig) (*FFPlayPlayer, error) {
@@ -30,52 +165,173 @@ func NewFFPlayPlayer(config FFPlayPlayerConfig) (*FFPlayPlayer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("ffplay executable lookup: %w", err)
 	}
-	if config.Wave == nil {
-		return nil, errors.New("no wave was provided")
+	if err := config.setDefaults(); err != nil {
+		return nil, err
 	}
-	if config.Duration <= 0 {
-		return nil, fmt.Errorf("invalid duration: %s", config.Duration)
-	}
-	if config.SampleRate <= 0 {
-		config.SampleRate = 44100
+	return newFFPlayPlayer(config), nil
+}
+
+// newFFPlayPlayer creates a player for a config with defaults already set.
+func newFFPlayPlayer(config PlayerConfig) *FFPlayPlayer {
+	if config.TempFile {
+		return &FFPlayPlayer{impl: &ffplayFilePlayer{config: config, done: make(chan struct{})}, config: config}
 	}
+	return &FFPlayPlayer{impl: &ffplayStreamPlayer{newRealtimeRenderer(config)}, config: config}
+}
 
-	return &FFPlayPlayer{config: config}, nil
+func (p *FFPlayPlayer) Play() error {
+	reportProgress(p, p.config)
+	return p.impl.Play()
 }
 
-func (p FFPlayPlayer) Play() error {
-	// get output frames
-	frames := Frames(p.config.Wave, p.config.SampleRate, 0, p.config.Duration)
+func (p *FFPlayPlayer) PlayContext(ctx context.Context) error { return playContext(ctx, p) }
+func (p *FFPlayPlayer) Pause()                                { p.impl.Pause() }
+func (p *FFPlayPlayer) Resume()                               { p.impl.Resume() }
+func (p *FFPlayPlayer) Stop()                                 { p.impl.Stop() }
+func (p *FFPlayPlayer) Seek(position time.Duration)           { p.impl.Seek(position) }
+func (p *FFPlayPlayer) Done() <-chan struct{}                 { return p.impl.Done() }
+func (p *FFPlayPlayer) Position() time.Duration               { return p.impl.Position() }
 
-	// Create tmp file
-	f, err := os.CreateTemp(os.TempDir(), "audio_*.pcm")
-	if err != nil {
-		panic(err)
+// ffplayBackend is implemented by the streaming and temporary file implementations of FFPlayPlayer.
+type ffplayBackend interface {
+	Play() error
+	Pause()
+	Resume()
+	Stop()
+	Seek(position time.Duration)
+	Done() <-chan struct{}
+	Position() time.Duration
+}
+
+// playContext plays with the player and stops it when the context is cancelled.
+func playContext(ctx context.Context, p Player) error {
+	played := make(chan struct{})
+	defer close(played)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.Stop()
+		case <-played:
+		}
+	}()
+	return p.Play()
+}
+
+// reportProgress calls the OnProgress callback of the config (if any) periodically, until playback is done.
+func reportProgress(p Player, config PlayerConfig) {
+	if config.OnProgress == nil {
+		return
 	}
+	go func() {
+		ticker := time.NewTicker(config.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.Done():
+				config.OnProgress(p.Position())
+				return
+			case <-ticker.C:
+				config.OnProgress(p.Position())
+			}
+		}
+	}()
+}
+
+// ffplayStreamPlayer renders frames in real time and streams them to ffplay's standard input.
+type ffplayStreamPlayer struct {
+	*realtimeRenderer
+}
 
-	// Encode PCM output to file
-	err = WritePCM(f, frames)
+func (p *ffplayStreamPlayer) Play() error { return p.record(p.play) }
+
+func (p *ffplayStreamPlayer) play() error {
+	cmdstr := strings.Split(newFFPlayCommand(p.config.SampleRate, p.config.Channels, "pipe:0", p.config.Monitor == nil), " ")
+	cmd := exec.Command(cmdstr[0], cmdstr[1:]...)
+	cmd.Env = ffplayEnv(p.config)
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return fmt.Errorf("encode PCM pulses: %w", err)
+		return fmt.Errorf("get ffplay stdin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start ffplay: %w", err)
 	}
-	defer f.Close()
-	defer os.Remove(f.Name())
+	go func() {
+		<-p.Done()
+		if p.isStopped() {
+			_ = cmd.Process.Kill()
+		}
+	}()
 
-	// Read output file with ffplay (by launching ffplay from the CLI)
-	cmdstr := strings.Split(newFFPlayCommand(p.config.SampleRate, f.Name()), " ")
-	_, err = exec.Command(cmdstr[0], cmdstr[1:]...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("play PCM file using ffplay: %w", err)
+	// ffplay reads its input as fast as it can,
+	// so rendering is paced with the wall clock to keep pause and seek responsive.
+	// By default, 10 blocks of 10ms are rendered in advance.
+	block := p.config.blockSize(p.config.SampleRate / 100)
+	ahead := frameTime(block*p.config.bufferBlocks(10), p.config.SampleRate)
+	frames := make([]float64, block*p.config.Channels)
+	encoded := []byte{}
+	w := bufio.NewWriter(stdin)
+	start := time.Now()
+	for i := 0; ; i += block {
+		if wait := frameTime(i, p.config.SampleRate) - time.Since(start) - ahead; wait > 0 {
+			select {
+			case <-p.Done():
+			case <-time.After(wait):
+			}
+		}
+		if !p.render(frames) {
+			break
+		}
+		encoded = EncodePCM(encoded[:0], frames)
+		if _, err := w.Write(encoded); err != nil {
+			break // ffplay was killed (stopped) or failed, see its exit status
+		}
+		if err := w.Flush(); err != nil {
+			break
+		}
+	}
+	_ = stdin.Close()
+	if err := cmd.Wait(); err != nil && !p.isStopped() {
+		return fmt.Errorf("play PCM stream using ffplay: %w", err)
 	}
 	return nil
 }
 
+// blockSize returns the configured block size, or the default of the backend.
+func (c PlayerConfig) blockSize(def int) int {
+	if c.BlockSize > 0 {
+		return c.BlockSize
+	}
+	return def
+}
+
+// bufferBlocks returns the configured number of buffered blocks, or the default of the backend.
+func (c PlayerConfig) bufferBlocks(def int) int {
+	if c.BufferBlocks > 0 {
+		return c.BufferBlocks
+	}
+	return def
+}
+
+// ffplayEnv returns the environment of ffplay, with the output device of the config.
+func ffplayEnv(config PlayerConfig) []string {
+	if config.Device == "" {
+		return nil // inherit the environment
+	}
+	return append(os.Environ(), "AUDIODEV="+config.Device)
+}
+
 // newFFPlayCommand returns the command string used to play a PCM file with ffplay.
-func newFFPlayCommand(sampleRate int, filepath string) string {
+// The window of ffplay shows the waveform when display is true.
+func newFFPlayCommand(sampleRate, channels int, filepath string, display bool) string {
+	window := "-showmode 1"
+	if !display {
+		window = "-nodisp"
+	}
 	return "ffplay" + " " +
 		"-f f64le" + " " +
 		"-ar " + strconv.Itoa(sampleRate) + " " +
+		"-ac " + strconv.Itoa(channels) + " " +
 		"-autoexit" + " " +
-		"-showmode 1" + " " +
+		window + " " +
 		filepath
 }
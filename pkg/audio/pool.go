@@ -0,0 +1,24 @@
+package audio
+
+import "sync"
+
+// Buffers released with PutBuffer, reused by GetBuffer to reduce allocations (and GC pauses)
+// during streaming or looped playback.
+var buffers = sync.Pool{}
+
+// GetBuffer returns a buffer of size frames, reused from a previously released buffer when possible.
+// Its content is undefined, it should be fully overwritten (for example with RenderFrames).
+// The buffer is returned as a pointer, so releasing it with PutBuffer doesn't allocate.
+func GetBuffer(size int) *[]float64 {
+	if b, ok := buffers.Get().(*[]float64); ok && cap(*b) >= size {
+		*b = (*b)[:size]
+		return b
+	}
+	b := make([]float64, size)
+	return &b
+}
+
+// PutBuffer releases a buffer obtained with GetBuffer, it must not be used afterwards.
+func PutBuffer(buf *[]float64) {
+	buffers.Put(buf)
+}
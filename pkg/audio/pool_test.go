@@ -0,0 +1,52 @@
+package audio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestBufferPool(t *testing.T) {
+	PutBuffer(GetBuffer(1024))
+	// Released buffers are reused without allocating
+	// (the pool may still drop a few of them, for example under the race detector)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf := GetBuffer(512)
+		(*buf)[511] = 1
+		PutBuffer(buf)
+	})
+	if allocs > 0.5 {
+		t.Errorf("%v allocations per buffer, want none", allocs)
+	}
+	if buf := GetBuffer(4096); len(*buf) != 4096 {
+		t.Errorf("buffer of %d frames, want 4096", len(*buf))
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	rec, err := newRecorder(t.TempDir(), 8000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.write([]float64{0.5, -0.5})
+	rec.write([]float64{0.25})
+	if err := rec.close(); err != nil {
+		t.Fatal(err)
+	}
+	rec.write([]float64{1}) // ignored once closed
+
+	frames, sampleRate, err := wave.ImportWavFrames(rec.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0.5, -0.5, 0.25}
+	if sampleRate != 8000 || len(frames) != len(want) {
+		t.Fatalf("recorded %d frames at %dHz, want %d frames at 8000Hz", len(frames), sampleRate, len(want))
+	}
+	for i := range want {
+		if math.Abs(frames[i]-want[i]) > 1e-3 {
+			t.Errorf("frame %d = %v, want %v", i, frames[i], want[i])
+		}
+	}
+}
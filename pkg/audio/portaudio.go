@@ -0,0 +1,135 @@
+//go:build portaudio
+
+package audio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// Default number of frames rendered per PortAudio callback (about 6ms at 44100Hz),
+// and default number of blocks of output latency.
+const (
+	portAudioFramesPerBuffer = 256
+	portAudioBufferBlocks    = 2
+)
+
+// PortAudioPlayer plays audio with PortAudio, rendering the wave in the audio callback (block by block)
+// for genuinely real-time, low-latency synthesis.
+//
+// It is only available when building with the "portaudio" build tag
+// (cgo and PortAudio, like portaudio19-dev, must be installed).
+type PortAudioPlayer struct {
+	*realtimeRenderer
+}
+
+var _ Player = (*PortAudioPlayer)(nil)
+
+func NewPortAudioPlayer(config PlayerConfig) (*PortAudioPlayer, error) {
+	if err := config.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &PortAudioPlayer{realtimeRenderer: newRealtimeRenderer(config)}, nil
+}
+
+func (p *PortAudioPlayer) PlayContext(ctx context.Context) error { return playContext(ctx, p) }
+
+func (p *PortAudioPlayer) Play() error {
+	reportProgress(p, p.config)
+	return p.record(p.play)
+}
+
+func (p *PortAudioPlayer) play() error {
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("initialize PortAudio: %w", err)
+	}
+	defer portaudio.Terminate()
+
+	// The callback runs on the audio thread: it must not allocate or block.
+	block := p.config.blockSize(portAudioFramesPerBuffer)
+	buf := make([]float64, block*p.config.Channels)
+	callback := func(out []float32) {
+		if len(out) > len(buf) {
+			for i := range out {
+				out[i] = 0
+			}
+			return
+		}
+		frames := buf[:len(out)]
+		p.render(frames)
+		for i, v := range frames {
+			out[i] = float32(v)
+		}
+	}
+	device, err := portAudioDevice(p.config.Device)
+	if err != nil {
+		return err
+	}
+	params := portaudio.LowLatencyParameters(nil, device)
+	params.Output.Channels = p.config.Channels
+	params.SampleRate = float64(p.config.SampleRate)
+	params.FramesPerBuffer = block
+	params.Output.Latency = frameTime(block*p.config.bufferBlocks(portAudioBufferBlocks), p.config.SampleRate)
+	stream, err := portaudio.OpenStream(params, callback)
+	if err != nil {
+		return fmt.Errorf("open PortAudio stream: %w", err)
+	}
+	defer stream.Close()
+	if err := stream.Start(); err != nil {
+		return fmt.Errorf("start PortAudio stream: %w", err)
+	}
+
+	<-p.Done()
+	if !p.isStopped() {
+		// Let the last rendered frames be played
+		time.Sleep(stream.Info().OutputLatency)
+	}
+	if err := stream.Stop(); err != nil {
+		return fmt.Errorf("stop PortAudio stream: %w", err)
+	}
+	return nil
+}
+
+// PortAudioDevices lists the names of the output devices available with PortAudio,
+// to be used as the Device of a player config.
+func PortAudioDevices() ([]string, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return nil, fmt.Errorf("initialize PortAudio: %w", err)
+	}
+	defer portaudio.Terminate()
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("list PortAudio devices: %w", err)
+	}
+	names := []string{}
+	for _, d := range devices {
+		if d.MaxOutputChannels > 0 {
+			names = append(names, d.Name)
+		}
+	}
+	return names, nil
+}
+
+// portAudioDevice returns the output device with the given name (or the default output device).
+func portAudioDevice(name string) (*portaudio.DeviceInfo, error) {
+	if name == "" {
+		device, err := portaudio.DefaultOutputDevice()
+		if err != nil {
+			return nil, fmt.Errorf("get default PortAudio output device: %w", err)
+		}
+		return device, nil
+	}
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("list PortAudio devices: %w", err)
+	}
+	for _, d := range devices {
+		if d.Name == name && d.MaxOutputChannels > 0 {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("PortAudio output device not found: %q", name)
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9 || js
+
+package audio
+
+import (
+	"errors"
+	"os"
+)
+
+var errSuspendUnsupported = errors.New("suspending a process isn't supported on this system")
+
+// suspendProcess isn't supported on this system.
+func suspendProcess(p *os.Process) error { return errSuspendUnsupported }
+
+func resumeProcess(p *os.Process) error { return errSuspendUnsupported }
@@ -0,0 +1,13 @@
+//go:build !windows && !plan9 && !js
+
+package audio
+
+import (
+	"os"
+	"syscall"
+)
+
+// suspendProcess pauses a process (for example: ffplay), until resumeProcess is called.
+func suspendProcess(p *os.Process) error { return p.Signal(syscall.SIGSTOP) }
+
+func resumeProcess(p *os.Process) error { return p.Signal(syscall.SIGCONT) }
@@ -0,0 +1,36 @@
+package audio
+
+import "github.com/ejuju/ziq/pkg/wave"
+
+// Number of frames rendered at once by a FrameReader.
+const frameReaderBlock = 1024
+
+// FrameReader renders a wave on demand and encodes it as PCM (f64le, like WritePCM),
+// so long (or infinite) renders never have to sit in memory.
+type FrameReader struct {
+	src        wave.Wave
+	sampleRate int
+	next       int    // index of the next frame to render
+	block      []byte // encoded frames of the last rendered block
+	buf        []byte // encoded frames not read yet
+}
+
+// NewFrameReader creates a reader producing the frames of the wave, starting at 0.
+// It never ends, use io.LimitReader to read a given duration (8 bytes per frame).
+func NewFrameReader(src wave.Wave, sampleRate int) *FrameReader {
+	return &FrameReader{src: src, sampleRate: sampleRate, block: make([]byte, 8*frameReaderBlock)}
+}
+
+func (r *FrameReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		frames := GetBuffer(frameReaderBlock)
+		RenderFrames(*frames, r.src, r.sampleRate, r.next)
+		r.next += frameReaderBlock
+		encodePCM(r.block, *frames)
+		PutBuffer(frames)
+		r.buf = r.block
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,198 @@
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+// realtimeRenderer renders the routes of a player block by block, just before they are played,
+// while playback is controlled from other goroutines (pause, seek and stop).
+// It is shared by the player backends rendering in real time.
+type realtimeRenderer struct {
+	config  PlayerConfig
+	routes  []Route
+	click   []Route // route of the click track (not recorded)
+	total   int     // number of frames to play (-1 when playing forever)
+	period  int     // number of frames of the duration when looping (0 otherwise)
+	scratch []float64
+
+	mu       sync.Mutex
+	recorder *recorder // set while recording
+	next     int       // index of the next frame to render
+	paused   bool
+	stopped  bool
+	done     chan struct{}
+	closed   bool
+}
+
+// newRealtimeRenderer creates a renderer for a config with defaults already set.
+func newRealtimeRenderer(config PlayerConfig) *realtimeRenderer {
+	r := &realtimeRenderer{config: config, routes: config.routes(), total: -1, done: make(chan struct{})}
+	if config.Click != nil {
+		r.click = []Route{{Wave: config.Click, Channels: config.mainChannels()}}
+	}
+	if config.Duration > 0 {
+		r.total = int(int64(config.Duration) * int64(config.SampleRate) / int64(time.Second))
+	}
+	if config.Duration > 0 && config.Loop != 0 && config.Loop != 1 {
+		r.period = r.total
+		r.total *= config.Loop
+		if config.Loop < 0 {
+			r.total = -1
+		}
+	}
+	return r
+}
+
+// render fills the buffer with interleaved frames (its length should be a multiple of the number of channels).
+// Silence is rendered while paused.
+// It returns false once playback is done, the buffer is then filled with silence.
+func (r *realtimeRenderer) render(buf []float64) bool {
+	for i := range buf {
+		buf[i] = 0
+	}
+	channels := r.config.Channels
+	count := len(buf) / channels
+
+	r.mu.Lock()
+	if r.stopped || (r.total >= 0 && r.next >= r.total) {
+		r.finish()
+		r.mu.Unlock()
+		return false
+	}
+	rec := r.recorder
+	if r.paused {
+		r.mu.Unlock()
+		if rec != nil {
+			rec.write(buf)
+		}
+		if r.config.Monitor != nil {
+			r.config.Monitor(buf)
+		}
+		return true
+	}
+	if remaining := r.total - r.next; r.total >= 0 && count > remaining {
+		count = remaining
+	}
+	first := r.next
+	r.next += count
+	r.mu.Unlock()
+
+	began := time.Now()
+	// When looping, frames past the end of the duration wrap to its beginning
+	for rendered := 0; rendered < count; {
+		start, n := first+rendered, count-rendered
+		if r.period > 0 {
+			start %= r.period
+			if start+n > r.period {
+				n = r.period - start
+			}
+		}
+		r.renderRoutes(buf[rendered*channels:(rendered+n)*channels], r.routes, start, n)
+		rendered += n
+	}
+	if r.config.Watchdog != nil {
+		r.config.Watchdog.Observe(time.Since(began), frameTime(count, r.config.SampleRate))
+	}
+	if rec != nil {
+		rec.write(buf[:count*channels])
+	}
+	// The click track is added after recording, so it is heard but not recorded
+	if r.click != nil {
+		r.renderRoutes(buf[:count*channels], r.click, first, count)
+	}
+	if r.config.Monitor != nil {
+		r.config.Monitor(buf[:count*channels])
+	}
+	return true
+}
+
+// renderRoutes adds count interleaved frames of the routes to the buffer, starting at the frame with the index first.
+func (r *realtimeRenderer) renderRoutes(buf []float64, routes []Route, first, count int) {
+	channels := r.config.Channels
+	if cap(r.scratch) < count {
+		r.scratch = make([]float64, count)
+	}
+	frames := r.scratch[:count]
+	for _, route := range routes {
+		RenderFrames(frames, route.Wave, r.config.SampleRate, first)
+		for _, c := range route.Channels {
+			for i, v := range frames {
+				buf[i*channels+c] += v
+			}
+		}
+	}
+}
+
+// finish closes the done channel (once), it must be called with the mutex locked.
+func (r *realtimeRenderer) finish() {
+	if !r.closed {
+		r.closed = true
+		close(r.done)
+	}
+}
+
+func (r *realtimeRenderer) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+}
+
+func (r *realtimeRenderer) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = false
+}
+
+func (r *realtimeRenderer) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	r.finish()
+}
+
+// Seek moves the play position (clamped between 0 and the duration, if any).
+// When looping, the position is relative to the current repetition.
+func (r *realtimeRenderer) Seek(position time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := int(int64(position) * int64(r.config.SampleRate) / int64(time.Second))
+	if r.period > 0 {
+		if next > r.period {
+			next = r.period
+		}
+		next += r.next - r.next%r.period
+	}
+	if next < 0 {
+		next = 0
+	} else if r.total >= 0 && next > r.total {
+		next = r.total
+	}
+	r.next = next
+}
+
+func (r *realtimeRenderer) Done() <-chan struct{} { return r.done }
+
+// Position returns the position of the last rendered frames
+// (slightly ahead of what is heard, because of the buffering of the backend).
+// When looping, it is the position in the current repetition.
+func (r *realtimeRenderer) Position() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.next
+	if r.period > 0 {
+		if r.total >= 0 && next >= r.total {
+			next = r.period // end of the last repetition
+		} else {
+			next %= r.period
+		}
+	}
+	return frameTime(next, r.config.SampleRate)
+}
+
+// isStopped reports whether Stop was called.
+func (r *realtimeRenderer) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Number of rendered blocks that can wait to be written to a recording.
+const recorderBacklog = 1024
+
+// recorder writes the rendered frames of a player to a WAV file in the background,
+// so disk writes never delay the render loop (or the audio thread).
+type recorder struct {
+	path   string
+	f      *os.File
+	w      *WavWriter
+	blocks chan *[]float64 // buffers of the pool, released once written
+	done   chan error
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+}
+
+// newRecorder creates a WAV file named after the current time (for example: "ziq-20060102-150405.wav") in the directory.
+func newRecorder(dir string, sampleRate, channels int) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create recording directory: %w", err)
+	}
+	path := filepath.Join(dir, "ziq-"+time.Now().Format("20060102-150405")+".wav")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create file: %s: %w", path, err)
+	}
+	w, err := NewWavWriter(f, sampleRate, channels)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &recorder{path: path, f: f, w: w, blocks: make(chan *[]float64, recorderBacklog), done: make(chan error, 1)}
+	go func() {
+		var err error
+		for block := range r.blocks {
+			if err == nil {
+				err = r.w.Write(*block)
+			}
+			PutBuffer(block)
+		}
+		r.done <- err
+	}()
+	return r, nil
+}
+
+// write queues a copy of the frames, without blocking.
+func (r *recorder) write(frames []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	block := GetBuffer(len(frames))
+	copy(*block, frames)
+	select {
+	case r.blocks <- block:
+	default:
+		r.dropped++
+		PutBuffer(block)
+	}
+}
+
+// close writes the queued frames and finalizes the file.
+func (r *recorder) close() error {
+	r.mu.Lock()
+	r.closed = true
+	close(r.blocks)
+	r.mu.Unlock()
+
+	err := <-r.done
+	if err != nil {
+		r.f.Close()
+		return fmt.Errorf("write recording: %s: %w", r.path, err)
+	}
+	if err := r.w.Close(); err != nil {
+		r.f.Close()
+		return fmt.Errorf("encode WAV header: %s: %w", r.path, err)
+	}
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.dropped > 0 {
+		return fmt.Errorf("recording %s is incomplete: %d blocks were dropped (the disk is too slow)", r.path, r.dropped)
+	}
+	return nil
+}
+
+// record plays with the play function while the rendered frames are recorded (when the config has a RecordDir).
+func (r *realtimeRenderer) record(play func() error) error {
+	if r.config.RecordDir == "" {
+		return play()
+	}
+	rec, err := newRecorder(r.config.RecordDir, r.config.SampleRate, r.config.Channels)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.recorder = rec
+	r.mu.Unlock()
+
+	err = play()
+
+	r.mu.Lock()
+	r.recorder = nil
+	r.mu.Unlock()
+	if cerr := rec.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
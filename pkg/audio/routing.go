@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Route sends a wave to specific output channels of the audio device (0-based).
+//
+// For example, to send drums to the outputs 3/4 of a multi-channel interface:
+// Route{Wave: drums, Channels: []int{2, 3}}
+type Route struct {
+	Wave     wave.Wave
+	Channels []int
+}
+
+// StereoRoutes sends the channels of a stereo wave to the first two output channels.
+func StereoRoutes(s wave.StereoWave) []Route {
+	return []Route{{Wave: s.Left, Channels: []int{0}}, {Wave: s.Right, Channels: []int{1}}}
+}
+
+// numChannels returns the number of output channels needed to play the routes.
+func numChannels(routes []Route) int {
+	out := 0
+	for _, r := range routes {
+		for _, c := range r.Channels {
+			if c+1 > out {
+				out = c + 1
+			}
+		}
+	}
+	return out
+}
+
+func validateRoutes(routes []Route, channels int) error {
+	for i, r := range routes {
+		if r.Wave == nil {
+			return fmt.Errorf("no wave was provided for route %d", i)
+		}
+		if len(r.Channels) == 0 {
+			return fmt.Errorf("no channel was provided for route %d", i)
+		}
+		for _, c := range r.Channels {
+			if c < 0 || c >= channels {
+				return fmt.Errorf("invalid channel for route %d: %d (%d channels available)", i, c, channels)
+			}
+		}
+	}
+	return nil
+}
+
+// RoutedFrames renders the routes to interleaved multi-channel frames.
+// Waves routed to the same channel are summed.
+func RoutedFrames(routes []Route, channels int, framesPerSec int, start, end time.Duration) []float64 {
+	perChannel := make([][]float64, channels)
+	for _, r := range routes {
+		frames := Frames(r.Wave, framesPerSec, start, end)
+		for _, c := range r.Channels {
+			if perChannel[c] == nil {
+				perChannel[c] = make([]float64, len(frames))
+			}
+			Mix(perChannel[c], frames)
+		}
+	}
+	return Interleave(perChannel...)
+}
+
+// Interleave merges the frames of several channels (ch1[0], ch2[0], ch1[1], ch2[1], ...).
+// Missing frames (when channels don't have the same length, or nil channels) are silent.
+func Interleave(channels ...[]float64) []float64 {
+	length := 0
+	for _, c := range channels {
+		if len(c) > length {
+			length = len(c)
+		}
+	}
+	out := make([]float64, length*len(channels))
+	for c, frames := range channels {
+		for i, v := range frames {
+			out[i*len(channels)+c] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+type ScrubMode int
+
+const (
+	// ScrubRepeat repeats a short window of the source around the scrub position.
+	ScrubRepeat ScrubMode = iota
+	// ScrubVarispeed makes the play head follow the scrub position:
+	// the source plays faster (higher pitch) or slower (lower pitch) depending on how fast the position moves.
+	ScrubVarispeed
+)
+
+// Scrubber plays a source around a position controlled by the user (for example: dragging a cursor in a UI).
+//
+// SetPosition can be called from any goroutine while the scrubber wave is being played.
+// The scrubber wave is stateful (see wave.Wave).
+type Scrubber struct {
+	src    wave.Wave
+	mode   ScrubMode
+	window time.Duration
+
+	mu       sync.Mutex
+	position time.Duration // position requested by the user
+	head     float64       // play head (varispeed mode)
+	anchor   time.Duration // position of the current window (repeat mode)
+	index    int64         // index of the current window (repeat mode)
+	last     time.Duration
+}
+
+// NewScrubber creates a scrubber, the window is the length of the repeated
+// window (repeat mode) or the time it takes for the play head to catch up with the position (varispeed mode).
+// It defaults to 60ms.
+func NewScrubber(src wave.Wave, mode ScrubMode, window time.Duration) *Scrubber {
+	if window <= 0 {
+		window = 60 * time.Millisecond
+	}
+	return &Scrubber{src: src, mode: mode, window: window, index: -1}
+}
+
+// SetPosition moves the scrub position.
+func (s *Scrubber) SetPosition(position time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.position = position
+}
+
+// Wave returns the scrubbed output.
+func (s *Scrubber) Wave() wave.Wave {
+	return func(x time.Duration) float64 {
+		// The source is played outside of the lock, so it never blocks SetPosition
+		s.mu.Lock()
+		switch s.mode {
+		case ScrubVarispeed:
+			if elapsed := x - s.last; elapsed > 0 {
+				s.head += (float64(s.position) - s.head) * (1 - math.Exp(-4.6*float64(elapsed)/float64(s.window)))
+			}
+			s.last = x
+			head := time.Duration(s.head)
+			s.mu.Unlock()
+			return s.src(head)
+		default:
+			index := int64(x / s.window)
+			if index != s.index {
+				s.index = index
+				s.anchor = s.position - s.window/2
+			}
+			anchor := s.anchor
+			s.mu.Unlock()
+			elapsed := x % s.window
+			return s.src(anchor+elapsed) * windowEnvelope(elapsed, s.window)
+		}
+	}
+}
+
+// windowEnvelope is a Hann window, it removes clicks at the window boundaries.
+func windowEnvelope(x, window time.Duration) float64 {
+	return 0.5 - 0.5*math.Cos(2*math.Pi*float64(x)/float64(window))
+}
+
+// Scrub plays a short window (of the given length) of the player's wave around the position,
+// so a UI can call it repeatedly while the user drags a cursor.
+func (p *FFPlayPlayer) Scrub(position, window time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("invalid window: %s", window)
+	}
+	start := position - window/2
+	if start < 0 {
+		start = 0
+	}
+	config := p.config
+	config.Duration = window
+	config.Wave = nil
+	config.Routes = nil
+	for _, r := range p.config.routes() {
+		src := r.Wave
+		r.Wave = func(x time.Duration) float64 { return src(start+x) * windowEnvelope(x, window) }
+		config.Routes = append(config.Routes, r)
+	}
+	return newFFPlayPlayer(config).Play()
+}
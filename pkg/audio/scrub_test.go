@@ -0,0 +1,27 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScrubber(t *testing.T) {
+	for _, mode := range []ScrubMode{ScrubRepeat, ScrubVarispeed} {
+		var s *Scrubber
+		// The source moves the position while it is played, like a UI reacting to the output
+		s = NewScrubber(func(x time.Duration) float64 {
+			s.SetPosition(time.Second)
+			return 1
+		}, mode, 0)
+		done := make(chan float64)
+		go func() { done <- s.Wave()(30 * time.Millisecond) }()
+		select {
+		case got := <-done:
+			if got <= 0 || got > 1 {
+				t.Errorf("mode %d: output = %v, want between 0 and 1", mode, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("mode %d: the scrubber is locked while its source plays", mode)
+		}
+	}
+}
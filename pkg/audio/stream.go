@@ -0,0 +1,26 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// StreamFFPlay plays a wave in real time with ffplay (fed through its standard input),
+// until the context is cancelled.
+//
+// Frames are rendered just before they are played (instead of rendering the whole duration upfront),
+// so changes made to stateful waves (transport, live parameters, etc.) are heard almost immediately.
+func StreamFFPlay(ctx context.Context, src wave.Wave, sampleRate int) error {
+	_, err := exec.LookPath("ffplay")
+	if err != nil {
+		return fmt.Errorf("ffplay executable lookup: %w", err)
+	}
+	config := PlayerConfig{Wave: src, SampleRate: sampleRate}
+	if err := config.setDefaults(); err != nil {
+		return err
+	}
+	return newFFPlayPlayer(config).PlayContext(ctx)
+}
@@ -0,0 +1,243 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Formats of a StreamServer.
+const (
+	// StreamWav is an endless 16-bit PCM WAV file (its header has the maximum data size).
+	StreamWav = "wav"
+	// StreamOgg is OGG/Vorbis, encoded by ffmpeg for each listener.
+	StreamOgg = "ogg"
+)
+
+// Number of blocks a listener can lag behind before it is disconnected.
+const streamListenerBacklog = 50
+
+// StreamServer serves a wave as a live HTTP audio stream,
+// so a generative piece can be listened to in a browser or piped to other machines
+// (for example: "curl http://host:8000 | ffplay -").
+//
+// The wave is rendered in real time by a single render loop (see Run):
+// all listeners hear the same audio and join the stream where it currently is, like a radio.
+type StreamServer struct {
+	src        wave.Wave
+	sampleRate int
+	format     string
+
+	mu        sync.Mutex
+	listeners map[chan []float64]struct{}
+	done      bool // set once Run returns
+}
+
+// NewStreamServer creates a server for the wave, in the given format (StreamWav or StreamOgg).
+func NewStreamServer(src wave.Wave, sampleRate int, format string) (*StreamServer, error) {
+	if src == nil {
+		return nil, fmt.Errorf("no wave was provided")
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	switch format {
+	case StreamWav:
+	case StreamOgg:
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			return nil, fmt.Errorf("ffmpeg executable lookup: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported stream format: %q", format)
+	}
+	return &StreamServer{src: src, sampleRate: sampleRate, format: format, listeners: map[chan []float64]struct{}{}}, nil
+}
+
+// Run renders the wave in real time (by blocks of 100ms) and sends it to the listeners,
+// until the context is cancelled. Listeners connecting after Run returned are rejected (503 Service Unavailable).
+func (s *StreamServer) Run(ctx context.Context) error {
+	block := s.sampleRate / 10
+	start := time.Now()
+	for next := 0; ; next += block {
+		// Wait until the block is due
+		due := start.Add(frameTime(next, s.sampleRate))
+		select {
+		case <-ctx.Done():
+			s.closeListeners()
+			return nil
+		case <-time.After(time.Until(due)):
+		}
+
+		frames := make([]float64, block) // not reused: listeners receive the slice
+		RenderFrames(frames, s.src, s.sampleRate, next)
+		s.mu.Lock()
+		for l := range s.listeners {
+			select {
+			case l <- frames:
+			default:
+				// The listener can't keep up (slow network)
+				delete(s.listeners, l)
+				close(l)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *StreamServer) closeListeners() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	for l := range s.listeners {
+		delete(s.listeners, l)
+		close(l)
+	}
+}
+
+// listen registers a new listener, it returns false once the stream is done.
+func (s *StreamServer) listen() (chan []float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return nil, false
+	}
+	l := make(chan []float64, streamListenerBacklog)
+	s.listeners[l] = struct{}{}
+	return l, true
+}
+
+func (s *StreamServer) unlisten(l chan []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.listeners[l]; ok {
+		delete(s.listeners, l)
+		close(l)
+	}
+}
+
+// ServeHTTP streams the audio until the client disconnects (or the server stops running).
+func (s *StreamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l, ok := s.listen()
+	if !ok {
+		http.Error(w, "the stream is over", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unlisten(l)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	switch s.format {
+	case StreamWav:
+		w.Header().Set("Content-Type", "audio/wav")
+		_ = s.serveWav(r.Context(), flushWriter{w}, l)
+	case StreamOgg:
+		w.Header().Set("Content-Type", "audio/ogg")
+		_ = s.serveOgg(r.Context(), flushWriter{w}, l)
+	}
+}
+
+func (s *StreamServer) serveWav(ctx context.Context, w io.Writer, l chan []float64) error {
+	if _, err := w.Write(streamWavHeader(s.sampleRate)); err != nil {
+		return err
+	}
+	encoded := []byte{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case frames, ok := <-l:
+			if !ok {
+				return nil
+			}
+			encoded = encodeInt16(encoded[:0], frames)
+			if _, err := w.Write(encoded); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *StreamServer) serveOgg(ctx context.Context, w io.Writer, l chan []float64) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-loglevel", "error",
+		"-f", "f64le", "-ar", strconv.Itoa(s.sampleRate), "-ac", "1", "-i", "pipe:0",
+		"-c:a", "libvorbis", "-f", "ogg", "pipe:1",
+	)
+	cmd.Stdout = w
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("get ffmpeg stdin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	encoded := []byte{}
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case frames, ok := <-l:
+			if !ok {
+				done = true
+				break
+			}
+			encoded = EncodePCM(encoded[:0], frames)
+			if _, err := stdin.Write(encoded); err != nil {
+				done = true
+			}
+		}
+	}
+	stdin.Close()
+	return cmd.Wait()
+}
+
+// streamWavHeader returns the header of a mono 16-bit WAV file of unknown (maximum) length.
+func streamWavHeader(sampleRate int) []byte {
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], math.MaxUint32)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16) // size of the fmt chunk
+	binary.LittleEndian.PutUint16(h[20:], 1)  // PCM
+	binary.LittleEndian.PutUint16(h[22:], 1)  // channels
+	binary.LittleEndian.PutUint32(h[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(sampleRate*2)) // bytes per second
+	binary.LittleEndian.PutUint16(h[32:], 2)                    // bytes per frame
+	binary.LittleEndian.PutUint16(h[34:], 16)                   // bits per sample
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], math.MaxUint32-36)
+	return h
+}
+
+// encodeInt16 appends the frames as 16-bit little-endian integers (clipped to [-1, 1]) to dst.
+func encodeInt16(dst []byte, frames []float64) []byte {
+	for _, v := range frames {
+		if v > 1 {
+			v = 1
+		} else if v < -1 {
+			v = -1
+		}
+		i := uint16(int16(v * 32767))
+		dst = append(dst, byte(i), byte(i>>8))
+	}
+	return dst
+}
+
+// flushWriter flushes each write, so the audio is sent as soon as it is rendered.
+type flushWriter struct{ w http.ResponseWriter }
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestEncodeInt16(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want []byte
+	}{
+		{in: 0, want: []byte{0, 0}},
+		{in: 1, want: []byte{0xFF, 0x7F}},
+		{in: -1, want: []byte{0x01, 0x80}},
+		{in: 2, want: []byte{0xFF, 0x7F}},
+		{in: -2, want: []byte{0x01, 0x80}},
+		{in: 0.5, want: []byte{0xFF, 0x3F}},
+	}
+	for _, tt := range tests {
+		if got := encodeInt16(nil, []float64{tt.in}); !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeInt16(%v) = % x, want % x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStreamServer(t *testing.T) {
+	s, err := NewStreamServer(wave.Const(0.5), 8000, StreamWav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{})
+	go func() {
+		defer close(ran)
+		_ = s.Run(ctx)
+	}()
+
+	// A listener receives the header and the audio, until the stream is done
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "audio/wav" {
+		t.Errorf("content type = %q, want audio/wav", ct)
+	}
+	header := make([]byte, 44+2)
+	if _, err := io.ReadFull(res.Body, header); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(header[:44], streamWavHeader(8000)) || !bytes.Equal(header[44:], encodeInt16(nil, []float64{0.5})) {
+		t.Errorf("stream starts with % x", header)
+	}
+
+	cancel()
+	<-ran
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, res.Body)
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener wasn't closed when the stream was done")
+	}
+
+	// Late listeners are rejected
+	late, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	late.Body.Close()
+	if late.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("late listener status = %d, want %d", late.StatusCode, http.StatusServiceUnavailable)
+	}
+}
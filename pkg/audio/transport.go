@@ -0,0 +1,128 @@
+package audio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Transport controls the playback of a source (play, pause and seek) while it is being streamed.
+//
+// Its methods can be called from any goroutine (for example: keyboard or UI handlers).
+// The transport wave is stateful, it should be played by a single real-time render loop:
+// the play position advances with the positions at which it is evaluated, and silence is produced while paused.
+type Transport struct {
+	src wave.Wave
+	end time.Duration
+
+	mu       sync.Mutex
+	playing  bool
+	position time.Duration
+	last     time.Duration
+	started  bool
+	done     chan struct{}
+	closed   bool
+}
+
+// NewTransport creates a paused transport.
+// Playback is done once the play position reaches the end (0 means that the source never ends).
+func NewTransport(src wave.Wave, end time.Duration) *Transport {
+	return &Transport{src: src, end: end, done: make(chan struct{})}
+}
+
+func (t *Transport) Play() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.playing = true
+}
+
+func (t *Transport) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.playing = false
+}
+
+// Toggle pauses or resumes playback.
+func (t *Transport) Toggle() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.playing = !t.playing
+}
+
+// Seek moves the play position (clamped between 0 and the end).
+func (t *Transport) Seek(position time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if position < 0 {
+		position = 0
+	} else if t.end > 0 && position > t.end {
+		position = t.end
+	}
+	t.position = position
+}
+
+// Position returns the current play position.
+func (t *Transport) Position() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.position
+}
+
+// Playing reports whether the transport is playing (or paused).
+func (t *Transport) Playing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.playing
+}
+
+// Done is closed once the play position reaches the end.
+func (t *Transport) Done() <-chan struct{} { return t.done }
+
+// Wave returns the output of the transport.
+func (t *Transport) Wave() wave.Wave {
+	return func(x time.Duration) float64 {
+		t.mu.Lock()
+		if !t.started {
+			t.started = true
+			t.last = x
+		}
+		if t.playing && x > t.last {
+			t.position += x - t.last
+		}
+		t.last = x
+		playing, position := t.playing, t.position
+		if t.end > 0 && position >= t.end {
+			playing = false
+			if !t.closed {
+				t.closed = true
+				close(t.done)
+			}
+		}
+		t.mu.Unlock()
+
+		if !playing {
+			return 0
+		}
+		return t.src(position)
+	}
+}
+
+// Follow returns a wave playing src at the play position of the transport (silent while paused),
+// for waves rendered just after the transport wave, at the same positions (like the Click of a player),
+// so they stay in time with the transport.
+// Pauses and seeks are followed at the next rendered block.
+func (t *Transport) Follow(src wave.Wave) wave.Wave {
+	return func(x time.Duration) float64 {
+		t.mu.Lock()
+		playing, position := t.playing, t.position-(t.last-x)
+		if t.end > 0 && t.position >= t.end {
+			playing = false
+		}
+		t.mu.Unlock()
+		if !playing || position < 0 {
+			return 0
+		}
+		return src(position)
+	}
+}
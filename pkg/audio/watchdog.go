@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+type WatchdogEventKind int
+
+const (
+	// WatchdogOverload is reported for every block that took too long to render.
+	WatchdogOverload WatchdogEventKind = iota
+	// WatchdogDegrade is reported when the overload has been sustained and rendering is degraded.
+	WatchdogDegrade
+	// WatchdogRecover is reported when rendering is back to normal.
+	WatchdogRecover
+)
+
+func (k WatchdogEventKind) String() string {
+	switch k {
+	case WatchdogOverload:
+		return "overload"
+	case WatchdogDegrade:
+		return "degrade"
+	case WatchdogRecover:
+		return "recover"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchdogEvent is reported to the application when the real-time render load changes.
+type WatchdogEvent struct {
+	Kind WatchdogEventKind
+	// Load is the render time of the block divided by the duration of the block.
+	// A load above 1 means that the block couldn't be rendered in time (= audible glitch).
+	Load float64
+}
+
+type WatchdogConfig struct {
+	// MaxLoad is the render load above which a block is considered overloaded (default: 0.8).
+	MaxLoad float64
+	// Sustain is the number of consecutive overloaded blocks before rendering is degraded (default: 3).
+	Sustain int
+	// Recover is the number of consecutive blocks under MaxLoad before rendering is restored (default: 50).
+	Recover int
+	// OnEvent is called (from the render goroutine) for every watchdog event, it can be nil.
+	OnEvent func(WatchdogEvent)
+	// Voices are the voice allocators (see voice.Allocator) whose maximum number of voices is halved while rendering is degraded
+	// (stealing their oldest voices), and restored once it recovers.
+	Voices []VoiceLimiter
+}
+
+// VoiceLimiter limits the number of voices played at once, like voice.Allocator.
+type VoiceLimiter interface {
+	MaxVoices() int
+	SetMaxVoices(n int)
+}
+
+// Watchdog monitors the render time of a real-time render loop (see the Watchdog option of players).
+//
+// When blocks take too long to render for too long, the watchdog switches to degraded mode:
+// waves wrapped with Luxury then use their cheaper fallback and voices are stolen, instead of letting the output glitch.
+type Watchdog struct {
+	config     WatchdogConfig
+	degraded   int32 // accessed atomically, 1 when degraded
+	mu         sync.Mutex
+	overloaded int   // consecutive overloaded blocks
+	healthy    int   // consecutive healthy blocks
+	maxVoices  []int // maximum number of voices of each voice limiter before degrading
+}
+
+func NewWatchdog(config WatchdogConfig) *Watchdog {
+	if config.MaxLoad <= 0 {
+		config.MaxLoad = 0.8
+	}
+	if config.Sustain <= 0 {
+		config.Sustain = 3
+	}
+	if config.Recover <= 0 {
+		config.Recover = 50
+	}
+	return &Watchdog{config: config}
+}
+
+// Degraded reports whether rendering is currently degraded.
+func (w *Watchdog) Degraded() bool { return atomic.LoadInt32(&w.degraded) == 1 }
+
+// Observe records the time it took to render a block of the given duration.
+func (w *Watchdog) Observe(renderTime, blockDuration time.Duration) {
+	if blockDuration <= 0 {
+		return
+	}
+	load := float64(renderTime) / float64(blockDuration)
+
+	w.mu.Lock()
+	events := []WatchdogEvent{}
+	if load > w.config.MaxLoad {
+		w.overloaded++
+		w.healthy = 0
+		events = append(events, WatchdogEvent{Kind: WatchdogOverload, Load: load})
+		if w.overloaded >= w.config.Sustain && !w.Degraded() {
+			atomic.StoreInt32(&w.degraded, 1)
+			events = append(events, WatchdogEvent{Kind: WatchdogDegrade, Load: load})
+		}
+	} else {
+		w.healthy++
+		w.overloaded = 0
+		if w.healthy >= w.config.Recover && w.Degraded() {
+			atomic.StoreInt32(&w.degraded, 0)
+			events = append(events, WatchdogEvent{Kind: WatchdogRecover, Load: load})
+		}
+	}
+	w.mu.Unlock()
+
+	for _, e := range events {
+		switch e.Kind {
+		case WatchdogDegrade:
+			w.limitVoices()
+		case WatchdogRecover:
+			w.restoreVoices()
+		}
+	}
+	if w.config.OnEvent != nil {
+		for _, e := range events {
+			w.config.OnEvent(e)
+		}
+	}
+}
+
+// limitVoices halves the maximum number of voices of the voice limiters.
+func (w *Watchdog) limitVoices() {
+	w.maxVoices = make([]int, len(w.config.Voices))
+	for i, v := range w.config.Voices {
+		w.maxVoices[i] = v.MaxVoices()
+		limit := w.maxVoices[i] / 2
+		if limit < 1 {
+			limit = 1
+		}
+		v.SetMaxVoices(limit)
+	}
+}
+
+// restoreVoices restores the maximum number of voices of the voice limiters.
+func (w *Watchdog) restoreVoices() {
+	for i, n := range w.maxVoices {
+		w.config.Voices[i].SetMaxVoices(n)
+	}
+	w.maxVoices = nil
+}
+
+// Frames renders a block of duration d from start (just like Frames) and records how long it took.
+func (w *Watchdog) Frames(src wave.Wave, framesPerSec int, start, d time.Duration) []float64 {
+	began := time.Now()
+	frames := Frames(src, framesPerSec, start, d)
+	w.Observe(time.Since(began), d)
+	return frames
+}
+
+// Luxury tags the source wave as an optional (expensive) effect:
+// the fallback wave is used instead while rendering is degraded.
+// For example, the fallback of a reverb can be its dry input.
+func (w *Watchdog) Luxury(src, fallback wave.Wave) wave.Wave {
+	return func(x time.Duration) float64 {
+		if w.Degraded() {
+			return fallback(x)
+		}
+		return src(x)
+	}
+}
@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+type limiter struct{ max int }
+
+func (l *limiter) MaxVoices() int     { return l.max }
+func (l *limiter) SetMaxVoices(n int) { l.max = n }
+
+func TestWatchdog(t *testing.T) {
+	const block = 10 * time.Millisecond
+	tests := []struct {
+		name      string
+		loads     []float64 // render time of blocks divided by their duration
+		degraded  bool
+		maxVoices int
+		events    []WatchdogEventKind
+	}{
+		{name: "healthy", loads: []float64{0.1, 0.5, 0.8}, maxVoices: 16},
+		{
+			name:      "short overload",
+			loads:     []float64{0.9, 1.2, 0.1, 0.9},
+			maxVoices: 16,
+			events:    []WatchdogEventKind{WatchdogOverload, WatchdogOverload, WatchdogOverload},
+		},
+		{
+			name:      "sustained overload",
+			loads:     []float64{0.9, 0.9, 0.9},
+			degraded:  true,
+			maxVoices: 8,
+			events:    []WatchdogEventKind{WatchdogOverload, WatchdogOverload, WatchdogOverload, WatchdogDegrade},
+		},
+		{
+			name:      "recovery",
+			loads:     []float64{0.9, 0.9, 0.9, 0.1, 0.1},
+			maxVoices: 16,
+			events:    []WatchdogEventKind{WatchdogOverload, WatchdogOverload, WatchdogOverload, WatchdogDegrade, WatchdogRecover},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voices := &limiter{max: 16}
+			events := []WatchdogEventKind{}
+			w := NewWatchdog(WatchdogConfig{
+				Recover: 2,
+				Voices:  []VoiceLimiter{voices},
+				OnEvent: func(e WatchdogEvent) { events = append(events, e.Kind) },
+			})
+			for _, load := range tt.loads {
+				w.Observe(time.Duration(load*float64(block)), block)
+			}
+			if w.Degraded() != tt.degraded {
+				t.Errorf("degraded = %v, want %v", w.Degraded(), tt.degraded)
+			}
+			if voices.max != tt.maxVoices {
+				t.Errorf("max voices = %d, want %d", voices.max, tt.maxVoices)
+			}
+			if len(events) != len(tt.events) {
+				t.Fatalf("events = %v, want %v", events, tt.events)
+			}
+			for i := range events {
+				if events[i] != tt.events[i] {
+					t.Fatalf("events = %v, want %v", events, tt.events)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchdogFrames(t *testing.T) {
+	// A 10ms block (a frame at 100Hz) taking at least 20ms to render, at several positions of a stream
+	slow := func(time.Duration) float64 {
+		time.Sleep(20 * time.Millisecond)
+		return 0
+	}
+	for _, start := range []time.Duration{0, 10 * time.Millisecond, time.Second} {
+		var loads []float64
+		w := NewWatchdog(WatchdogConfig{OnEvent: func(e WatchdogEvent) { loads = append(loads, e.Load) }})
+		if frames := w.Frames(slow, 100, start, 10*time.Millisecond); len(frames) != 1 {
+			t.Fatalf("start %s: %d frames, want 1", start, len(frames))
+		}
+		if len(loads) != 1 || loads[0] < 2 {
+			t.Errorf("start %s: loads = %v, want one overload of at least 2", start, loads)
+		}
+	}
+}
+
+func TestWatchdogLuxury(t *testing.T) {
+	w := NewWatchdog(WatchdogConfig{Sustain: 1})
+	lux := w.Luxury(wave.Const(1), wave.Const(0))
+	if lux(0) != 1 {
+		t.Fatalf("luxury wave = %v before degrading, want 1", lux(0))
+	}
+	w.Observe(time.Second, time.Millisecond)
+	if lux(0) != 0 {
+		t.Fatalf("luxury wave = %v while degraded, want the fallback (0)", lux(0))
+	}
+}
+
+func TestRealtimeRendererObserves(t *testing.T) {
+	var loads []float64
+	w := NewWatchdog(WatchdogConfig{MaxLoad: 1e-12, OnEvent: func(e WatchdogEvent) { loads = append(loads, e.Load) }})
+	config := PlayerConfig{Wave: wave.Const(0), Duration: time.Second, Watchdog: w}
+	if err := config.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	r := newRealtimeRenderer(config)
+	r.render(make([]float64, 512))
+	if len(loads) != 1 || loads[0] <= 0 {
+		t.Fatalf("loads = %v, want the load of the rendered block", loads)
+	}
+}
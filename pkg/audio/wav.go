@@ -0,0 +1,76 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	goaudio "github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// WavWriter encodes frames to a 16-bit WAV file, chunk by chunk.
+// Frames of multi-channel files must be interleaved.
+// Values outside of [-1, 1] are clipped.
+type WavWriter struct {
+	enc *wav.Encoder
+	buf *goaudio.IntBuffer
+}
+
+func NewWavWriter(w io.WriteSeeker, sampleRate, channels int) (*WavWriter, error) {
+	if w == nil {
+		return nil, errors.New("no io.WriteSeeker was provided")
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("invalid number of channels: %d", channels)
+	}
+	return &WavWriter{
+		enc: wav.NewEncoder(w, sampleRate, 16, channels, 1),
+		buf: &goaudio.IntBuffer{
+			Format:         &goaudio.Format{NumChannels: channels, SampleRate: sampleRate},
+			SourceBitDepth: 16,
+		},
+	}, nil
+}
+
+// Write encodes the frames.
+func (w *WavWriter) Write(frames []float64) error {
+	w.buf.Data = w.buf.Data[:0]
+	for _, v := range frames {
+		if v > 1 {
+			v = 1
+		} else if v < -1 {
+			v = -1
+		}
+		w.buf.Data = append(w.buf.Data, int(v*32767))
+	}
+	return w.enc.Write(w.buf)
+}
+
+// Close finalizes the WAV header, it doesn't close the underlying writer.
+func (w *WavWriter) Close() error { return w.enc.Close() }
+
+// Encodes frames to a 16-bit WAV file.
+func ExportWav(filepath string, frames []float64, sampleRate, channels int) error {
+	f, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("create file: %s: %w", filepath, err)
+	}
+	defer f.Close()
+
+	w, err := NewWavWriter(f, sampleRate, channels)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(frames); err != nil {
+		return fmt.Errorf("encode frames: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("encode WAV header: %w", err)
+	}
+	return f.Close()
+}
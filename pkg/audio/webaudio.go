@@ -0,0 +1,88 @@
+//go:build js && wasm
+
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"syscall/js"
+)
+
+// WebAudioPlayer plays audio in a browser (when compiled with GOOS=js GOARCH=wasm).
+// Frames are rendered in real time, when the Web Audio API asks for them:
+// the player defines a global JavaScript function that fills the output buffers of an audio node
+// (one Float32Array per channel), for example with a ScriptProcessorNode:
+//
+//	const ctx = new AudioContext({sampleRate: 44100});
+//	const node = ctx.createScriptProcessor(1024, 0, 1);
+//	node.onaudioprocess = (e) => ziqRender(e.outputBuffer.getChannelData(0));
+//	node.connect(ctx.destination);
+//
+// An AudioWorklet runs on another thread, so it should receive the buffers filled
+// on the main thread through its message port (or a shared ring buffer).
+type WebAudioPlayer struct {
+	*realtimeRenderer
+	name string
+}
+
+var _ Player = (*WebAudioPlayer)(nil)
+
+// NewWebAudioPlayer creates a player rendering frames through the global JavaScript function with the given name
+// (defined while playing).
+func NewWebAudioPlayer(config PlayerConfig, name string) (*WebAudioPlayer, error) {
+	if err := config.setDefaults(); err != nil {
+		return nil, err
+	}
+	if config.Device != "" {
+		return nil, errors.New("the output device is chosen by the browser")
+	}
+	if config.RecordDir != "" {
+		return nil, errors.New("recording isn't supported in browsers")
+	}
+	if name == "" {
+		return nil, errors.New("no JavaScript function name was provided")
+	}
+	return &WebAudioPlayer{realtimeRenderer: newRealtimeRenderer(config), name: name}, nil
+}
+
+func (p *WebAudioPlayer) PlayContext(ctx context.Context) error { return playContext(ctx, p) }
+
+// Play defines the render function and waits until playback is done.
+// The function fills the buffers with silence once playback is done (or while paused).
+func (p *WebAudioPlayer) Play() error {
+	reportProgress(p, p.config)
+	channels := p.config.Channels
+	frames, encoded := []float64{}, []byte{}
+	fn := js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 {
+			return nil
+		}
+		count := args[0].Get("length").Int()
+		if cap(frames) < count*channels {
+			frames, encoded = make([]float64, count*channels), make([]byte, 4*count)
+		}
+		frames = frames[:count*channels]
+		p.render(frames)
+
+		// Copy each channel to its (planar) output buffer, as f32le bytes
+		for c, arg := range args {
+			if c >= channels {
+				break
+			}
+			for i := 0; i < count; i++ {
+				binary.LittleEndian.PutUint32(encoded[4*i:], math.Float32bits(float32(frames[i*channels+c])))
+			}
+			bytes := js.Global().Get("Uint8Array").New(arg.Get("buffer"), arg.Get("byteOffset"), 4*count)
+			js.CopyBytesToJS(bytes, encoded[:4*count])
+		}
+		return nil
+	})
+	js.Global().Set(p.name, fn)
+
+	<-p.Done()
+	js.Global().Delete(p.name)
+	fn.Release()
+	return nil
+}
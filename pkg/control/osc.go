@@ -0,0 +1,241 @@
+package control
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Mapping maps an OSC address to a named parameter.
+type Mapping struct {
+	// Address is the OSC address pattern of the message (for example: "/synth/cutoff").
+	Address string
+	// Param is the name of the controlled parameter (for example: "cutoff").
+	Param string
+	// Min and Max are the parameter values for received values of 0 and 1 (faders of most control surfaces).
+	// When both are 0, received values are used as is.
+	Min, Max float64
+	// Initial is the value of the parameter until a message is received.
+	Initial float64
+	// Smoothing is the time it takes for the parameter to (almost) reach a new value,
+	// to avoid audible steps when a fader is moved (default: 20ms).
+	Smoothing time.Duration
+}
+
+// OSCServer listens for OSC (Open Sound Control) messages over UDP
+// and updates the mapped parameters, so software like TouchOSC, Max
+// or SuperCollider can control a running performance.
+type OSCServer struct {
+	conn     net.PacketConn
+	mu       sync.Mutex
+	params   *wave.ParamSet
+	mappings map[string][]Mapping
+	handlers map[string][]func(args []any)
+}
+
+// ListenOSC starts listening on the provided UDP address (for example: ":9000").
+// Messages are handled once Serve is called.
+func ListenOSC(addr string, mappings ...Mapping) (*OSCServer, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen UDP: %s: %w", addr, err)
+	}
+
+	s := &OSCServer{
+		conn:     conn,
+		params:   wave.NewParamSet(),
+		mappings: map[string][]Mapping{},
+		handlers: map[string][]func(args []any){},
+	}
+	for _, m := range mappings {
+		s.mappings[m.Address] = append(s.mappings[m.Address], m)
+		s.params.Add(m.Param, m.Initial, m.Smoothing)
+	}
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *OSCServer) Addr() net.Addr { return s.conn.LocalAddr() }
+
+// Close stops the server.
+func (s *OSCServer) Close() error { return s.conn.Close() }
+
+// Handle registers a callback for the messages sent to an address (for example: "/transport/stop").
+// Arguments are int32, int64, float32, float64, string, []byte, bool or nil values.
+func (s *OSCServer) Handle(address string, handler func(args []any)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[address] = append(s.handlers[address], handler)
+}
+
+// Param returns a wave producing the smoothed value of the named parameter (see wave.ParamSet.Wave).
+func (s *OSCServer) Param(name string) wave.Wave { return s.params.Wave(name) }
+
+// Serve handles incoming messages until the server is closed.
+// Malformed packets are ignored.
+func (s *OSCServer) Serve() error {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read UDP packet: %w", err)
+		}
+		_ = s.handlePacket(buf[:n])
+	}
+}
+
+func (s *OSCServer) handlePacket(packet []byte) error {
+	if bytes.HasPrefix(packet, []byte("#bundle\x00")) {
+		if len(packet) < 16 {
+			return errors.New("missing bundle time tag")
+		}
+		// Skip the bundle header (8 bytes) and time tag (8 bytes), then handle each element
+		for rest := packet[16:]; len(rest) >= 4; {
+			size := int(binary.BigEndian.Uint32(rest))
+			if size < 0 || 4+size > len(rest) {
+				return errors.New("invalid bundle element size")
+			}
+			if err := s.handlePacket(rest[4 : 4+size]); err != nil {
+				return err
+			}
+			rest = rest[4+size:]
+		}
+		return nil
+	}
+
+	address, args, err := parseMessage(packet)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	handlers := s.handlers[address]
+	if received, ok := firstNumber(args); ok {
+		for _, m := range s.mappings[address] {
+			value := received
+			if m.Min != 0 || m.Max != 0 {
+				value = m.Min + (m.Max-m.Min)*received
+			}
+			s.params.Set(m.Param, value)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, h := range handlers {
+		h(args)
+	}
+	return nil
+}
+
+func parseMessage(packet []byte) (string, []any, error) {
+	address, rest, err := readString(packet)
+	if err != nil {
+		return "", nil, fmt.Errorf("read address: %w", err)
+	}
+	if len(rest) == 0 {
+		return address, nil, nil // type tag string is optional in old implementations
+	}
+	tags, rest, err := readString(rest)
+	if err != nil || len(tags) == 0 || tags[0] != ',' {
+		return "", nil, errors.New("invalid type tag string")
+	}
+
+	args := []any{}
+	for _, tag := range tags[1:] {
+		switch tag {
+		case 'i', 'f':
+			if len(rest) < 4 {
+				return "", nil, errors.New("missing argument data")
+			}
+			v := binary.BigEndian.Uint32(rest)
+			if tag == 'i' {
+				args = append(args, int32(v))
+			} else {
+				args = append(args, math.Float32frombits(v))
+			}
+			rest = rest[4:]
+		case 'h', 'd':
+			if len(rest) < 8 {
+				return "", nil, errors.New("missing argument data")
+			}
+			v := binary.BigEndian.Uint64(rest)
+			if tag == 'h' {
+				args = append(args, int64(v))
+			} else {
+				args = append(args, math.Float64frombits(v))
+			}
+			rest = rest[8:]
+		case 's', 'S':
+			var str string
+			str, rest, err = readString(rest)
+			if err != nil {
+				return "", nil, fmt.Errorf("read string argument: %w", err)
+			}
+			args = append(args, str)
+		case 'b':
+			if len(rest) < 4 {
+				return "", nil, errors.New("missing blob size")
+			}
+			size := int(binary.BigEndian.Uint32(rest))
+			padded := 4 + (size+3)/4*4
+			if size < 0 || padded > len(rest) {
+				return "", nil, errors.New("invalid blob size")
+			}
+			args = append(args, append([]byte(nil), rest[4:4+size]...))
+			rest = rest[padded:]
+		case 'T':
+			args = append(args, true)
+		case 'F':
+			args = append(args, false)
+		case 'N', 'I':
+			args = append(args, nil)
+		default:
+			return "", nil, fmt.Errorf("unsupported argument type: %q", tag)
+		}
+	}
+	return address, args, nil
+}
+
+// readString reads a null-terminated string padded to 4 bytes.
+func readString(data []byte) (string, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, errors.New("unterminated string")
+	}
+	padded := (end + 4) / 4 * 4
+	if padded > len(data) {
+		padded = len(data)
+	}
+	return string(data[:end]), data[padded:], nil
+}
+
+// firstNumber returns the first numeric (or boolean) argument as a float64.
+func firstNumber(args []any) (float64, bool) {
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case int32:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		case float32:
+			return float64(v), true
+		case float64:
+			return v, true
+		case bool:
+			if v {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,138 @@
+package control
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// oscString encodes a null-terminated string padded to 4 bytes.
+func oscString(s string) []byte {
+	out := append([]byte(s), 0)
+	for len(out)%4 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func oscUint32(v uint32) []byte {
+	out := make([]byte, 4)
+	binary.BigEndian.PutUint32(out, v)
+	return out
+}
+
+func oscUint64(v uint64) []byte {
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, v)
+	return out
+}
+
+func oscMessage(address, tags string, data ...[]byte) []byte {
+	out := oscString(address)
+	if tags != "" {
+		out = append(out, oscString(tags)...)
+	}
+	for _, d := range data {
+		out = append(out, d...)
+	}
+	return out
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		address string
+		args    []any
+		wantErr bool
+	}{
+		{name: "no type tags", in: oscString("/play"), address: "/play"},
+		{name: "no arguments", in: oscMessage("/play", ","), address: "/play", args: []any{}},
+		{
+			name:    "int and float",
+			in:      oscMessage("/synth/cutoff", ",if", oscUint32(7), oscUint32(math.Float32bits(0.5))),
+			address: "/synth/cutoff",
+			args:    []any{int32(7), float32(0.5)},
+		},
+		{
+			name:    "int64 and double",
+			in:      oscMessage("/a", ",hd", oscUint32(0), oscUint32(42), oscUint64(math.Float64bits(-1.25))),
+			address: "/a",
+			args:    []any{int64(42), -1.25},
+		},
+		{
+			name:    "string and blob",
+			in:      oscMessage("/a", ",sb", oscString("hello"), oscUint32(3), []byte{1, 2, 3, 0}),
+			address: "/a",
+			args:    []any{"hello", []byte{1, 2, 3}},
+		},
+		{name: "booleans and nil", in: oscMessage("/a", ",TFN"), address: "/a", args: []any{true, false, nil}},
+		{name: "unterminated address", in: []byte("/abc"), wantErr: true},
+		{name: "type tags without comma", in: oscMessage("/a", "i", oscUint32(1)), wantErr: true},
+		{name: "missing int", in: oscMessage("/a", ",i"), wantErr: true},
+		{name: "missing double", in: oscMessage("/a", ",d", oscUint32(1)), wantErr: true},
+		{name: "blob too long", in: oscMessage("/a", ",b", oscUint32(100), []byte{1, 2, 3, 4}), wantErr: true},
+		{name: "unsupported type", in: oscMessage("/a", ",x"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, args, err := parseMessage(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+			if address != tt.address || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("parseMessage = %q, %#v, want %q, %#v", address, args, tt.address, tt.args)
+			}
+		})
+	}
+}
+
+func TestOSCServer(t *testing.T) {
+	s, err := ListenOSC("127.0.0.1:0", Mapping{Address: "/cutoff", Param: "cutoff", Min: 100, Max: 1100, Initial: 100, Smoothing: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve()
+
+	received := make(chan []any, 1)
+	s.Handle("/stop", func(args []any) { received <- args })
+	cutoff := s.Param("cutoff")
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A bundle with a mapped value and a handled message
+	bundle := append(oscString("#bundle"), make([]byte, 8)...)
+	for _, msg := range [][]byte{
+		oscMessage("/cutoff", ",f", oscUint32(math.Float32bits(0.5))),
+		oscMessage("/stop", ",T"),
+	} {
+		bundle = append(bundle, oscUint32(uint32(len(msg)))...)
+		bundle = append(bundle, msg...)
+	}
+	if _, err := conn.Write(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case args := <-received:
+		if !reflect.DeepEqual(args, []any{true}) {
+			t.Errorf("handler arguments = %#v, want [true]", args)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message wasn't handled")
+	}
+	if got := cutoff(time.Second); math.Abs(got-600) > 1e-6 {
+		t.Errorf("cutoff = %v, want 600", got)
+	}
+	if got := s.Param("unknown")(0); got != 0 {
+		t.Errorf("unknown param = %v, want 0", got)
+	}
+}
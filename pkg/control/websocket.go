@@ -0,0 +1,334 @@
+package control
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Transport is a playback controlled by a WebSocket server (for example: *audio.Transport).
+type Transport interface {
+	Play()
+	Pause()
+	Seek(position time.Duration)
+	Position() time.Duration
+	Playing() bool
+}
+
+// Message is a JSON message exchanged with the clients of a WebSocket server.
+//
+// Clients send:
+//
+//	{"type": "set", "param": "cutoff", "value": 800}
+//	{"type": "play"}, {"type": "pause"} or {"type": "seek", "position": 90.5}
+//	{"type": "state"} (to request the current state)
+//
+// The server answers every message (and greets new clients) with the current state,
+// which is also sent to all other clients when it changes:
+//
+//	{"type": "state", "params": {"cutoff": 800}, "playing": true, "position": 12.5}
+//
+// Positions are in seconds.
+type Message struct {
+	Type     string             `json:"type"`
+	Param    string             `json:"param,omitempty"`
+	Value    float64            `json:"value"`
+	Position float64            `json:"position"`
+	Params   map[string]float64 `json:"params,omitempty"`
+	Playing  bool               `json:"playing"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// Maximum size of a received message.
+const maxWebSocketMessage = 1 << 16
+
+// Maximum duration of a write to a client, slower clients are disconnected.
+const webSocketWriteTimeout = time.Second
+
+// WebSocketServer exposes named parameters and transport controls as JSON messages over WebSocket,
+// so a browser-based control surface can tweak a running patch.
+// It implements http.Handler.
+//
+// Browsers let any web page open WebSocket connections (to localhost too),
+// so connections from web pages served by other hosts are rejected, unless their origin is allowed (see Origins).
+type WebSocketServer struct {
+	// Origins are the origins of other web pages allowed to connect (for example: "http://localhost:3000").
+	// Pages served by the host of the server are always allowed, and so are clients that aren't browsers
+	// (they don't send an Origin header).
+	Origins []string
+
+	params    map[string]*wave.Param
+	transport Transport
+
+	mu    sync.Mutex
+	conns map[*wsConn]struct{}
+}
+
+// NewWebSocketServer creates a server controlling the parameters (by name) and the transport (nil if there is none).
+func NewWebSocketServer(params map[string]*wave.Param, transport Transport) *WebSocketServer {
+	return &WebSocketServer{params: params, transport: transport, conns: map[*wsConn]struct{}{}}
+}
+
+// ServeHTTP upgrades the connection to WebSocket and handles its messages until it is closed.
+func (s *WebSocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer c.conn.Close()
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+
+	if err := c.writeJSON(s.state()); err != nil {
+		return
+	}
+	for {
+		data, err := c.readMessage()
+		if err != nil {
+			return
+		}
+		msg := Message{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			_ = c.writeJSON(Message{Type: "error", Error: "invalid JSON message"})
+			continue
+		}
+		changed, err := s.handle(msg)
+		if err != nil {
+			_ = c.writeJSON(Message{Type: "error", Error: err.Error()})
+			continue
+		}
+		if changed {
+			s.broadcast(s.state())
+		} else {
+			_ = c.writeJSON(s.state())
+		}
+	}
+}
+
+// allowOrigin reports whether the origin of a request is the host of the server, or one of the allowed origins.
+func (s *WebSocketServer) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range s.Origins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// handle applies a message, it reports whether the state changed.
+func (s *WebSocketServer) handle(msg Message) (bool, error) {
+	switch msg.Type {
+	case "state":
+		return false, nil
+	case "set":
+		p, ok := s.params[msg.Param]
+		if !ok {
+			return false, fmt.Errorf("unknown parameter: %q", msg.Param)
+		}
+		p.Set(msg.Value)
+		return true, nil
+	case "play", "pause", "seek":
+		if s.transport == nil {
+			return false, errors.New("no transport to control")
+		}
+		switch msg.Type {
+		case "play":
+			s.transport.Play()
+		case "pause":
+			s.transport.Pause()
+		case "seek":
+			s.transport.Seek(time.Duration(msg.Position * float64(time.Second)))
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown message type: %q", msg.Type)
+	}
+}
+
+func (s *WebSocketServer) state() Message {
+	msg := Message{Type: "state", Params: map[string]float64{}}
+	for name, p := range s.params {
+		msg.Params[name] = p.Get()
+	}
+	if s.transport != nil {
+		msg.Playing = s.transport.Playing()
+		msg.Position = s.transport.Position().Seconds()
+	}
+	return msg
+}
+
+// broadcast sends a message to all clients, clients that can't receive it are disconnected.
+func (s *WebSocketServer) broadcast(msg Message) {
+	s.mu.Lock()
+	conns := make([]*wsConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		if err := c.writeJSON(msg); err != nil {
+			c.conn.Close() // ends its read loop
+		}
+	}
+}
+
+// wsConn is a server-side WebSocket connection (RFC 6455), limited to what the control messages need.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	wmu  sync.Mutex
+}
+
+// Magic value used to compute the accept key of the handshake.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, errors.New("expected a WebSocket upgrade request")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key header")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection can't be upgraded")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijack connection: %w", err)
+	}
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	_, err = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// readMessage returns the payload of the next text or binary message,
+// control frames (ping and close) are answered on the way.
+func (c *wsConn) readMessage() ([]byte, error) {
+	message := []byte{}
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(c.r, header); err != nil {
+			return nil, err
+		}
+		fin, opcode := header[0]&0x80 != 0, header[0]&0x0F
+		masked, size := header[1]&0x80 != 0, uint64(header[1]&0x7F)
+		switch size {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(c.r, ext); err != nil {
+				return nil, err
+			}
+			size = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(c.r, ext); err != nil {
+				return nil, err
+			}
+			size = binary.BigEndian.Uint64(ext)
+		}
+		if !masked {
+			return nil, errors.New("client frames should be masked")
+		}
+		if size > maxWebSocketMessage || uint64(len(message))+size > maxWebSocketMessage {
+			return nil, errors.New("message too large")
+		}
+		mask := make([]byte, 4)
+		if _, err := io.ReadFull(c.r, mask); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case 0x8: // close
+			_ = c.writeFrame(0x8, payload)
+			return nil, io.EOF
+		case 0x9: // ping
+			if err := c.writeFrame(0xA, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case 0xA: // pong
+			continue
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(0x1, data)
+}
+
+// writeFrame writes a single (unmasked, final) frame, within the write timeout.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	frame = append(frame, payload...)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
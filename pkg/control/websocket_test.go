@@ -0,0 +1,268 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// clientFrame encodes a frame sent by a client (masked, unless masked is false).
+func clientFrame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(n))
+		frame = append(append(frame, maskBit|127), ext...)
+	}
+	if !masked {
+		return append(frame, payload...)
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      [][]byte
+		want    string
+		replies []byte // frames written by the server
+		wantErr bool
+	}{
+		{name: "text", in: [][]byte{clientFrame(true, 0x1, []byte(`{"type":"state"}`), true)}, want: `{"type":"state"}`},
+		{name: "extended length", in: [][]byte{clientFrame(true, 0x1, bytes.Repeat([]byte("a"), 300), true)}, want: strings.Repeat("a", 300)},
+		{
+			name: "fragmented",
+			in:   [][]byte{clientFrame(false, 0x1, []byte("hel"), true), clientFrame(true, 0x0, []byte("lo"), true)},
+			want: "hello",
+		},
+		{
+			name:    "ping between fragments",
+			in:      [][]byte{clientFrame(false, 0x1, []byte("hel"), true), clientFrame(true, 0x9, []byte("p"), true), clientFrame(true, 0x0, []byte("lo"), true)},
+			want:    "hello",
+			replies: []byte{0x8A, 1, 'p'},
+		},
+		{name: "close", in: [][]byte{clientFrame(true, 0x8, nil, true)}, replies: []byte{0x88, 0}, wantErr: true},
+		{name: "unmasked", in: [][]byte{clientFrame(true, 0x1, []byte("a"), false)}, wantErr: true},
+		{name: "too large", in: [][]byte{clientFrame(true, 0x1, make([]byte, maxWebSocketMessage+1), true)}, wantErr: true},
+		{name: "truncated", in: [][]byte{clientFrame(true, 0x1, []byte("hello"), true)[:5]}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+			replies := make(chan []byte, 1)
+			go func() {
+				out, _ := io.ReadAll(client)
+				replies <- out
+			}()
+			go func() {
+				for _, f := range tt.in {
+					if _, err := client.Write(f); err != nil {
+						return
+					}
+				}
+			}()
+
+			// Truncated frames never end (pipes can't be half-closed)
+			_ = server.SetReadDeadline(time.Now().Add(time.Second))
+			c := &wsConn{conn: server, r: bufio.NewReader(server)}
+			got, err := c.readMessage()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+			server.Close()
+			if out := <-replies; !bytes.Equal(out, tt.replies) {
+				t.Errorf("replies = % x, want % x", out, tt.replies)
+			}
+		})
+	}
+}
+
+func TestWriteFrameHeader(t *testing.T) {
+	tests := []struct {
+		size   int
+		header []byte
+	}{
+		{size: 0, header: []byte{0x81, 0}},
+		{size: 125, header: []byte{0x81, 125}},
+		{size: 126, header: []byte{0x81, 126, 0, 126}},
+		{size: 0xFFFF, header: []byte{0x81, 126, 0xFF, 0xFF}},
+		{size: 0x10000, header: []byte{0x81, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	}
+	for _, tt := range tests {
+		server, client := net.Pipe()
+		out := make(chan []byte, 1)
+		go func() {
+			b, _ := io.ReadAll(client)
+			out <- b
+		}()
+		c := &wsConn{conn: server}
+		if err := c.writeFrame(0x1, make([]byte, tt.size)); err != nil {
+			t.Fatal(err)
+		}
+		server.Close()
+		got := <-out
+		if len(got) != len(tt.header)+tt.size || !bytes.Equal(got[:len(tt.header)], tt.header) {
+			t.Errorf("%d bytes: frame starts with % x (%d bytes), want % x", tt.size, got[:len(tt.header)], len(got), tt.header)
+		}
+	}
+}
+
+type fakeTransport struct {
+	playing  bool
+	position time.Duration
+}
+
+func (t *fakeTransport) Play()                   { t.playing = true }
+func (t *fakeTransport) Pause()                  { t.playing = false }
+func (t *fakeTransport) Seek(p time.Duration)    { t.position = p }
+func (t *fakeTransport) Position() time.Duration { return t.position }
+func (t *fakeTransport) Playing() bool           { return t.playing }
+
+// dialWebSocket opens a WebSocket connection to the test server, it returns the status code of the handshake.
+func dialWebSocket(t *testing.T, srv *httptest.Server, origin string) (net.Conn, *bufio.Reader, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode == http.StatusSwitchingProtocols && res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("accept key = %q", res.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return conn, r, res.StatusCode
+}
+
+// readServerMessage reads a (small, unfragmented) text frame sent by the server.
+func readServerMessage(t *testing.T, r *bufio.Reader) map[string]any {
+	t.Helper()
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1])
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	msg := map[string]any{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("invalid JSON: %s", payload)
+	}
+	return msg
+}
+
+func TestWebSocketServerOrigin(t *testing.T) {
+	s := NewWebSocketServer(nil, nil)
+	s.Origins = []string{"http://localhost:3000"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{origin: "", status: http.StatusSwitchingProtocols},
+		{origin: srv.URL, status: http.StatusSwitchingProtocols},
+		{origin: "http://localhost:3000", status: http.StatusSwitchingProtocols},
+		{origin: "https://evil.example", status: http.StatusForbidden},
+		{origin: "http://localhost:3001", status: http.StatusForbidden},
+		{origin: "null", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		conn, _, status := dialWebSocket(t, srv, tt.origin)
+		conn.Close()
+		if status != tt.status {
+			t.Errorf("origin %q: status = %d, want %d", tt.origin, status, tt.status)
+		}
+	}
+}
+
+func TestWebSocketServerState(t *testing.T) {
+	transport := &fakeTransport{}
+	s := NewWebSocketServer(map[string]*wave.Param{"cutoff": wave.NewParam(0, 0)}, transport)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	conn, r, status := dialWebSocket(t, srv, "")
+	defer conn.Close()
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", status)
+	}
+
+	// The state of a paused transport at 0 explicitly says so
+	greeting := readServerMessage(t, r)
+	for field, want := range map[string]any{"type": "state", "playing": false, "position": 0.0} {
+		if got, ok := greeting[field]; !ok || got != want {
+			t.Errorf("greeting %s = %v (present: %v), want %v", field, got, ok, want)
+		}
+	}
+
+	tests := []struct {
+		send  string
+		field string
+		want  any
+	}{
+		{send: `{"type":"set","param":"cutoff","value":800}`, field: "params", want: map[string]any{"cutoff": 800.0}},
+		{send: `{"type":"play"}`, field: "playing", want: true},
+		{send: `{"type":"seek","position":1.5}`, field: "position", want: 1.5},
+		{send: `{"type":"set","param":"gain","value":1}`, field: "error", want: `unknown parameter: "gain"`},
+		{send: `not json`, field: "error", want: "invalid JSON message"},
+	}
+	for _, tt := range tests {
+		if _, err := conn.Write(clientFrame(true, 0x1, []byte(tt.send), true)); err != nil {
+			t.Fatal(err)
+		}
+		msg := readServerMessage(t, r)
+		got, _ := json.Marshal(msg[tt.field])
+		want, _ := json.Marshal(tt.want)
+		if !bytes.Equal(got, want) {
+			t.Errorf("after %s: %s = %s, want %s", tt.send, tt.field, got, want)
+		}
+	}
+}
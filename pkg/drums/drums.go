@@ -0,0 +1,186 @@
+// Package drums plays drum kits: named pads (kick, snare, hats...) triggered by patterns or hits.
+package drums
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/pattern"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Duration of the fade out of choked hits (avoids clicks).
+const chokeFade = 5 * time.Millisecond
+
+// Pad is a sound of a drum kit, played from its beginning on each hit.
+type Pad struct {
+	// Sound is a sample (see SamplePad) or a synthesized sound (see Kick, Snare and HiHat).
+	Sound wave.Wave
+	// Length is how long a hit plays (default: 2s).
+	Length time.Duration
+	// Gain is the level of the pad in decibels (default: 0dB).
+	Gain float64
+	// Pitch transposes the pad in semitones (by playing it faster or slower, which also changes its length).
+	Pitch float64
+	// Velocity maps the velocity of hits to their gain (default: wave.Linear),
+	// for example wave.Exponential makes soft hits much quieter.
+	Velocity wave.Curve
+	// Choke is the choke group of the pad: a hit stops the hits of the other pads of the group that are still playing,
+	// for example to let a closed hi-hat stop an open one. 0 is no group.
+	Choke int
+}
+
+// SamplePad returns a pad playing a WAV file (until its end).
+func SamplePad(filepath string) (Pad, error) {
+	c, err := wave.ImportWavClip(filepath)
+	if err != nil {
+		return Pad{}, fmt.Errorf("import sample: %w", err)
+	}
+	return Pad{Sound: c.Wave, Length: c.Duration}, nil
+}
+
+// hit returns the sound of a hit and its length.
+func (p Pad) hit(velocity float64) (wave.Wave, time.Duration) {
+	length := p.Length
+	if length <= 0 {
+		length = 2 * time.Second
+	}
+	speed := math.Pow(2, p.Pitch/12)
+	curve := p.Velocity
+	if curve == nil {
+		curve = wave.Linear
+	}
+	if velocity <= 0 {
+		velocity = 1
+	}
+	gain := wave.DecibelsToGain(p.Gain) * curve(math.Min(1, velocity))
+	return func(x time.Duration) float64 {
+		return p.Sound(time.Duration(float64(x)*speed)) * gain
+	}, time.Duration(float64(length) / speed)
+}
+
+// Kit maps the names of its pads (for example: "kick", "snare", "hat") to their sounds.
+type Kit map[string]Pad
+
+// Hit triggers a pad of a kit.
+type Hit struct {
+	Pad string
+	At  time.Duration
+	// Velocity is the strength of the hit, between 0 and 1 (scaling its level),
+	// like the velocity of pattern steps, 0 is the default velocity (1).
+	Velocity float64
+}
+
+// Sequence returns the hits of patterns (by pad name) played from the beginning, repeat times (at least once),
+// with the velocities of their steps (see Kit.Play).
+func Sequence(patterns map[string]pattern.Pattern, step time.Duration, repeat int) []Hit {
+	if repeat <= 0 {
+		repeat = 1
+	}
+	hits := []Hit{}
+	for pad, p := range patterns {
+		for r := 0; r < repeat; r++ {
+			offset := time.Duration(r) * p.Duration(step)
+			for i, s := range p {
+				if !s.Rest && !s.Tie {
+					hits = append(hits, Hit{Pad: pad, At: offset + time.Duration(i)*step, Velocity: s.Level()})
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// Play returns a wave playing the hits (in any order) with the pads of the kit.
+// The sounds of simultaneous hits are summed, and hits of unknown pads are ignored.
+func (k Kit) Play(hits []Hit) wave.Wave {
+	type voice struct {
+		start, end time.Duration
+		choked     time.Duration // position at which the voice is choked (fading out), 0 if it isn't
+		wave       wave.Wave
+	}
+
+	hits = append([]Hit(nil), hits...)
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].At < hits[j].At })
+	voices := []voice{}
+	lastInGroup := map[int]int{} // index of the last voice of each choke group
+	longest := time.Duration(0)
+	for _, h := range hits {
+		pad, ok := k[h.Pad]
+		if !ok || pad.Sound == nil {
+			continue
+		}
+		w, length := pad.hit(h.Velocity)
+		if pad.Choke != 0 {
+			if i, ok := lastInGroup[pad.Choke]; ok && voices[i].end > h.At && voices[i].start < h.At {
+				voices[i].choked = h.At
+				if voices[i].end > h.At+chokeFade {
+					voices[i].end = h.At + chokeFade
+				}
+			}
+			lastInGroup[pad.Choke] = len(voices)
+		}
+		voices = append(voices, voice{start: h.At, end: h.At + length, wave: w})
+		if length > longest {
+			longest = length
+		}
+	}
+
+	return func(x time.Duration) float64 {
+		// Only voices that started before x (and not earlier than the longest voice) can be playing.
+		i := sort.Search(len(voices), func(i int) bool { return voices[i].start > x })
+		sum := 0.0
+		for i--; i >= 0 && voices[i].start >= x-longest; i-- {
+			v := voices[i]
+			if x >= v.end {
+				continue
+			}
+			out := v.wave(x - v.start)
+			if v.choked > 0 && x > v.choked {
+				out *= 1 - float64(x-v.choked)/float64(chokeFade)
+			}
+			sum += out
+		}
+		return sum
+	}
+}
+
+// Kick is a synthesized bass drum: a sine sweeping down from 150Hz to 50Hz.
+func Kick() Pad {
+	const low, high, sweep, decay = 50.0, 150.0, 0.04, 0.3
+	return Pad{Length: 1500 * time.Millisecond, Sound: func(x time.Duration) float64 {
+		t := x.Seconds()
+		if t < 0 {
+			return 0
+		}
+		phase := low*t + (high-low)*sweep*(1-math.Exp(-t/sweep))
+		return math.Sin(2*math.Pi*phase) * math.Exp(-t/decay)
+	}}
+}
+
+// Snare is a synthesized snare drum: a short tone at 180Hz with a burst of noise.
+func Snare() Pad {
+	noise := wave.Noise(wave.Const(1), wave.NextSeed())
+	return Pad{Length: time.Second, Sound: func(x time.Duration) float64 {
+		t := x.Seconds()
+		if t < 0 {
+			return 0
+		}
+		return 0.5*math.Sin(2*math.Pi*180*t)*math.Exp(-t/0.05) + 0.6*noise(x)*math.Exp(-t/0.12)
+	}}
+}
+
+// HiHat is a synthesized hi-hat: noise decaying in about decay
+// (for example: 50ms for a closed hi-hat and 400ms for an open one, in the same choke group).
+func HiHat(decay time.Duration) Pad {
+	noise := wave.Noise(wave.Const(0.5), wave.NextSeed())
+	tau := decay.Seconds() / 4.6
+	return Pad{Length: decay, Sound: func(x time.Duration) float64 {
+		if x < 0 || tau <= 0 {
+			return 0
+		}
+		return noise(x) * math.Exp(-x.Seconds()/tau)
+	}}
+}
@@ -0,0 +1,51 @@
+package drums
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/pattern"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestKitPlayVelocity(t *testing.T) {
+	kit := Kit{"pad": Pad{Sound: wave.Const(1), Length: time.Second}}
+	tests := []struct {
+		velocity float64
+		want     float64
+	}{
+		{velocity: 0, want: 1}, // the default velocity, like pattern steps
+		{velocity: 0.5, want: 0.5},
+		{velocity: 1, want: 1},
+		{velocity: 2, want: 1},
+		{velocity: -1, want: 1},
+	}
+	for _, tt := range tests {
+		w := kit.Play([]Hit{{Pad: "pad", At: 0, Velocity: tt.velocity}})
+		if got := w(500 * time.Millisecond); got != tt.want {
+			t.Errorf("hit with a velocity of %v = %v, want %v", tt.velocity, got, tt.want)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	const step = 100 * time.Millisecond
+	hits := Sequence(map[string]pattern.Pattern{
+		"kick": pattern.MustParseTriggers("x-9-"),
+		"hat":  pattern.MustParseTriggers("-3"),
+	}, step, 2)
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].At < hits[j].At })
+	want := []Hit{
+		{Pad: "kick", At: 0, Velocity: 1},
+		{Pad: "hat", At: 100 * time.Millisecond, Velocity: 3.0 / 9},
+		{Pad: "kick", At: 200 * time.Millisecond, Velocity: 1},
+		{Pad: "hat", At: 300 * time.Millisecond, Velocity: 3.0 / 9},
+		{Pad: "kick", At: 400 * time.Millisecond, Velocity: 1},
+		{Pad: "kick", At: 600 * time.Millisecond, Velocity: 1},
+	}
+	if !reflect.DeepEqual(hits, want) {
+		t.Errorf("hits = %+v, want %+v", hits, want)
+	}
+}
@@ -0,0 +1,59 @@
+package drums
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Fade out at the end of slices (avoids clicks when the next hit is cut).
+const sliceFade = 3 * time.Millisecond
+
+// Slice cuts a loop at the markers (positions in the loop, in any order) into clips,
+// from the beginning of the loop to its end: n markers inside the loop make n+1 slices
+// (n when a marker is at the beginning).
+// Each slice plays from its marker to the next one, and fades out quickly at its end.
+func Slice(loop wave.Clip, markers []time.Duration) []wave.Clip {
+	markers = append([]time.Duration(nil), markers...)
+	sort.Slice(markers, func(i, j int) bool { return markers[i] < markers[j] })
+	cuts := []time.Duration{0}
+	for _, m := range markers {
+		if m > cuts[len(cuts)-1] && m < loop.Duration {
+			cuts = append(cuts, m)
+		}
+	}
+	cuts = append(cuts, loop.Duration)
+
+	slices := []wave.Clip{}
+	for i := 0; i+1 < len(cuts); i++ {
+		length := cuts[i+1] - cuts[i]
+		fade := sliceFade
+		if fade > length {
+			fade = length
+		}
+		src := wave.FadeOut(wave.Shift(loop.Wave, cuts[i]), length-fade, fade)
+		slices = append(slices, wave.NewClip(src, length))
+	}
+	return slices
+}
+
+// SliceOnsets cuts a loop at its hits (detected on a render at the sample rate, see analyze.Onsets),
+// for example to re-sequence the hits of a breakbeat.
+func SliceOnsets(loop wave.Clip, sampleRate int, config analyze.OnsetConfig) []wave.Clip {
+	frames := audio.Frames(loop.Wave, sampleRate, 0, loop.Duration)
+	return Slice(loop, analyze.Onsets(frames, sampleRate, config))
+}
+
+// SliceKit returns a kit playing the slices, named after their index ("0", "1", ...),
+// so they can be triggered by patterns (see Sequence).
+func SliceKit(slices []wave.Clip) Kit {
+	kit := Kit{}
+	for i, s := range slices {
+		kit[strconv.Itoa(i)] = Pad{Sound: s.Wave, Length: s.Duration}
+	}
+	return kit
+}
@@ -0,0 +1,101 @@
+package drums
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestSlice(t *testing.T) {
+	const ms = time.Millisecond
+	// A ramp, so the position of each slice in the loop can be checked
+	loop := wave.NewClip(func(x time.Duration) float64 { return x.Seconds() }, time.Second)
+	tests := []struct {
+		name    string
+		markers []time.Duration
+		want    []time.Duration // starts of the slices
+	}{
+		{name: "markers", markers: []time.Duration{250 * ms, 500 * ms}, want: []time.Duration{0, 250 * ms, 500 * ms}},
+		{name: "unsorted markers", markers: []time.Duration{750 * ms, 250 * ms}, want: []time.Duration{0, 250 * ms, 750 * ms}},
+		{name: "duplicate markers", markers: []time.Duration{500 * ms, 500 * ms}, want: []time.Duration{0, 500 * ms}},
+		{name: "marker at 0", markers: []time.Duration{0, 500 * ms}, want: []time.Duration{0, 500 * ms}},
+		{name: "markers out of the loop", markers: []time.Duration{-100 * ms, time.Second, 2 * time.Second}, want: []time.Duration{0}},
+		{name: "no markers", want: []time.Duration{0}},
+	}
+	for _, tt := range tests {
+		slices := Slice(loop, tt.markers)
+		if len(slices) != len(tt.want) {
+			t.Errorf("%s: %d slices, want %d", tt.name, len(slices), len(tt.want))
+			continue
+		}
+		for i, s := range slices {
+			end := time.Second
+			if i+1 < len(tt.want) {
+				end = tt.want[i+1]
+			}
+			if s.Duration != end-tt.want[i] {
+				t.Errorf("%s: slice %d lasts %s, want %s", tt.name, i, s.Duration, end-tt.want[i])
+			}
+			// Plays the loop from its marker, then fades out to silence at its end
+			checks := []struct {
+				at   time.Duration
+				want float64
+			}{
+				{at: 10 * ms, want: (tt.want[i] + 10*ms).Seconds()},
+				{at: s.Duration - sliceFade/2, want: 0.5 * (end - sliceFade/2).Seconds()},
+				{at: s.Duration, want: 0},
+			}
+			for _, c := range checks {
+				if got := s.Wave(c.at); math.Abs(got-c.want) > 1e-9 {
+					t.Errorf("%s: slice %d at %s = %v, want %v", tt.name, i, c.at, got, c.want)
+				}
+			}
+		}
+	}
+}
+
+func TestSliceOnsets(t *testing.T) {
+	const sampleRate = 44100
+	// Decaying noise bursts at 100ms, 400ms and 700ms
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]float64, sampleRate)
+	for i := range noise {
+		noise[i] = 2*rng.Float64() - 1
+	}
+	loop := wave.NewClip(func(x time.Duration) float64 {
+		for _, hit := range []time.Duration{700 * time.Millisecond, 400 * time.Millisecond, 100 * time.Millisecond} {
+			if x >= hit {
+				return noise[int(x.Seconds()*sampleRate)] * math.Exp(-(x-hit).Seconds()/0.015)
+			}
+		}
+		return 0
+	}, time.Second)
+
+	slices := SliceOnsets(loop, sampleRate, analyze.OnsetConfig{})
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	if len(slices) != len(want) {
+		t.Fatalf("%d slices, want %d", len(slices), len(want))
+	}
+	for i, s := range slices {
+		if d := s.Duration - want[i]; d < -3*time.Millisecond || d > 3*time.Millisecond {
+			t.Errorf("slice %d lasts %s, want %s", i, s.Duration, want[i])
+		}
+	}
+}
+
+func TestSliceKit(t *testing.T) {
+	slices := Slice(wave.NewClip(wave.Const(1), time.Second), []time.Duration{250 * time.Millisecond})
+	kit := SliceKit(slices)
+	lengths := map[string]time.Duration{}
+	for name, pad := range kit {
+		lengths[name] = pad.Length
+	}
+	if want := map[string]time.Duration{"0": 250 * time.Millisecond, "1": 750 * time.Millisecond}; !reflect.DeepEqual(lengths, want) {
+		t.Errorf("pads = %v, want %v", lengths, want)
+	}
+}
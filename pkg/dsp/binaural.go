@@ -0,0 +1,298 @@
+package dsp
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Spherical head model of SphericalHeadHRTF.
+const (
+	headRadius    = 0.0875 // in meters
+	speedOfSound  = 343.0  // in meters per second
+	hrirDuration  = 0.006  // length of the modeled impulse responses (in seconds)
+	hrirGridStep  = 10.0   // angle between two modeled directions (in degrees)
+	hrirCrossfade = 256    // frames of the crossfade between two responses when the direction changes
+)
+
+// HRIR is the pair of head-related impulse responses of a direction:
+// how a click played from this direction reaches each ear.
+type HRIR struct {
+	// Azimuth is in degrees, 0 is in front of the listener, 90 on their right, -90 on their left, 180 behind them.
+	Azimuth float64
+	// Elevation is in degrees, 0 is at the height of the ears, 90 above the listener, -90 below them.
+	Elevation   float64
+	Left, Right []float64
+}
+
+// HRTF is a set of head-related impulse responses (for example, a dataset measured on a dummy head)
+// at a sample rate, used to place sounds around a listener wearing headphones (see Binaural).
+// Measured datasets (see ImportHRTF) sound more natural than the model of SphericalHeadHRTF,
+// especially for sounds behind or above the listener.
+type HRTF struct {
+	SampleRate int
+	Responses  []HRIR
+}
+
+// SphericalHeadHRTF returns a modeled HRTF (every 10° of azimuth and elevation) for the sample rate,
+// with the structural model of Brown and Duda: the delay between the ears (a spherical head),
+// the shadow of the head on high frequencies, and the echoes of the outer ear (which depend on the elevation).
+func SphericalHeadHRTF(sampleRate int) HRTF {
+	h := HRTF{SampleRate: sampleRate}
+	for elevation := -40.0; elevation <= 90; elevation += hrirGridStep {
+		for azimuth := -180.0; azimuth < 180; azimuth += hrirGridStep {
+			h.Responses = append(h.Responses, HRIR{
+				Azimuth:   azimuth,
+				Elevation: elevation,
+				Left:      sphericalHeadResponse(sampleRate, azimuth, elevation, -1),
+				Right:     sphericalHeadResponse(sampleRate, azimuth, elevation, 1),
+			})
+		}
+	}
+	return h
+}
+
+// direction returns the unit vector of a direction (x to the right, y to the front, z up).
+func direction(azimuth, elevation float64) [3]float64 {
+	az, el := azimuth*math.Pi/180, elevation*math.Pi/180
+	return [3]float64{math.Sin(az) * math.Cos(el), math.Cos(az) * math.Cos(el), math.Sin(el)}
+}
+
+// sphericalHeadResponse models the impulse response of an ear (side -1 for the left ear, 1 for the right ear).
+func sphericalHeadResponse(sampleRate int, azimuth, elevation, side float64) []float64 {
+	fs := float64(sampleRate)
+	out := make([]float64, int(hrirDuration*fs))
+
+	// Angle between the source and the axis of the ear (0 when the source faces the ear)
+	d := direction(azimuth, elevation)
+	angle := math.Acos(math.Max(-1, math.Min(1, side*d[0])))
+
+	// Time for the sound to reach the ear (around the head when it's on the other side), relative to the center of the head
+	delay := -math.Cos(angle)
+	if angle > math.Pi/2 {
+		delay = angle - math.Pi/2
+	}
+	delay = (delay + 1) * headRadius / speedOfSound * fs
+
+	// Direct sound and echoes of the outer ear: gains and delays (in frames at 44100Hz) depending on the direction
+	gains := []float64{1, 0.5, -1, 0.5, -0.25, 0.25}
+	a, b, dd := []float64{0, 1, 5, 5, 5, 5}, []float64{0, 2, 4, 7, 11, 13}, []float64{0, 1, 0.5, 0.5, 0.5, 0.5}
+	lateral := math.Asin(math.Max(-1, math.Min(1, side*d[0]))) // from the front, toward the ear
+	impulse := make([]float64, len(out))
+	for k, g := range gains {
+		t := delay
+		if k > 0 {
+			t += (a[k]*math.Cos(lateral/2)*math.Sin(dd[k]*(math.Pi/2-elevation*math.Pi/180)) + b[k]) * fs / 44100
+		}
+		// Fractional delay (linear interpolation between two frames)
+		i := int(t)
+		if i+1 < len(impulse) {
+			impulse[i] += g * (1 - (t - float64(i)))
+			impulse[i+1] += g * (t - float64(i))
+		}
+	}
+
+	// Head shadow: a shelving filter (one pole, one zero) cutting high frequencies more as the ear faces away from the source
+	alpha := 1.05 + 0.95*math.Cos(angle*180/150)
+	beta := 2 * speedOfSound / headRadius
+	b0, b1, a1 := (beta+alpha*2*fs)/(beta+2*fs), (beta-alpha*2*fs)/(beta+2*fs), (beta-2*fs)/(beta+2*fs)
+	x1, y1 := 0.0, 0.0
+	for i, x := range impulse {
+		y := b0*x + b1*x1 - a1*y1
+		x1, y1 = x, y
+		out[i] = y
+	}
+	return out
+}
+
+// hrirFileRegexp matches the names of the files of ImportHRTF (ear, elevation and azimuth).
+var hrirFileRegexp = regexp.MustCompile(`^([LR])(-?\d+)e(\d+)a\.wav$`)
+
+// ImportHRTF loads measured responses from a directory (and its subdirectories) of mono WAV files,
+// one per ear and direction, named like the full set of the MIT KEMAR measurements:
+// "L<elevation>e<azimuth>a.wav" for the left ear and "R<elevation>e<azimuth>a.wav" for the right ear
+// (for example: "L-20e090a.wav" is the left ear for a source on the right, 20° below the ears).
+// Angles are in degrees, azimuths go clockwise from 0 to 360 (90 is on the right, like in HRIR).
+// Each direction needs both ears, and all files should have the same sample rate.
+func ImportHRTF(dir string) (HRTF, error) {
+	h := HRTF{}
+	responses := map[[2]int]*HRIR{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		m := hrirFileRegexp.FindStringSubmatch(d.Name())
+		if d.IsDir() || m == nil {
+			return nil
+		}
+		elevation, _ := strconv.Atoi(m[2])
+		azimuth, _ := strconv.Atoi(m[3])
+		frames, sampleRate, err := wave.ImportWavFrames(path)
+		if err != nil {
+			return err
+		}
+		if h.SampleRate == 0 {
+			h.SampleRate = sampleRate
+		} else if sampleRate != h.SampleRate {
+			return fmt.Errorf("sample rate of %s is %dHz instead of %dHz", path, sampleRate, h.SampleRate)
+		}
+
+		key := [2]int{elevation, azimuth}
+		r, ok := responses[key]
+		if !ok {
+			r = &HRIR{Azimuth: math.Remainder(float64(azimuth), 360), Elevation: float64(elevation)}
+			responses[key] = r
+		}
+		if m[1] == "L" {
+			r.Left = frames
+		} else {
+			r.Right = frames
+		}
+		return nil
+	})
+	if err != nil {
+		return HRTF{}, fmt.Errorf("import HRTF: %s: %w", dir, err)
+	}
+	if len(responses) == 0 {
+		return HRTF{}, fmt.Errorf("import HRTF: %s: no response found", dir)
+	}
+
+	keys := make([][2]int, 0, len(responses))
+	for key, r := range responses {
+		if r.Left == nil || r.Right == nil {
+			return HRTF{}, fmt.Errorf("import HRTF: %s: missing ear at %d° of elevation and %d° of azimuth", dir, key[0], key[1])
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, key := range keys {
+		h.Responses = append(h.Responses, *responses[key])
+	}
+	return h, nil
+}
+
+// directions returns the unit vectors of the directions of the responses.
+func (h HRTF) directions() [][3]float64 {
+	out := make([][3]float64, len(h.Responses))
+	for i, r := range h.Responses {
+		out[i] = direction(r.Azimuth, r.Elevation)
+	}
+	return out
+}
+
+// nearest returns the index of the direction (unit vector) closest to the azimuth and elevation.
+func nearest(directions [][3]float64, azimuth, elevation float64) int {
+	d := direction(azimuth, elevation)
+	best, bestDot := 0, math.Inf(-1)
+	for i, e := range directions {
+		if dot := d[0]*e[0] + d[1]*e[1] + d[2]*e[2]; dot > bestDot {
+			best, bestDot = i, dot
+		}
+	}
+	return best
+}
+
+// Binaural places a mono source around a listener wearing headphones, in the direction
+// given by the azimuth and elevation waves (in degrees, see HRIR), by filtering it with the responses of the HRTF.
+// Moving sources crossfade between the responses of the directions they go through.
+//
+// The source is rendered at the sample rate of the HRTF (frames should be played at this rate),
+// the waves keep state between evaluations (see wave.Wave).
+func Binaural(src, azimuth, elevation wave.Wave, hrtf HRTF) wave.StereoWave {
+	length := 1
+	for _, r := range hrtf.Responses {
+		if len(r.Left) > length {
+			length = len(r.Left)
+		}
+		if len(r.Right) > length {
+			length = len(r.Right)
+		}
+	}
+	b := &binaural{
+		src: src, azimuth: azimuth, elevation: elevation, hrtf: hrtf,
+		directions: hrtf.directions(),
+		history:    make([]float64, length),
+		last:       -1,
+	}
+	return wave.StereoWave{
+		Left:  func(x time.Duration) float64 { return b.at(x)[0] },
+		Right: func(x time.Duration) float64 { return b.at(x)[1] },
+	}
+}
+
+type binaural struct {
+	src, azimuth, elevation wave.Wave
+	hrtf                    HRTF
+	directions              [][3]float64 // unit vectors of the directions of the responses
+	history                 []float64    // last input frames (ring)
+	i                       int          // index of the last input frame in the history
+	current, previous       int          // indices of the responses (crossfaded)
+	fade                    int          // remaining frames of the crossfade
+	az, el                  float64      // direction of the current response
+	last                    time.Duration
+	output                  [2]float64
+}
+
+// at returns the output of both ears at x, processing a new input frame when x changes.
+func (b *binaural) at(x time.Duration) [2]float64 {
+	if x == b.last {
+		return b.output
+	}
+	if x < b.last || b.last < 0 {
+		for i := range b.history {
+			b.history[i] = 0
+		}
+		b.az, b.el = b.azimuth(x), b.elevation(x)
+		b.current = nearest(b.directions, b.az, b.el)
+		b.fade = 0
+	}
+	b.last = x
+	if len(b.hrtf.Responses) == 0 {
+		b.output = [2]float64{}
+		return b.output
+	}
+
+	b.i = (b.i + 1) % len(b.history)
+	b.history[b.i] = b.src(x)
+	if az, el := b.azimuth(x), b.elevation(x); az != b.az || el != b.el {
+		b.az, b.el = az, el
+		if next := nearest(b.directions, az, el); next != b.current {
+			b.previous, b.current, b.fade = b.current, next, hrirCrossfade
+		}
+	}
+
+	b.output = b.convolve(b.current)
+	if b.fade > 0 {
+		t := float64(b.fade) / hrirCrossfade
+		old := b.convolve(b.previous)
+		b.output[0] = b.output[0]*(1-t) + old[0]*t
+		b.output[1] = b.output[1]*(1-t) + old[1]*t
+		b.fade--
+	}
+	return b.output
+}
+
+// convolve filters the history with the responses of both ears.
+func (b *binaural) convolve(response int) [2]float64 {
+	r := b.hrtf.Responses[response]
+	out := [2]float64{}
+	for ear, ir := range [2][]float64{r.Left, r.Right} {
+		j := b.i
+		for _, v := range ir {
+			out[ear] += v * b.history[j]
+			if j--; j < 0 {
+				j = len(b.history) - 1
+			}
+		}
+	}
+	return out
+}
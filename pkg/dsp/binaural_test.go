@@ -0,0 +1,131 @@
+package dsp
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestBinauralAzimuth(t *testing.T) {
+	const sampleRate = 44100
+	hrtf := SphericalHeadHRTF(sampleRate)
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]float64, sampleRate/10)
+	for i := range noise {
+		noise[i] = 2*rng.Float64() - 1
+	}
+	src := func(x time.Duration) float64 { return noise[int(math.Round(x.Seconds()*sampleRate))%len(noise)] }
+
+	// Ratio of the levels of the right and left ears
+	ratio := func(azimuth float64) float64 {
+		out := Binaural(src, wave.Const(azimuth), wave.Const(0), hrtf)
+		left, right := 0.0, 0.0
+		for i := range noise {
+			x := time.Duration(int64(i) * int64(time.Second) / sampleRate)
+			l, r := out.Left(x), out.Right(x)
+			left, right = left+l*l, right+r*r
+		}
+		return math.Sqrt(right / left)
+	}
+	tests := []struct {
+		azimuth  float64
+		min, max float64
+	}{
+		{azimuth: -90, max: 0.5},
+		{azimuth: 0, min: 0.95, max: 1.05},
+		{azimuth: 180, min: 0.95, max: 1.05},
+		{azimuth: 90, min: 2},
+	}
+	for _, tt := range tests {
+		if got := ratio(tt.azimuth); got < tt.min || (tt.max > 0 && got > tt.max) {
+			t.Errorf("azimuth %v: right/left level = %v, want between %v and %v", tt.azimuth, got, tt.min, tt.max)
+		}
+	}
+	// The right ear gets louder as the source moves from the left to the right
+	previous := 0.0
+	for azimuth := -90.0; azimuth <= 90; azimuth += 30 {
+		got := ratio(azimuth)
+		if got <= previous {
+			t.Errorf("azimuth %v: right/left level = %v, want more than %v (at %v)", azimuth, got, previous, azimuth-30)
+		}
+		previous = got
+	}
+}
+
+func TestNearest(t *testing.T) {
+	h := HRTF{Responses: []HRIR{{Azimuth: 0}, {Azimuth: 90}, {Azimuth: -90}, {Azimuth: 180}, {Elevation: 90}}}
+	tests := []struct {
+		azimuth, elevation float64
+		want               int
+	}{
+		{azimuth: 10, want: 0},
+		{azimuth: 80, want: 1},
+		{azimuth: 260, want: 2}, // -100
+		{azimuth: -170, want: 3},
+		{azimuth: 45, elevation: 80, want: 4},
+	}
+	for _, tt := range tests {
+		if got := nearest(h.directions(), tt.azimuth, tt.elevation); got != tt.want {
+			t.Errorf("nearest to %v° of azimuth and %v° of elevation = %d, want %d", tt.azimuth, tt.elevation, got, tt.want)
+		}
+	}
+}
+
+func TestImportHRTF(t *testing.T) {
+	write := func(dir, name string, sampleRate int, frames ...float64) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := audio.ExportWav(filepath.Join(dir, name), frames, sampleRate, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := t.TempDir()
+	write(filepath.Join(dir, "elev0"), "L0e090a.wav", 44100, 0.25, 0)
+	write(filepath.Join(dir, "elev0"), "R0e090a.wav", 44100, 0.5, 0)
+	write(filepath.Join(dir, "elev-10"), "L-10e270a.wav", 44100, 0.5, 0)
+	write(filepath.Join(dir, "elev-10"), "R-10e270a.wav", 44100, 0.25, 0)
+	write(dir, "readme.wav", 8000, 1) // ignored
+
+	h, err := ImportHRTF(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.SampleRate != 44100 || len(h.Responses) != 2 {
+		t.Fatalf("%d responses at %dHz, want 2 at 44100Hz", len(h.Responses), h.SampleRate)
+	}
+	for i, want := range []HRIR{{Azimuth: -90, Elevation: -10, Left: []float64{0.5, 0}}, {Azimuth: 90, Elevation: 0, Left: []float64{0.25, 0}}} {
+		r := h.Responses[i]
+		if r.Azimuth != want.Azimuth || r.Elevation != want.Elevation || len(r.Left) != 2 || math.Abs(r.Left[0]-want.Left[0]) > 1e-3 {
+			t.Errorf("response %d = %+v, want %+v", i, r, want)
+		}
+	}
+
+	errorTests := []struct {
+		name  string
+		files func(dir string)
+	}{
+		{name: "no response", files: func(dir string) {}},
+		{name: "missing ear", files: func(dir string) { write(dir, "L0e000a.wav", 44100, 1) }},
+		{name: "different sample rates", files: func(dir string) {
+			write(dir, "L0e000a.wav", 44100, 1)
+			write(dir, "R0e000a.wav", 48000, 1)
+		}},
+	}
+	for _, tt := range errorTests {
+		dir := t.TempDir()
+		tt.files(dir)
+		if _, err := ImportHRTF(dir); err == nil {
+			t.Errorf("%s: imported without error", tt.name)
+		}
+	}
+	if _, err := ImportHRTF(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("imported a missing directory without error")
+	}
+}
@@ -0,0 +1,98 @@
+package dsp
+
+import (
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Block renders consecutive frames at once, to avoid a function call per sample through deep processing chains.
+// Blocks are created for a sample rate.
+type Block interface {
+	// ProcessBlock fills out with consecutive frames, the first one being at the start position.
+	ProcessBlock(out []float64, start time.Duration)
+}
+
+// BlockProcessor is implemented by processors able to process a whole buffer in a tight loop.
+type BlockProcessor interface {
+	Processor
+	// ProcessBuffer processes the samples of the buffer in place.
+	ProcessBuffer(buf []float64)
+}
+
+// NewWaveBlock renders a plain wave block by block (evaluating it for each frame).
+func NewWaveBlock(src wave.Wave, sampleRate int) Block {
+	return &waveBlock{src: src, sampleRate: sampleRate}
+}
+
+type waveBlock struct {
+	src        wave.Wave
+	sampleRate int
+}
+
+func (b *waveBlock) ProcessBlock(out []float64, start time.Duration) {
+	for i := range out {
+		out[i] = b.src(start + frameOffset(i, b.sampleRate))
+	}
+}
+
+// NewNodeBlock renders a node block by block.
+func NewNodeBlock(n Node, sampleRate int) Block {
+	return &nodeBlock{n: n, sampleRate: sampleRate}
+}
+
+type nodeBlock struct {
+	n          Node
+	sampleRate int
+}
+
+func (b *nodeBlock) ProcessBlock(out []float64, start time.Duration) {
+	for i := range out {
+		out[i] = b.n.Process(start + frameOffset(i, b.sampleRate))
+	}
+}
+
+// ApplyBlock returns a block processing the output of the source block with the processors (in order).
+// Processors implementing BlockProcessor process the whole block at once.
+func ApplyBlock(src Block, processors ...Processor) Block {
+	return &processedBlock{src: src, processors: processors}
+}
+
+type processedBlock struct {
+	src        Block
+	processors []Processor
+}
+
+func (b *processedBlock) ProcessBlock(out []float64, start time.Duration) {
+	b.src.ProcessBlock(out, start)
+	for _, p := range b.processors {
+		if bp, ok := p.(BlockProcessor); ok {
+			bp.ProcessBuffer(out)
+			continue
+		}
+		for i, v := range out {
+			out[i] = p.Process(v)
+		}
+	}
+}
+
+// Render renders count frames of a block, in blocks of the given size.
+func Render(b Block, sampleRate int, start time.Duration, count, blockSize int) []float64 {
+	out := make([]float64, count)
+	if blockSize <= 0 {
+		blockSize = count
+	}
+	for i := 0; i < count; i += blockSize {
+		end := i + blockSize
+		if end > count {
+			end = count
+		}
+		b.ProcessBlock(out[i:end], start+frameOffset(i, sampleRate))
+	}
+	return out
+}
+
+// frameOffset returns the position of a frame relative to the first frame.
+func frameOffset(index, sampleRate int) time.Duration {
+	return time.Duration(int64(index) * int64(time.Second) / int64(sampleRate))
+}
@@ -0,0 +1,85 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestProcessBuffer(t *testing.T) {
+	const sampleRate = 44100
+	tests := []struct {
+		name string
+		new  func() BlockProcessor
+	}{
+		{name: "low-pass", new: func() BlockProcessor { return NewLowPass(sampleRate, 800, 4) }},
+		{name: "band-pass", new: func() BlockProcessor { return NewBandPass(sampleRate, 2000, 2) }},
+		{name: "delay", new: func() BlockProcessor { return NewDelay(sampleRate, 3*time.Millisecond, 0.6, 0.5) }},
+	}
+	in := make([]float64, 4096)
+	for i := range in {
+		in[i] = math.Sin(float64(i)*0.3) + 0.5*math.Sin(float64(i)*0.011)
+	}
+	for _, tt := range tests {
+		sample, block := tt.new(), tt.new()
+		want := make([]float64, len(in))
+		for i, v := range in {
+			want[i] = sample.Process(v)
+		}
+		// Blocks of uneven sizes, so the state is carried between blocks
+		got := append([]float64(nil), in...)
+		for i := 0; i < len(got); i += 100 {
+			end := i + 100
+			if end > len(got) {
+				end = len(got)
+			}
+			block.ProcessBuffer(got[i:end])
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: frame %d = %v, want %v (per sample)", tt.name, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestApplyBlock(t *testing.T) {
+	const sampleRate = 44100
+	src := wave.OscillateSine(wave.Const(220))
+	chain := func() []Processor {
+		return []Processor{NewLowPass(sampleRate, 1200, 2), NewDelay(sampleRate, 10*time.Millisecond, 0.3, 0.3)}
+	}
+	want := Render(NewNodeBlock(Apply(FromWave(src), chain()...), sampleRate), sampleRate, 0, 10000, 0)
+	for _, size := range []int{1, 64, 1000, 0} {
+		got := Render(ApplyBlock(NewWaveBlock(src, sampleRate), chain()...), sampleRate, 0, 10000, size)
+		for i := range want {
+			// Positions inside blocks are truncated to the nanosecond from the start of the block, the sine moves by at most ~1e-6 per ns
+			if math.Abs(got[i]-want[i]) > 1e-5 {
+				t.Fatalf("blocks of %d: frame %d = %v, want %v", size, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func BenchmarkBiquad(b *testing.B) {
+	buf := make([]float64, 512)
+	for i := range buf {
+		buf[i] = math.Sin(float64(i) * 0.1)
+	}
+	b.Run("Process", func(b *testing.B) {
+		f := NewLowPass(44100, 1000, 1)
+		for i := 0; i < b.N; i++ {
+			for j, v := range buf {
+				buf[j] = f.Process(v)
+			}
+		}
+	})
+	b.Run("ProcessBuffer", func(b *testing.B) {
+		f := NewLowPass(44100, 1000, 1)
+		for i := 0; i < b.N; i++ {
+			f.ProcessBuffer(buf)
+		}
+	})
+}
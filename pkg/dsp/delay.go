@@ -0,0 +1,49 @@
+package dsp
+
+import "time"
+
+// Delay repeats its input after a delay time, with feedback (echo).
+type Delay struct {
+	buf      []float64
+	i        int
+	feedback float64
+	mix      float64
+}
+
+// NewDelay creates a delay for the sample rate.
+// Feedback is the gain of each repetition (below 1) and mix is the level of the delayed signal
+// (0 is the dry signal only, 1 is the delayed signal only).
+func NewDelay(sampleRate int, d time.Duration, feedback, mix float64) *Delay {
+	size := int(d.Seconds() * float64(sampleRate))
+	if size < 1 {
+		size = 1
+	}
+	return &Delay{buf: make([]float64, size), feedback: feedback, mix: mix}
+}
+
+func (d *Delay) Process(in float64) float64 {
+	delayed := d.buf[d.i]
+	d.buf[d.i] = in + delayed*d.feedback
+	d.i = (d.i + 1) % len(d.buf)
+	return in*(1-d.mix) + delayed*d.mix
+}
+
+func (d *Delay) Reset() {
+	for i := range d.buf {
+		d.buf[i] = 0
+	}
+	d.i = 0
+}
+
+func (d *Delay) ProcessBuffer(buf []float64) {
+	line, j, feedback, mix := d.buf, d.i, d.feedback, d.mix
+	for i, in := range buf {
+		delayed := line[j]
+		line[j] = in + delayed*feedback
+		if j++; j == len(line) {
+			j = 0
+		}
+		buf[i] = in*(1-mix) + delayed*mix
+	}
+	d.i = j
+}
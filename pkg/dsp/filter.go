@@ -0,0 +1,120 @@
+package dsp
+
+import "math"
+
+// Biquad is a second-order filter (with the coefficients of the audio EQ cookbook of Robert Bristow-Johnson).
+type Biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// NewLowPass creates a filter removing the frequencies above the cutoff frequency (in Hz) for the sample rate.
+// Q is the resonance at the cutoff (0.707 doesn't resonate, higher values boost the cutoff frequency).
+func NewLowPass(sampleRate int, frequency, q float64) *Biquad {
+	w, alpha := biquadParams(sampleRate, frequency, q)
+	c := math.Cos(w)
+	return newBiquad((1-c)/2, 1-c, (1-c)/2, 1+alpha, -2*c, 1-alpha)
+}
+
+// NewHighPass creates a filter removing the frequencies below the cutoff frequency (see NewLowPass).
+func NewHighPass(sampleRate int, frequency, q float64) *Biquad {
+	w, alpha := biquadParams(sampleRate, frequency, q)
+	c := math.Cos(w)
+	return newBiquad((1+c)/2, -(1 + c), (1+c)/2, 1+alpha, -2*c, 1-alpha)
+}
+
+// NewBandPass creates a filter keeping the frequencies around a center frequency (in Hz), at their level.
+// Q is the center frequency divided by the width of the band: higher values keep narrower bands.
+func NewBandPass(sampleRate int, frequency, q float64) *Biquad {
+	f := &Biquad{}
+	f.setBandPass(sampleRate, frequency, q)
+	return f
+}
+
+// setBandPass changes the coefficients of the filter (keeping its state, for filters moving over time).
+func (f *Biquad) setBandPass(sampleRate int, frequency, q float64) {
+	w, alpha := biquadParams(sampleRate, frequency, q)
+	f.set(alpha, 0, -alpha, 1+alpha, -2*math.Cos(w), 1-alpha)
+}
+
+func biquadParams(sampleRate int, frequency, q float64) (w, alpha float64) {
+	if q <= 0 {
+		q = math.Sqrt2 / 2
+	}
+	// Keep the frequency under the Nyquist frequency
+	frequency = math.Max(1, math.Min(frequency, 0.49*float64(sampleRate)))
+	w = 2 * math.Pi * frequency / float64(sampleRate)
+	return w, math.Sin(w) / (2 * q)
+}
+
+func newBiquad(b0, b1, b2, a0, a1, a2 float64) *Biquad {
+	f := &Biquad{}
+	f.set(b0, b1, b2, a0, a1, a2)
+	return f
+}
+
+func (f *Biquad) set(b0, b1, b2, a0, a1, a2 float64) {
+	f.b0, f.b1, f.b2, f.a1, f.a2 = b0/a0, b1/a0, b2/a0, a1/a0, a2/a0
+}
+
+func (f *Biquad) Process(in float64) float64 {
+	out := f.b0*in + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x1, f.x2, f.y1, f.y2 = in, f.x1, out, f.y1
+	return out
+}
+
+func (f *Biquad) Reset() { f.x1, f.x2, f.y1, f.y2 = 0, 0, 0, 0 }
+
+// ProcessBuffer is like Process for each sample of the buffer,
+// with the coefficients and the state kept in local variables during the loop.
+func (f *Biquad) ProcessBuffer(buf []float64) {
+	b0, b1, b2, a1, a2 := f.b0, f.b1, f.b2, f.a1, f.a2
+	x1, x2, y1, y2 := f.x1, f.x2, f.y1, f.y2
+	for i, in := range buf {
+		out := b0*in + b1*x1 + b2*x2 - a1*y1 - a2*y2
+		x1, x2, y1, y2 = in, x1, out, y1
+		buf[i] = out
+	}
+	f.x1, f.x2, f.y1, f.y2 = x1, x2, y1, y2
+}
+
+// FilterBank splits a signal into adjacent frequency bands (for example for a vocoder or a spectrum display).
+// Bands are spaced logarithmically (like musical pitch), each one is a band-pass filter of the fourth order.
+type FilterBank struct {
+	// Frequencies are the center frequencies of the bands, from the lowest one.
+	Frequencies []float64
+	filters     [][2]*Biquad
+}
+
+// NewFilterBank creates a bank of bands between low and high frequencies (in Hz) for the sample rate.
+func NewFilterBank(sampleRate, bands int, low, high float64) *FilterBank {
+	if bands < 1 {
+		bands = 1
+	}
+	// Width of a band (in octaves) and its Q
+	octaves := math.Log2(high/low) / float64(bands)
+	q := math.Sqrt(math.Pow(2, octaves)) / (math.Pow(2, octaves) - 1)
+
+	b := &FilterBank{}
+	for i := 0; i < bands; i++ {
+		f := low * math.Pow(2, octaves*(float64(i)+0.5))
+		b.Frequencies = append(b.Frequencies, f)
+		b.filters = append(b.filters, [2]*Biquad{NewBandPass(sampleRate, f, q), NewBandPass(sampleRate, f, q)})
+	}
+	return b
+}
+
+// Process filters the next input sample and writes the output of each band to out (one value per band).
+func (b *FilterBank) Process(in float64, out []float64) {
+	for i, f := range b.filters {
+		out[i] = f[1].Process(f[0].Process(in))
+	}
+}
+
+// Reset clears the state of the filters.
+func (b *FilterBank) Reset() {
+	for _, f := range b.filters {
+		f[0].Reset()
+		f[1].Reset()
+	}
+}
@@ -0,0 +1,40 @@
+package dsp
+
+import (
+	"math"
+	"time"
+)
+
+// EnvelopeFollower is a processor returning the level of its input (the amplitude of a sine),
+// rising in the attack time and falling in the release time, for example to drive an effect with the dynamics of a sound.
+type EnvelopeFollower struct {
+	attack, release float64 // smoothing at each frame
+	level           float64
+}
+
+// NewEnvelopeFollower creates an envelope follower for the sample rate.
+func NewEnvelopeFollower(sampleRate int, attack, release time.Duration) *EnvelopeFollower {
+	return &EnvelopeFollower{attack: smoothing(sampleRate, attack), release: smoothing(sampleRate, release)}
+}
+
+// smoothing returns the part of the remaining distance to a target covered at each frame,
+// so the target is (mostly) reached after d.
+func smoothing(sampleRate int, d time.Duration) float64 {
+	if d <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-4.6/(d.Seconds()*float64(sampleRate)))
+}
+
+func (f *EnvelopeFollower) Process(in float64) float64 {
+	// The mean of a rectified sine is 2/π of its amplitude
+	target := math.Abs(in) * math.Pi / 2
+	if target > f.level {
+		f.level += (target - f.level) * f.attack
+	} else {
+		f.level += (target - f.level) * f.release
+	}
+	return f.level
+}
+
+func (f *EnvelopeFollower) Reset() { f.level = 0 }
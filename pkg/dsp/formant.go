@@ -0,0 +1,90 @@
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Vowels of FormantFilter, values in between morph from a vowel to the next one.
+const (
+	VowelA = 0.0
+	VowelE = 1.0
+	VowelI = 2.0
+	VowelO = 3.0
+	VowelU = 4.0
+)
+
+// formant is a resonance of the vocal tract.
+type formant struct {
+	frequency, gain, bandwidth float64 // in Hz, linear, in Hz
+}
+
+// The first three formants of each vowel (of a bass voice).
+var vowelFormants = [][3]formant{
+	{{600, 1, 60}, {1040, 0.447, 70}, {2250, 0.355, 110}}, // A
+	{{400, 1, 40}, {1620, 0.251, 80}, {2400, 0.355, 100}}, // E
+	{{250, 1, 60}, {1750, 0.032, 90}, {2600, 0.158, 100}}, // I
+	{{400, 1, 40}, {750, 0.282, 80}, {2400, 0.089, 100}},  // O
+	{{350, 1, 40}, {600, 0.1, 80}, {2400, 0.025, 100}},    // U
+}
+
+// FormantFilter returns a node filtering the source through the resonances of a vowel (formants),
+// for talking-synth effects: for example, a saw wave with a vowel slowly moving from VowelA to VowelO.
+//
+// The vowel wave selects the vowel (see VowelA to VowelU),
+// values in between morph smoothly from a vowel to the next one (values are clamped between VowelA and VowelU,
+// and NaN keeps the previous vowel).
+// Sources rich in harmonics (saw, pulse, noise) work best.
+func FormantFilter(src Node, vowel wave.Wave, sampleRate int) Node {
+	f := &formantFilter{src: src, vowel: vowel, sampleRate: sampleRate, last: math.NaN()}
+	for i := range f.filters {
+		f.filters[i] = &Biquad{}
+	}
+	return f
+}
+
+type formantFilter struct {
+	src        Node
+	vowel      wave.Wave
+	sampleRate int
+	filters    [3]*Biquad
+	gains      [3]float64
+	last       float64 // vowel of the current coefficients
+}
+
+func (f *formantFilter) Process(x time.Duration) float64 {
+	in := f.src.Process(x)
+	v := math.Max(VowelA, math.Min(VowelU, f.vowel(x)))
+	if math.IsNaN(v) {
+		v = f.last
+		if math.IsNaN(v) {
+			v = VowelA // no previous vowel
+		}
+	}
+	if v != f.last {
+		f.last = v
+		i := int(math.Min(v, VowelU-1))
+		t := v - float64(i)
+		for j, a := range vowelFormants[i] {
+			b := vowelFormants[i+1][j]
+			frequency := a.frequency + (b.frequency-a.frequency)*t
+			bandwidth := a.bandwidth + (b.bandwidth-a.bandwidth)*t
+			f.gains[j] = a.gain + (b.gain-a.gain)*t
+			f.filters[j].setBandPass(f.sampleRate, frequency, frequency/bandwidth)
+		}
+	}
+	out := 0.0
+	for j, filter := range f.filters {
+		out += f.gains[j] * filter.Process(in)
+	}
+	return out
+}
+
+func (f *formantFilter) Reset() {
+	f.src.Reset()
+	for _, filter := range f.filters {
+		filter.Reset()
+	}
+}
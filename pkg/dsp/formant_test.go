@@ -0,0 +1,78 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestFormantFilterVowels(t *testing.T) {
+	const sampleRate = 44100
+	saw := wave.Oscillate(wave.Sawtooth, wave.Const(110), 0)
+	render := func(vowel wave.Wave) []float64 {
+		return audio.FrameRange(ToWave(FormantFilter(FromWave(saw), vowel, sampleRate)), sampleRate, 0, 4410)
+	}
+	// NaN after 50ms
+	nanAfter := func(v float64) wave.Wave {
+		return func(x time.Duration) float64 {
+			if x >= 50*time.Millisecond {
+				return math.NaN()
+			}
+			return v
+		}
+	}
+	tests := []struct {
+		name  string
+		vowel wave.Wave
+		want  wave.Wave // vowel rendering the same frames
+	}{
+		{name: "below A", vowel: wave.Const(-1), want: wave.Const(VowelA)},
+		{name: "above U", vowel: wave.Const(7), want: wave.Const(VowelU)},
+		{name: "-Inf", vowel: wave.Const(math.Inf(-1)), want: wave.Const(VowelA)},
+		{name: "+Inf", vowel: wave.Const(math.Inf(1)), want: wave.Const(VowelU)},
+		{name: "NaN", vowel: wave.Const(math.NaN()), want: wave.Const(VowelA)},
+		{name: "NaN keeps the previous vowel", vowel: nanAfter(VowelE), want: wave.Const(VowelE)},
+		{name: "NaN keeps the last vowel", vowel: nanAfter(VowelU), want: wave.Const(VowelU)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, want := render(tt.vowel), render(tt.want)
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("frame %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormantFilterMorph(t *testing.T) {
+	// Sines at the first formant of a vowel are louder through it than through another vowel
+	const sampleRate = 44100
+	tests := []struct {
+		frequency    float64
+		vowel, other float64
+	}{
+		{frequency: 600, vowel: VowelA, other: VowelI},
+		{frequency: 250, vowel: VowelI, other: VowelA},
+		{frequency: 325, vowel: 1.5, other: VowelA}, // halfway between E (400Hz) and I (250Hz)
+		{frequency: 500, vowel: 0.5, other: VowelU}, // halfway between A (600Hz) and E (400Hz)
+	}
+	rms := func(frequency, vowel float64) float64 {
+		n := FormantFilter(FromWave(wave.OscillateSine(wave.Const(frequency))), wave.Const(vowel), sampleRate)
+		frames := audio.FrameRange(ToWave(n), sampleRate, 0, sampleRate/2)
+		sum := 0.0
+		for _, v := range frames[sampleRate/4:] {
+			sum += v * v
+		}
+		return math.Sqrt(sum / float64(sampleRate/4))
+	}
+	for _, tt := range tests {
+		if got, other := rms(tt.frequency, tt.vowel), rms(tt.frequency, tt.other); got <= other {
+			t.Errorf("%vHz: RMS through vowel %v = %v, through vowel %v = %v, want it louder", tt.frequency, tt.vowel, got, tt.other, other)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package dsp provides stateful signal processing (filters, delays, reverbs) alongside functional waves.
+//
+// A wave.Wave can be evaluated at any position, so it can't hold state (like the memory of a filter).
+// Nodes are instead evaluated at consecutive positions, one sample after the other,
+// and they are reset before going back in time.
+package dsp
+
+import (
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Node is a signal generator that may keep state between samples.
+type Node interface {
+	// Process returns the sample at the given position.
+	// Positions are consecutive frames, they only go back in time after a call to Reset.
+	Process(x time.Duration) float64
+	// Reset clears the state of the node (and of its inputs).
+	Reset()
+}
+
+// Processor transforms an input signal, keeping state between samples (for example: a filter or a delay).
+// Processors are created for a given sample rate, one input sample is processed per frame.
+type Processor interface {
+	// Process returns the output sample for the next input sample.
+	Process(in float64) float64
+	// Reset clears the state of the processor.
+	Reset()
+}
+
+// FromWave returns a stateless node producing the values of a wave.
+func FromWave(w wave.Wave) Node { return waveNode{w} }
+
+type waveNode struct{ w wave.Wave }
+
+func (n waveNode) Process(x time.Duration) float64 { return n.w(x) }
+func (n waveNode) Reset()                          {}
+
+// Apply returns a node processing the output of the source node with the processors (in order).
+func Apply(src Node, processors ...Processor) Node {
+	return &processedNode{src: src, processors: processors}
+}
+
+type processedNode struct {
+	src        Node
+	processors []Processor
+}
+
+func (n *processedNode) Process(x time.Duration) float64 {
+	v := n.src.Process(x)
+	for _, p := range n.processors {
+		v = p.Process(v)
+	}
+	return v
+}
+
+func (n *processedNode) Reset() {
+	n.src.Reset()
+	for _, p := range n.processors {
+		p.Reset()
+	}
+}
+
+// ToWave returns a wave playing the node.
+// The node is reset when the wave goes back in time (for example: when seeking or looping)
+// and the last sample is reused when the same position is evaluated several times.
+// Since the node keeps state, so does the wave (see wave.Wave).
+func ToWave(n Node) wave.Wave {
+	last, lastValue := time.Duration(-1), 0.0
+	return func(x time.Duration) float64 {
+		if x == last {
+			return lastValue
+		}
+		if x < last {
+			n.Reset()
+		}
+		last, lastValue = x, n.Process(x)
+		return lastValue
+	}
+}
+
+// Effect returns a function applying processors to a wave,
+// for example to use processors as timeline effects.
+func Effect(processors ...Processor) func(src wave.Wave) wave.Wave {
+	return func(src wave.Wave) wave.Wave { return ToWave(Apply(FromWave(src), processors...)) }
+}
@@ -0,0 +1,76 @@
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// PitchShift returns a node transposing the source by semitones (negative values transpose down) without changing its tempo,
+// for example to play a vocal or melodic sample in the key of a song.
+//
+// The source is time-stretched (see TimeStretch) then played faster or slower by the transposition ratio,
+// so the stretch and the change of speed cancel out in time.
+// Formants are transposed too (large transpositions make voices sound smaller or bigger).
+// The source is read out of order, so it shouldn't hold state.
+func PitchShift(src wave.Wave, sampleRate int, semitones float64) Node {
+	ratio := math.Pow(2, semitones/12)
+	return &pitchShift{
+		stretch:    TimeStretch(src, sampleRate, 1/ratio),
+		sampleRate: sampleRate,
+		ratio:      ratio,
+		next:       -1,
+	}
+}
+
+type pitchShift struct {
+	stretch    Node
+	sampleRate int
+	ratio      float64
+	next       int        // index of the next output frame (-1 before the first one)
+	produced   int        // index of the next stretched frame
+	history    [4]float64 // last stretched frames (interpolated)
+}
+
+func (p *pitchShift) Process(x time.Duration) float64 {
+	// Index of the frame at x (frames start at i*time.Second/sampleRate, rounded down to the nanosecond)
+	n := int((int64(x)*int64(p.sampleRate) + int64(time.Second) - 1) / int64(time.Second))
+	position := float64(n) * p.ratio // in stretched frames
+	m := int(math.Floor(position))
+	if n != p.next {
+		p.history = [4]float64{}
+		p.produced = m - 1
+	}
+	p.next = n + 1
+
+	// Stretched frames from m-1 to m+2
+	for p.produced <= m+2 {
+		copy(p.history[:], p.history[1:])
+		p.history[3] = 0
+		if p.produced >= 0 {
+			p.history[3] = p.stretch.Process(frameOffset(p.produced, p.sampleRate))
+		}
+		p.produced++
+	}
+	return hermite(p.history, position-float64(m))
+}
+
+func (p *pitchShift) Reset() {
+	p.stretch.Reset()
+	p.next = -1
+}
+
+// hermite interpolates between y[1] and y[2] (t between 0 and 1) with a cubic Hermite spline.
+func hermite(y [4]float64, t float64) float64 {
+	c1 := (y[2] - y[0]) / 2
+	c2 := y[0] - 2.5*y[1] + 2*y[2] - y[3]/2
+	c3 := (y[3]-y[0])/2 + 1.5*(y[1]-y[2])
+	return ((c3*t+c2)*t+c1)*t + y[1]
+}
+
+// ShiftClip transposes a clip (see PitchShift), its duration doesn't change.
+// The clip is stateful (see wave.Wave).
+func ShiftClip(c wave.Clip, sampleRate int, semitones float64) wave.Clip {
+	return wave.NewClip(ToWave(PitchShift(c.Wave, sampleRate, semitones)), c.Duration)
+}
@@ -0,0 +1,88 @@
+package dsp
+
+import "math"
+
+// Frequencies of the saturation filters (in Hz): the pivot of the tilt, and the cutoff of the DC blocker.
+const (
+	tiltPivot          = 800.0
+	saturationDCCutoff = 10.0
+)
+
+// SaturationConfig describes the color of a saturation.
+type SaturationConfig struct {
+	// Drive is the gain before the saturation curve (default: 2): higher values add more harmonics.
+	Drive float64
+	// Bias makes the curve asymmetric (between 0 and 1, for example 0.2), adding even harmonics
+	// (warmer, like tape or tubes). 0 only adds odd harmonics.
+	Bias float64
+	// Tilt (in dB) brightens the saturated signal when positive (like an exciter), or darkens it when negative (like tape),
+	// by boosting the frequencies above 800Hz and cutting the ones below it (by half of the tilt each).
+	Tilt float64
+	// Mix is the level of the saturated signal, between 0 and 1 (default: 1, the saturated signal only),
+	// the rest is the dry signal.
+	Mix float64
+}
+
+func (c *SaturationConfig) setDefaults() {
+	if c.Drive <= 0 {
+		c.Drive = 2
+	}
+	c.Bias = math.Max(0, math.Min(1, c.Bias))
+	if c.Mix <= 0 || c.Mix > 1 {
+		c.Mix = 1
+	}
+}
+
+// Saturation softly compresses the peaks of its input (with a hyperbolic tangent curve),
+// adding harmonics that warm up sterile sounds like pure sines,
+// then tilts the spectrum of the result.
+// The peaks of full-scale inputs stay at full scale (before tilting).
+type Saturation struct {
+	config    SaturationConfig
+	normalize float64 // gain restoring the level of full-scale inputs
+	offset    float64 // output of the curve for silence (removed)
+	tiltLow   float64 // gains of the frequencies below and above the pivot
+	tiltHigh  float64
+	lowPass   float64 // one-pole smoothing of the tilt
+	low       float64
+	dc        float64 // one-pole smoothing of the DC blocker
+	lastIn    float64
+	lastOut   float64
+}
+
+// NewSaturation creates a saturation for the sample rate.
+func NewSaturation(sampleRate int, config SaturationConfig) *Saturation {
+	config.setDefaults()
+	offset := math.Tanh(config.Drive * config.Bias)
+	// Outputs of the curve for full-scale inputs
+	positive, negative := math.Tanh(config.Drive*(1+config.Bias))-offset, offset-math.Tanh(config.Drive*(config.Bias-1))
+	gain := math.Pow(10, config.Tilt/40)
+	return &Saturation{
+		config:    config,
+		offset:    offset,
+		normalize: 1 / math.Max(positive, negative),
+		tiltLow:   1 / gain,
+		tiltHigh:  gain,
+		lowPass:   1 - math.Exp(-2*math.Pi*tiltPivot/float64(sampleRate)),
+		dc:        math.Exp(-2 * math.Pi * saturationDCCutoff / float64(sampleRate)),
+	}
+}
+
+func (s *Saturation) Process(in float64) float64 {
+	v := (math.Tanh(s.config.Drive*(in+s.config.Bias)) - s.offset) * s.normalize
+
+	// The asymmetry of the curve shifts the signal, which is removed (by a one-pole high-pass filter)
+	if s.config.Bias != 0 {
+		out := v - s.lastIn + s.dc*s.lastOut
+		s.lastIn, s.lastOut = v, out
+		v = out
+	}
+
+	// Tilt: the frequencies below the pivot (one-pole low-pass) and the ones above it are scaled separately
+	s.low += (v - s.low) * s.lowPass
+	v = s.low*s.tiltLow + (v-s.low)*s.tiltHigh
+
+	return in*(1-s.config.Mix) + v*s.config.Mix
+}
+
+func (s *Saturation) Reset() { s.low, s.lastIn, s.lastOut = 0, 0, 0 }
@@ -0,0 +1,181 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Grains of spectral effects (about 46ms at 44100Hz), overlapping by three quarters.
+const (
+	spectralSize = 2048
+	spectralHop  = spectralSize / 4
+)
+
+// SpectralFreeze returns a node sustaining the spectrum of the source while the freeze wave is positive
+// (like a gate), for ambient pads and drones made of any sound: for example, freezing a chord or a breath.
+// The frozen spectrum keeps the frequencies of its partials (their phases keep advancing at their speed),
+// the source is heard again once the freeze wave isn't positive.
+func SpectralFreeze(src Node, freeze wave.Wave, sampleRate int) Node {
+	frozen := false
+	held := make([]float64, spectralSize/2+1)     // frozen magnitudes
+	advance := make([]float64, spectralSize/2+1)  // phase advance of each bin between two grains
+	previous := make([]float64, spectralSize/2+1) // phases of the previous grain
+	phases := make([]float64, spectralSize/2+1)   // phases of the frozen grains
+	n := newSpectralNode(src, sampleRate, func(x time.Duration, magnitudes, p []float64) {
+		if freeze(x) <= 0 {
+			frozen = false
+			copy(previous, p)
+			return
+		}
+		if !frozen {
+			frozen = true
+			copy(held, magnitudes)
+			copy(phases, p)
+			for k := range advance {
+				advance[k] = p[k] - previous[k]
+			}
+		}
+		for k := range phases {
+			phases[k] = math.Mod(phases[k]+advance[k], 2*math.Pi)
+		}
+		copy(magnitudes, held)
+		copy(p, phases)
+		copy(previous, phases)
+	})
+	n.reset = func() { frozen = false }
+	return n
+}
+
+// SpectralBlur returns a node smearing the spectrum of the source over time:
+// each frequency of the source fades in and out slowly, for washed-out ambient textures.
+// The blur wave is the time (in seconds) frequencies take to (mostly) reach their level,
+// 0 (or less) doesn't blur.
+func SpectralBlur(src Node, blur wave.Wave, sampleRate int) Node {
+	blurred := make([]float64, spectralSize/2+1)
+	hop := float64(spectralHop) / float64(sampleRate) // in seconds
+	n := newSpectralNode(src, sampleRate, func(x time.Duration, magnitudes, phases []float64) {
+		keep := 0.0 // part of the previous magnitudes kept
+		if b := blur(x); b > 0 {
+			keep = math.Exp(-4.6 * hop / b)
+		}
+		for k, m := range magnitudes {
+			blurred[k] = blurred[k]*keep + m*(1-keep)
+			magnitudes[k] = blurred[k]
+		}
+	})
+	n.reset = func() {
+		for k := range blurred {
+			blurred[k] = 0
+		}
+	}
+	return n
+}
+
+// spectralNode processes a source in the frequency domain, grain by grain (short-time Fourier transform),
+// and overlaps the processed grains.
+// The source is processed ahead of the output (by a grain), so the output isn't delayed.
+type spectralNode struct {
+	src        Node
+	sampleRate int
+	// process modifies the spectrum of a grain starting at x (from 0Hz to half the sample rate).
+	process func(x time.Duration, magnitudes, phases []float64)
+	// reset clears the state of the processing (optional).
+	reset func()
+
+	window             []float64
+	input, acc         []float64 // input and output frames of the current grain
+	bins               []complex128
+	magnitudes, phases []float64
+	grain              int // index of the current grain (starting at frame grain*spectralHop)
+	next               int // index of the next output frame
+}
+
+func newSpectralNode(src Node, sampleRate int, process func(x time.Duration, magnitudes, phases []float64)) *spectralNode {
+	n := &spectralNode{
+		src:        src,
+		sampleRate: sampleRate,
+		process:    process,
+		window:     make([]float64, spectralSize),
+		input:      make([]float64, spectralSize),
+		acc:        make([]float64, spectralSize),
+		bins:       make([]complex128, spectralSize),
+		magnitudes: make([]float64, spectralSize/2+1),
+		phases:     make([]float64, spectralSize/2+1),
+		next:       -1,
+	}
+	for i := range n.window {
+		n.window[i] = analyze.Hann(i, spectralSize)
+	}
+	return n
+}
+
+func (n *spectralNode) Process(x time.Duration) float64 {
+	// Index of the frame at x (frames start at i*time.Second/sampleRate, rounded down to the nanosecond)
+	i := int((int64(x)*int64(n.sampleRate) + int64(time.Second) - 1) / int64(time.Second))
+	if i != n.next {
+		n.restart(i)
+	}
+	if i == (n.grain+1)*spectralHop {
+		n.addGrain()
+	}
+	n.next = i + 1
+	return n.acc[i-n.grain*spectralHop]
+}
+
+func (n *spectralNode) Reset() {
+	n.src.Reset()
+	if n.reset != nil {
+		n.reset()
+	}
+	n.next = -1
+}
+
+// restart starts overlapping grains at an output frame (the grains before it are silent).
+func (n *spectralNode) restart(i int) {
+	for j := range n.acc {
+		n.input[j], n.acc[j] = 0, 0
+	}
+	n.grain = floorDiv(i, spectralHop) - spectralSize/spectralHop - 1
+	for g := 0; g <= spectralSize/spectralHop; g++ {
+		n.addGrain()
+	}
+}
+
+// addGrain reads the frames of the next grain, processes it and overlaps it with the current one.
+func (n *spectralNode) addGrain() {
+	n.grain++
+	copy(n.acc, n.acc[spectralHop:])
+	copy(n.input, n.input[spectralHop:])
+	start := n.grain * spectralHop
+	for j := spectralSize - spectralHop; j < spectralSize; j++ {
+		n.acc[j], n.input[j] = 0, 0
+		if start+j >= 0 {
+			n.input[j] = n.src.Process(frameOffset(start+j, n.sampleRate))
+		}
+	}
+
+	for j, v := range n.input {
+		n.bins[j] = complex(v*n.window[j], 0)
+	}
+	analyze.FFT(n.bins)
+	for k := range n.magnitudes {
+		n.magnitudes[k], n.phases[k] = cmplx.Polar(n.bins[k])
+	}
+	n.process(frameOffset(start, n.sampleRate), n.magnitudes, n.phases)
+	for k := range n.magnitudes {
+		n.bins[k] = cmplx.Rect(n.magnitudes[k], n.phases[k])
+		if k > 0 && k < spectralSize/2 {
+			n.bins[spectralSize-k] = cmplx.Conj(n.bins[k]) // the spectrum of a real signal is symmetric
+		}
+	}
+	analyze.IFFT(n.bins)
+
+	// Hann windows overlapping by three quarters (for analysis and synthesis) sum to 1.5
+	for j, v := range n.bins {
+		n.acc[j] += real(v) * n.window[j] / 1.5
+	}
+}
@@ -0,0 +1,147 @@
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// TimeStretch returns a node playing the source faster (speed > 1) or slower (speed < 1)
+// without changing its pitch, unlike wave.Speed: for example to match the tempo of an imported loop
+// to the tempo of a song (speed = song tempo / loop tempo).
+// Speeds that aren't positive (or aren't finite) play the source at its speed.
+//
+// It uses WSOLA (waveform similarity overlap-add): short grains of the source (about 23ms) are overlapped,
+// each one read around its position in the source where it best continues the previous one (so no phase is cancelled).
+// Transients can be smeared or repeated at extreme speeds.
+// The source is read out of order (ahead and around the played position), so it shouldn't hold state.
+func TimeStretch(src wave.Wave, sampleRate int, speed float64) Node {
+	size := 2 * int(math.Round(0.0116*float64(sampleRate))) // about 1024 frames at 44100Hz
+	if size < 4 {
+		size = 4
+	}
+	s := &timeStretch{
+		src:        src,
+		sampleRate: sampleRate,
+		speed:      stretchSpeed(speed),
+		size:       size,
+		hop:        size / 2,
+		tolerance:  size / 4,
+		window:     make([]float64, size),
+		acc:        make([]float64, size),
+		natural:    make([]float64, size),
+		candidates: make([]float64, size+size/2),
+	}
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size)) // grains at half overlap sum to 1
+	}
+	s.Reset()
+	return s
+}
+
+type timeStretch struct {
+	src                  wave.Wave
+	sampleRate           int
+	speed                float64
+	size, hop, tolerance int       // grain size, output hop between grains, search range (in frames)
+	window               []float64 // weights of a grain
+	acc                  []float64 // output frames of the current grain (overlapped with the next one)
+	natural, candidates  []float64 // buffers of the grain search
+	next                 int       // index of the next output frame
+	grain                int       // index of the current grain (starting at output frame grain*hop)
+	previous             int       // source frame of the current grain
+}
+
+func (s *timeStretch) Process(x time.Duration) float64 {
+	// Index of the frame at x (frames start at i*time.Second/sampleRate, rounded down to the nanosecond)
+	n := int((int64(x)*int64(s.sampleRate) + int64(time.Second) - 1) / int64(time.Second))
+	if n != s.next {
+		s.restart(n)
+	}
+	if n == (s.grain+1)*s.hop {
+		s.addGrain(true)
+	}
+	s.next = n + 1
+	return s.acc[n-s.grain*s.hop]
+}
+
+func (s *timeStretch) Reset() { s.restart(0) }
+
+// restart starts overlapping grains at an output frame, without searching the position of the first one.
+func (s *timeStretch) restart(n int) {
+	for i := range s.acc {
+		s.acc[i] = 0
+	}
+	s.grain = floorDiv(n, s.hop) - 2
+	s.addGrain(false)
+	s.addGrain(true)
+	s.next = n
+}
+
+// nominal returns the source frame of a grain at the stretched speed.
+func (s *timeStretch) nominal(grain int) int { return int(math.Round(float64(grain*s.hop) * s.speed)) }
+
+// addGrain overlaps the next grain with the end of the current one.
+func (s *timeStretch) addGrain(search bool) {
+	s.grain++
+	copy(s.acc, s.acc[s.hop:])
+	for i := s.size - s.hop; i < s.size; i++ {
+		s.acc[i] = 0
+	}
+
+	// The best grain continues the source after the previous one (its natural continuation)
+	position, best := s.nominal(s.grain), s.tolerance
+	s.read(s.candidates, position-s.tolerance)
+	if search {
+		s.read(s.natural, s.previous+s.hop)
+		bestScore := math.Inf(-1)
+		for offset := 0; offset <= 2*s.tolerance; offset++ {
+			score := 0.0
+			for i := 0; i < s.size; i += 2 { // every other frame is precise enough and twice as fast
+				score += s.natural[i] * s.candidates[offset+i]
+			}
+			if score > bestScore {
+				best, bestScore = offset, score
+			}
+		}
+	}
+	s.previous = position + best - s.tolerance
+
+	for i, w := range s.window {
+		s.acc[i] += w * s.candidates[best+i]
+	}
+}
+
+// read evaluates consecutive frames of the source (silent before its start).
+func (s *timeStretch) read(dst []float64, first int) {
+	for i := range dst {
+		if first+i < 0 {
+			dst[i] = 0
+		} else {
+			dst[i] = s.src(frameOffset(first+i, s.sampleRate))
+		}
+	}
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+// StretchClip time-stretches a clip (see TimeStretch), its duration is divided by the speed.
+// The clip is stateful (see wave.Wave).
+func StretchClip(c wave.Clip, sampleRate int, speed float64) wave.Clip {
+	speed = stretchSpeed(speed)
+	return wave.NewClip(ToWave(TimeStretch(c.Wave, sampleRate, speed)), time.Duration(float64(c.Duration)/speed))
+}
+
+// stretchSpeed replaces invalid speeds with 1.
+func stretchSpeed(speed float64) float64 {
+	if speed <= 0 || math.IsNaN(speed) || math.IsInf(speed, 0) {
+		return 1
+	}
+	return speed
+}
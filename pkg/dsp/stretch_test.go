@@ -0,0 +1,107 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestTimeStretchIdentity(t *testing.T) {
+	const sampleRate = 44100
+	src := func(x time.Duration) float64 {
+		s := x.Seconds()
+		return 0.5*math.Sin(2*math.Pi*220*s) + 0.25*math.Sin(2*math.Pi*3*330*s)
+	}
+	got := audio.FrameRange(ToWave(TimeStretch(src, sampleRate, 1)), sampleRate, 0, sampleRate/2)
+	want := audio.FrameRange(src, sampleRate, 0, sampleRate/2)
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("frame %d = %v, want %v (the source at speed 1)", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTimeStretch(t *testing.T) {
+	const sampleRate = 44100
+	// 220Hz during the first second, then 330Hz
+	src := func(x time.Duration) float64 {
+		if x < time.Second {
+			return 0.5 * math.Sin(2*math.Pi*220*x.Seconds())
+		}
+		return 0.5 * math.Sin(2*math.Pi*330*x.Seconds())
+	}
+	tests := []struct {
+		speed float64
+	}{
+		{speed: 2},
+		{speed: 1.25},
+		{speed: 0.8},
+		{speed: 0.5},
+	}
+	for _, tt := range tests {
+		out := audio.FrameRange(ToWave(TimeStretch(src, sampleRate, tt.speed)), sampleRate, 0, int(2/tt.speed*sampleRate))
+		change := int(1 / tt.speed * sampleRate) // the frame where the source changes, once stretched
+		for _, at := range []struct {
+			start     int
+			frequency float64
+		}{
+			{start: change / 4, frequency: 220},
+			{start: change + change/4, frequency: 330},
+		} {
+			// The pitch doesn't change
+			frames := out[at.start : at.start+4096]
+			p, ok := analyze.DetectPitch(frames, sampleRate, analyze.PitchConfig{})
+			if !ok || math.Abs(p.Frequency-at.frequency) > 1 {
+				t.Errorf("speed %v: pitch at frame %d = %+v (found: %v), want %vHz", tt.speed, at.start, p, ok, at.frequency)
+			}
+			// Nor the level (no phase is cancelled)
+			if rms := analyze.Measure(frames, sampleRate, 1).RMS; math.Abs(rms-0.5/math.Sqrt2) > 0.02 {
+				t.Errorf("speed %v: RMS at frame %d = %v, want %v", tt.speed, at.start, rms, 0.5/math.Sqrt2)
+			}
+		}
+	}
+}
+
+func TestTimeStretchSeek(t *testing.T) {
+	const sampleRate = 44100
+	src := wave.OscillateSine(wave.Const(220))
+	n := TimeStretch(src, sampleRate, 1.5)
+	first := audio.FrameRange(ToWave(n), sampleRate, 0, 1000)
+
+	// Playing from another position, then seeking back, renders the same frames
+	_ = audio.FrameRange(ToWave(n), sampleRate, 20000, 21000)
+	again := audio.FrameRange(ToWave(n), sampleRate, 0, 1000)
+	for i := range first {
+		if again[i] != first[i] {
+			t.Fatalf("frame %d after seeking back = %v, want %v", i, again[i], first[i])
+		}
+	}
+}
+
+func TestStretchClip(t *testing.T) {
+	c := wave.NewClip(wave.Const(1), 3*time.Second)
+	tests := []struct {
+		speed float64
+		want  time.Duration
+	}{
+		{speed: 1.5, want: 2 * time.Second},
+		{speed: 0.5, want: 6 * time.Second},
+		{speed: 0, want: 3 * time.Second}, // invalid speeds play at the original speed
+		{speed: -2, want: 3 * time.Second},
+		{speed: math.NaN(), want: 3 * time.Second},
+		{speed: math.Inf(1), want: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		stretched := StretchClip(c, 44100, tt.speed)
+		if stretched.Duration != tt.want {
+			t.Errorf("speed %v: duration = %s, want %s", tt.speed, stretched.Duration, tt.want)
+		}
+		if got := stretched.Wave(time.Second); math.Abs(got-1) > 1e-9 {
+			t.Errorf("speed %v: wave at 1s = %v, want 1", tt.speed, got)
+		}
+	}
+}
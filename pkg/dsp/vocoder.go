@@ -0,0 +1,80 @@
+package dsp
+
+import (
+	"math"
+	"time"
+)
+
+// VocoderConfig describes the bands of a vocoder.
+type VocoderConfig struct {
+	// Bands is the number of frequency bands (default: 16): more bands make speech more intelligible.
+	Bands int
+	// MinFrequency and MaxFrequency are the range of the bands (default: 100Hz to 8000Hz).
+	MinFrequency, MaxFrequency float64
+	// Release is how fast the level of a band falls after the modulator gets quieter in the band (default: 20ms).
+	Release time.Duration
+}
+
+func (c *VocoderConfig) setDefaults() {
+	if c.Bands <= 0 {
+		c.Bands = 16
+	}
+	if c.MinFrequency <= 0 {
+		c.MinFrequency = 100
+	}
+	if c.MaxFrequency <= c.MinFrequency {
+		c.MaxFrequency = math.Max(8000, 2*c.MinFrequency)
+	}
+	if c.Release <= 0 {
+		c.Release = 20 * time.Millisecond
+	}
+}
+
+// Vocoder returns a node imposing the spectral envelope of the modulator (for example: an imported voice)
+// onto the carrier (for example: a saw pad), for robotic voices and talking synths.
+//
+// Both signals are split by filter banks (see FilterBank):
+// each band of the carrier is played at the level of the same band of the modulator.
+// Carriers rich in harmonics (saw, noise) work best, since a band of the carrier without content stays silent.
+func Vocoder(carrier, modulator Node, sampleRate int, config VocoderConfig) Node {
+	config.setDefaults()
+	v := &vocoder{
+		carrier:        carrier,
+		modulator:      modulator,
+		carriers:       NewFilterBank(sampleRate, config.Bands, config.MinFrequency, config.MaxFrequency),
+		modulators:     NewFilterBank(sampleRate, config.Bands, config.MinFrequency, config.MaxFrequency),
+		carrierBands:   make([]float64, config.Bands),
+		modulatorBands: make([]float64, config.Bands),
+	}
+	for i := 0; i < config.Bands; i++ {
+		v.levels = append(v.levels, NewEnvelopeFollower(sampleRate, time.Millisecond, config.Release))
+	}
+	return v
+}
+
+type vocoder struct {
+	carrier, modulator           Node
+	carriers, modulators         *FilterBank
+	levels                       []*EnvelopeFollower // of each band of the modulator
+	carrierBands, modulatorBands []float64           // outputs of the filter banks
+}
+
+func (v *vocoder) Process(x time.Duration) float64 {
+	v.carriers.Process(v.carrier.Process(x), v.carrierBands)
+	v.modulators.Process(v.modulator.Process(x), v.modulatorBands)
+	out := 0.0
+	for i, level := range v.levels {
+		out += v.carrierBands[i] * level.Process(v.modulatorBands[i])
+	}
+	return out
+}
+
+func (v *vocoder) Reset() {
+	v.carrier.Reset()
+	v.modulator.Reset()
+	v.carriers.Reset()
+	v.modulators.Reset()
+	for _, level := range v.levels {
+		level.Reset()
+	}
+}
@@ -0,0 +1,46 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestVocoder(t *testing.T) {
+	const sampleRate = 44100
+	carrier := FromWave(wave.Oscillate(wave.Sawtooth, wave.Const(110), 0))
+	// A sine during 200ms, then silence
+	modulator := FromWave(func(x time.Duration) float64 {
+		if x >= 200*time.Millisecond {
+			return 0
+		}
+		return math.Sin(2 * math.Pi * 1000 * x.Seconds())
+	})
+	frames := audio.FrameRange(ToWave(Vocoder(carrier, modulator, sampleRate, VocoderConfig{})), sampleRate, 0, sampleRate/2)
+	rms := func(from, to time.Duration) float64 {
+		sum, n := 0.0, 0
+		for i := int(from.Seconds() * sampleRate); i < int(to.Seconds()*sampleRate); i++ {
+			sum += frames[i] * frames[i]
+			n++
+		}
+		return math.Sqrt(sum / float64(n))
+	}
+	tests := []struct {
+		name     string
+		from, to time.Duration
+		min, max float64
+	}{
+		{name: "while the modulator plays", from: 100 * time.Millisecond, to: 200 * time.Millisecond, min: 0.01, max: 1},
+		// The default release is 20ms
+		{name: "after the release", from: 240 * time.Millisecond, to: 300 * time.Millisecond, max: 1e-3},
+		{name: "long after the release", from: 400 * time.Millisecond, to: 500 * time.Millisecond, max: 1e-6},
+	}
+	for _, tt := range tests {
+		if got := rms(tt.from, tt.to); got < tt.min || got > tt.max {
+			t.Errorf("%s: RMS = %v, want between %v and %v", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package dsp
+
+import (
+	"math"
+	"time"
+)
+
+// AutoWahConfig describes how an auto-wah follows its input.
+type AutoWahConfig struct {
+	// Sensitivity scales the level of the input (default: 2):
+	// the filter reaches the top of its range when the scaled level reaches 1.
+	Sensitivity float64
+	// MinFrequency and MaxFrequency are the range of the sweep (default: 300Hz to 3000Hz).
+	MinFrequency, MaxFrequency float64
+	// Q is the resonance of the filter (default: 4), higher values sound more vocal.
+	Q float64
+	// Attack and Release are how fast the filter opens and closes (default: 10ms and 150ms).
+	Attack, Release time.Duration
+	// Mix is the level of the filtered signal, between 0 and 1 (default: 1, the filtered signal only),
+	// the rest is the dry signal.
+	Mix float64
+}
+
+func (c *AutoWahConfig) setDefaults() {
+	if c.Sensitivity <= 0 {
+		c.Sensitivity = 2
+	}
+	if c.MinFrequency <= 0 {
+		c.MinFrequency = 300
+	}
+	if c.MaxFrequency <= c.MinFrequency {
+		c.MaxFrequency = math.Max(3000, 2*c.MinFrequency)
+	}
+	if c.Q <= 0 {
+		c.Q = 4
+	}
+	if c.Attack <= 0 {
+		c.Attack = 10 * time.Millisecond
+	}
+	if c.Release <= 0 {
+		c.Release = 150 * time.Millisecond
+	}
+	if c.Mix <= 0 || c.Mix > 1 {
+		c.Mix = 1
+	}
+}
+
+// AutoWah is a band-pass filter sweeping up as its input gets louder (envelope-following wah),
+// for funky basses, guitars and plucks: each note opens the filter, which closes as the note fades.
+type AutoWah struct {
+	config     AutoWahConfig
+	sampleRate int
+	follower   *EnvelopeFollower
+	filter     *Biquad
+}
+
+// NewAutoWah creates an auto-wah for the sample rate.
+func NewAutoWah(sampleRate int, config AutoWahConfig) *AutoWah {
+	config.setDefaults()
+	return &AutoWah{
+		config:     config,
+		sampleRate: sampleRate,
+		follower:   NewEnvelopeFollower(sampleRate, config.Attack, config.Release),
+		filter:     NewBandPass(sampleRate, config.MinFrequency, config.Q),
+	}
+}
+
+func (w *AutoWah) Process(in float64) float64 {
+	t := math.Min(1, w.follower.Process(in)*w.config.Sensitivity)
+	w.filter.setBandPass(w.sampleRate, w.config.MinFrequency*math.Pow(w.config.MaxFrequency/w.config.MinFrequency, t), w.config.Q)
+	return in*(1-w.config.Mix) + w.filter.Process(in)*w.config.Mix
+}
+
+func (w *AutoWah) Reset() {
+	w.follower.Reset()
+	w.filter.Reset()
+}
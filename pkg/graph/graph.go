@@ -0,0 +1,53 @@
+package graph
+
+import "sort"
+
+// Node is a processing unit of a patch (an oscillator, a filter, a mixer, etc.).
+type Node struct {
+	// Name identifies the node in the graph (for example: "bass-filter").
+	Name string `json:"name"`
+	// Kind is the type of processing (for example: "sine", "lowpass").
+	Kind string `json:"kind"`
+	// Params holds the static parameter values of the node (for example: "cutoff": 1200).
+	Params map[string]float64 `json:"params,omitempty"`
+}
+
+// Connection sends the output of a node to a named input of another node.
+type Connection struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Input is the name of the input on the destination node (for example: "in", "frequency" or "cutoff").
+	Input string `json:"input"`
+}
+
+// Graph is an introspectable representation of a patch:
+// nodes, their parameters and how they are connected.
+// It can be encoded to JSON, and compiled to a playable patch (see Compile).
+type Graph struct {
+	Nodes       []Node       `json:"nodes"`
+	Connections []Connection `json:"connections"`
+	// Output is the name of the node producing the output of the patch.
+	Output string `json:"output"`
+}
+
+// Node returns the node with the given name.
+func (g *Graph) Node(name string) (Node, bool) {
+	for _, n := range g.Nodes {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return Node{}, false
+}
+
+// Inputs returns the connections going to the node, ordered by input name.
+func (g *Graph) Inputs(name string) []Connection {
+	out := []Connection{}
+	for _, c := range g.Connections {
+		if c.To == name {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Input < out[j].Input })
+	return out
+}
@@ -0,0 +1,236 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/dsp"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Unit evaluates a node of a patch, one sample at a time.
+type Unit interface {
+	// Process returns the output of the node for the current sample.
+	Process(x time.Duration, in Inputs) float64
+}
+
+// FeedbackUnit is a unit whose output only depends on past inputs (for example: a delay).
+// Connections from such a unit can close a feedback loop.
+type FeedbackUnit interface {
+	// Output returns the output of the node for the current sample (before its inputs are known).
+	Output(x time.Duration) float64
+	// Input receives the inputs of the node for the current sample.
+	Input(x time.Duration, in Inputs)
+}
+
+// Inputs holds the values received by a node for the current sample, by input name.
+// Several connections to the same input are summed.
+type Inputs map[string]float64
+
+// Value returns the value of the input, or the fallback (usually a parameter of the node) if it isn't connected.
+func (in Inputs) Value(name string, fallback float64) float64 {
+	if v, ok := in[name]; ok {
+		return v
+	}
+	return fallback
+}
+
+// UnitFactory creates the unit of a node from its parameters.
+type UnitFactory func(params map[string]float64, sampleRate int) (any, error)
+
+var kinds = map[string]UnitFactory{}
+
+// RegisterKind makes a node kind available to patches, the factory should return a Unit or a FeedbackUnit.
+// It should be called before patches are compiled (for example: in an init function).
+func RegisterKind(name string, factory UnitFactory) { kinds[name] = factory }
+
+// RegisterParam makes a real-time parameter available as a node kind producing its smoothed value,
+// so a patch can be controlled while it is playing (for example: by a MIDI controller).
+func RegisterParam(name string, p *wave.Param) {
+	RegisterKind(name, func(params map[string]float64, sampleRate int) (any, error) {
+		w := p.Wave()
+		return unitFunc(func(x time.Duration, in Inputs) float64 { return w(x) }), nil
+	})
+}
+
+// Patch is a compiled graph: it evaluates all nodes for each sample, in topological order.
+// It implements dsp.Node.
+type Patch struct {
+	graph      *Graph
+	sampleRate int
+	order      []string // names of the nodes, in evaluation order
+	inputs     map[string][]Connection
+	nodes      []patchNode // nodes in evaluation order
+	outputs    []float64   // outputs of the nodes for the current sample, by index in nodes
+	output     int         // index of the output node in nodes
+	err        error
+}
+
+// patchNode is a node ready to be evaluated: its connections are resolved to the indexes of their source nodes.
+type patchNode struct {
+	unit     Unit
+	feedback FeedbackUnit
+	sources  []patchSource
+	in       Inputs // reused for each sample
+}
+
+type patchSource struct {
+	input string
+	from  int
+}
+
+// Compile creates the units of the nodes and sorts them so each node is evaluated after its inputs.
+// Feedback loops are only allowed through feedback units (like "delay"), other cycles are reported as errors.
+func (g *Graph) Compile(sampleRate int) (*Patch, error) {
+	p := &Patch{graph: g, sampleRate: sampleRate, inputs: map[string][]Connection{}}
+	if _, ok := g.Node(g.Output); !ok {
+		return nil, fmt.Errorf("output node not found: %q", g.Output)
+	}
+	for _, c := range g.Connections {
+		if _, ok := g.Node(c.From); !ok {
+			return nil, fmt.Errorf("connection from unknown node: %q", c.From)
+		}
+		if _, ok := g.Node(c.To); !ok {
+			return nil, fmt.Errorf("connection to unknown node: %q", c.To)
+		}
+		p.inputs[c.To] = append(p.inputs[c.To], c)
+	}
+	units, err := p.createUnits()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort nodes topologically (Kahn's algorithm),
+	// ignoring the connections from feedback units since their output is known before their inputs.
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, n := range g.Nodes {
+		pending[n.Name] += 0
+		for _, c := range p.inputs[n.Name] {
+			if _, ok := units[c.From].(FeedbackUnit); ok {
+				continue
+			}
+			pending[n.Name]++
+			dependents[c.From] = append(dependents[c.From], n.Name)
+		}
+	}
+	ready := []string{}
+	for _, n := range g.Nodes {
+		if pending[n.Name] == 0 {
+			ready = append(ready, n.Name)
+		}
+	}
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		p.order = append(p.order, name)
+		for _, dep := range dependents[name] {
+			if pending[dep]--; pending[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+	if len(p.order) < len(g.Nodes) {
+		cycle := []string{}
+		for name, count := range pending {
+			if count > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("cycle without delay between nodes: %s", strings.Join(cycle, ", "))
+	}
+	p.resolve(units)
+	return p, nil
+}
+
+// createUnits creates the units of the nodes, by node name.
+func (p *Patch) createUnits() (map[string]any, error) {
+	units := map[string]any{}
+	for _, n := range p.graph.Nodes {
+		factory, ok := kinds[n.Kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown kind of node %q: %q", n.Name, n.Kind)
+		}
+		u, err := factory(n.Params, p.sampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("create node %q: %w", n.Name, err)
+		}
+		switch u.(type) {
+		case Unit, FeedbackUnit:
+		default:
+			return nil, fmt.Errorf("create node %q: kind %q doesn't create a unit", n.Name, n.Kind)
+		}
+		units[n.Name] = u
+	}
+	return units, nil
+}
+
+// resolve places the units in evaluation order and resolves the connections to node indexes,
+// so nothing is looked up by name (or allocated) while samples are processed.
+func (p *Patch) resolve(units map[string]any) {
+	index := map[string]int{}
+	for i, name := range p.order {
+		index[name] = i
+	}
+	p.nodes = make([]patchNode, len(p.order))
+	p.outputs = make([]float64, len(p.order))
+	for i, name := range p.order {
+		n := patchNode{in: Inputs{}}
+		switch u := units[name].(type) {
+		case FeedbackUnit:
+			n.feedback = u
+		case Unit:
+			n.unit = u
+		}
+		for _, c := range p.inputs[name] {
+			n.sources = append(n.sources, patchSource{input: c.Input, from: index[c.From]})
+		}
+		p.nodes[i] = n
+	}
+	p.output = index[p.graph.Output]
+}
+
+// Process evaluates all nodes for the sample at the given position and returns the output of the patch.
+func (p *Patch) Process(x time.Duration) float64 {
+	for i, n := range p.nodes {
+		if n.feedback != nil {
+			p.outputs[i] = n.feedback.Output(x)
+		}
+	}
+	for i := range p.nodes {
+		n := &p.nodes[i]
+		for _, s := range n.sources {
+			n.in[s.input] = 0
+		}
+		for _, s := range n.sources {
+			n.in[s.input] += p.outputs[s.from]
+		}
+		if n.feedback != nil {
+			n.feedback.Input(x, n.in)
+		} else {
+			p.outputs[i] = n.unit.Process(x, n.in)
+		}
+	}
+	return p.outputs[p.output]
+}
+
+// Reset recreates the units of the nodes, clearing their state.
+// If a unit can't be recreated, the patch keeps its current units and Err returns the error.
+func (p *Patch) Reset() {
+	units, err := p.createUnits()
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.err = nil
+	p.resolve(units)
+}
+
+// Err returns the error of the last Reset, if the units of the nodes couldn't be recreated.
+func (p *Patch) Err() error { return p.err }
+
+// Wave returns a wave playing the patch (see dsp.ToWave).
+func (p *Patch) Wave() wave.Wave { return dsp.ToWave(p) }
@@ -0,0 +1,167 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   *Graph
+		order   []string
+		wantErr bool
+	}{
+		{
+			name: "nodes after their inputs",
+			graph: &Graph{
+				Nodes: []Node{{Name: "out", Kind: "sum"}, {Name: "gain", Kind: "gain"}, {Name: "a", Kind: "const"}, {Name: "b", Kind: "const"}},
+				Connections: []Connection{
+					{From: "gain", To: "out", Input: "in"},
+					{From: "a", To: "gain", Input: "in"},
+					{From: "b", To: "out", Input: "in"},
+				},
+				Output: "out",
+			},
+			order: []string{"a", "b", "gain", "out"},
+		},
+		{
+			name: "cycle through a delay",
+			graph: &Graph{
+				Nodes:       []Node{{Name: "sum", Kind: "sum"}, {Name: "echo", Kind: "delay", Params: map[string]float64{"time": 0.1}}},
+				Connections: []Connection{{From: "sum", To: "echo", Input: "in"}, {From: "echo", To: "sum", Input: "in"}},
+				Output:      "sum",
+			},
+			order: []string{"sum", "echo"}, // the output of the delay is known before its input
+		},
+		{
+			name: "cycle without delay",
+			graph: &Graph{
+				Nodes:       []Node{{Name: "a", Kind: "sum"}, {Name: "b", Kind: "gain"}},
+				Connections: []Connection{{From: "a", To: "b", Input: "in"}, {From: "b", To: "a", Input: "in"}},
+				Output:      "a",
+			},
+			wantErr: true,
+		},
+		{name: "missing output", graph: &Graph{Nodes: []Node{{Name: "a", Kind: "const"}}, Output: "b"}, wantErr: true},
+		{name: "unknown kind", graph: &Graph{Nodes: []Node{{Name: "a", Kind: "reverb"}}, Output: "a"}, wantErr: true},
+		{
+			name:    "connection to an unknown node",
+			graph:   &Graph{Nodes: []Node{{Name: "a", Kind: "const"}}, Connections: []Connection{{From: "a", To: "b"}}, Output: "a"},
+			wantErr: true,
+		},
+		{name: "invalid parameter", graph: &Graph{Nodes: []Node{{Name: "a", Kind: "delay"}}, Output: "a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := tt.graph.Compile(10)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error: %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(p.order, tt.order) {
+			t.Errorf("%s: order = %v, want %v", tt.name, p.order, tt.order)
+		}
+	}
+}
+
+func TestPatchProcess(t *testing.T) {
+	const sampleRate = 10
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  []float64
+	}{
+		{
+			name: "summed inputs and parameters",
+			graph: &Graph{
+				Nodes: []Node{
+					{Name: "out", Kind: "gain", Params: map[string]float64{"gain": 0.5}},
+					{Name: "a", Kind: "const", Params: map[string]float64{"value": 1}},
+					{Name: "b", Kind: "const", Params: map[string]float64{"value": 2}},
+				},
+				Connections: []Connection{{From: "a", To: "out", Input: "in"}, {From: "b", To: "out", Input: "in"}},
+				Output:      "out",
+			},
+			want: []float64{1.5, 1.5, 1.5},
+		},
+		{
+			// The sum receives the constant and its own output one frame later
+			name: "feedback loop",
+			graph: &Graph{
+				Nodes: []Node{
+					{Name: "sum", Kind: "sum"},
+					{Name: "one", Kind: "const", Params: map[string]float64{"value": 1}},
+					{Name: "echo", Kind: "delay", Params: map[string]float64{"time": 0.1}},
+				},
+				Connections: []Connection{
+					{From: "one", To: "sum", Input: "in"},
+					{From: "echo", To: "sum", Input: "in"},
+					{From: "sum", To: "echo", Input: "in"},
+				},
+				Output: "sum",
+			},
+			want: []float64{1, 2, 3, 4},
+		},
+	}
+	for _, tt := range tests {
+		p, err := tt.graph.Compile(sampleRate)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// Twice, since resetting the patch restarts it
+		for run := 0; run < 2; run++ {
+			got := []float64{}
+			for i := range tt.want {
+				got = append(got, p.Process(time.Duration(i)*time.Second/sampleRate))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s (run %d): outputs = %v, want %v", tt.name, run, got, tt.want)
+			}
+			p.Reset()
+		}
+	}
+}
+
+func TestPatchResetError(t *testing.T) {
+	created := 0
+	RegisterKind("test-once", func(params map[string]float64, sampleRate int) (any, error) {
+		if created++; created > 1 {
+			return nil, errors.New("already created")
+		}
+		return unitFunc(func(x time.Duration, in Inputs) float64 { return 1 }), nil
+	})
+	defer delete(kinds, "test-once")
+
+	p, err := (&Graph{Nodes: []Node{{Name: "a", Kind: "test-once"}}, Output: "a"}).Compile(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Reset()
+	if p.Err() == nil {
+		t.Errorf("resetting didn't report the error of the unit")
+	}
+	if got := p.Process(0); got != 1 {
+		t.Errorf("output after a failed reset = %v, want 1 (the previous unit)", got)
+	}
+}
+
+func BenchmarkPatchProcess(b *testing.B) {
+	p, err := (&Graph{
+		Nodes: []Node{
+			{Name: "osc", Kind: "sine", Params: map[string]float64{"frequency": 440}},
+			{Name: "lfo", Kind: "sine", Params: map[string]float64{"frequency": 2}},
+			{Name: "out", Kind: "gain"},
+		},
+		Connections: []Connection{{From: "osc", To: "out", Input: "in"}, {From: "lfo", To: "out", Input: "gain"}},
+		Output:      "out",
+	}).Compile(44100)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Process(time.Duration(i) * time.Second / 44100)
+	}
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Report describes the signal chain of a patch, so collaborators can understand it without reading its code.
+type Report struct {
+	Output string `json:"output"`
+	// Sources are the nodes without inputs (oscillators, samples, etc.).
+	Sources []NodeReport `json:"sources"`
+	// Effects are the nodes processing other nodes, in processing order.
+	Effects []NodeReport `json:"effects"`
+	// Unused are the nodes that don't contribute to the output.
+	Unused []string `json:"unused,omitempty"`
+	// Routing lists all connections ("from -> to.input").
+	Routing []string `json:"routing"`
+}
+
+// NodeReport describes a node of the signal chain.
+type NodeReport struct {
+	Name   string             `json:"name"`
+	Kind   string             `json:"kind"`
+	Params map[string]float64 `json:"params,omitempty"`
+	// Inputs maps input names to the names of the nodes connected to them.
+	Inputs map[string][]string `json:"inputs,omitempty"`
+}
+
+// Report walks the graph from its output and describes its signal chain.
+func (g *Graph) Report() Report {
+	r := Report{Output: g.Output, Sources: []NodeReport{}, Effects: []NodeReport{}, Routing: []string{}}
+
+	// Visit nodes depth-first from the output, so nodes are listed after their inputs
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		inputs := g.Inputs(name)
+		for _, c := range inputs {
+			visit(c.From)
+		}
+
+		n, ok := g.Node(name)
+		if !ok {
+			n = Node{Name: name, Kind: "unknown"}
+		}
+		nr := NodeReport{Name: n.Name, Kind: n.Kind, Params: n.Params}
+		if len(inputs) == 0 {
+			r.Sources = append(r.Sources, nr)
+			return
+		}
+		nr.Inputs = map[string][]string{}
+		for _, c := range inputs {
+			nr.Inputs[c.Input] = append(nr.Inputs[c.Input], c.From)
+		}
+		r.Effects = append(r.Effects, nr)
+	}
+	if g.Output != "" {
+		visit(g.Output)
+	}
+
+	for _, n := range g.Nodes {
+		if !visited[n.Name] {
+			r.Unused = append(r.Unused, n.Name)
+		}
+	}
+	for _, c := range g.Connections {
+		r.Routing = append(r.Routing, c.From+" -> "+c.To+"."+c.Input)
+	}
+	return r
+}
+
+// JSON encodes the report as indented JSON.
+func (r Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Text returns a human-readable version of the report.
+func (r Report) Text() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Signal chain (output: %s)\n", r.Output)
+
+	b.WriteString("\nSources:\n")
+	for _, n := range r.Sources {
+		writeNodeReport(b, n)
+	}
+	b.WriteString("\nEffects:\n")
+	for _, n := range r.Effects {
+		writeNodeReport(b, n)
+	}
+	if len(r.Unused) > 0 {
+		b.WriteString("\nUnused: " + strings.Join(r.Unused, ", ") + "\n")
+	}
+	b.WriteString("\nRouting:\n")
+	for _, route := range r.Routing {
+		b.WriteString("  " + route + "\n")
+	}
+	return b.String()
+}
+
+func writeNodeReport(b *strings.Builder, n NodeReport) {
+	fmt.Fprintf(b, "  - %s (%s)", n.Name, n.Kind)
+	if len(n.Params) > 0 {
+		params := []string{}
+		for name, value := range n.Params {
+			params = append(params, fmt.Sprintf("%s=%g", name, value))
+		}
+		sort.Strings(params)
+		b.WriteString(": " + strings.Join(params, ", "))
+	}
+	b.WriteString("\n")
+
+	inputs := []string{}
+	for input := range n.Inputs {
+		inputs = append(inputs, input)
+	}
+	sort.Strings(inputs)
+	for _, input := range inputs {
+		fmt.Fprintf(b, "      %s <- %s\n", input, strings.Join(n.Inputs[input], ", "))
+	}
+}
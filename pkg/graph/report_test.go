@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testGraph() *Graph {
+	return &Graph{
+		Nodes: []Node{
+			{Name: "out", Kind: "gain", Params: map[string]float64{"gain": 0.5}},
+			{Name: "filter", Kind: "lowpass", Params: map[string]float64{"cutoff": 1200, "q": 0.7}},
+			{Name: "osc", Kind: "sawtooth", Params: map[string]float64{"frequency": 110}},
+			{Name: "lfo", Kind: "sine", Params: map[string]float64{"frequency": 2}},
+			{Name: "noise", Kind: "noise"},
+		},
+		Connections: []Connection{
+			{From: "filter", To: "out", Input: "in"},
+			{From: "osc", To: "filter", Input: "in"},
+			{From: "lfo", To: "filter", Input: "cutoff"},
+		},
+		Output: "out",
+	}
+}
+
+func TestReport(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  Report
+	}{
+		{
+			name:  "signal chain",
+			graph: testGraph(),
+			want: Report{
+				Output: "out",
+				Sources: []NodeReport{
+					{Name: "lfo", Kind: "sine", Params: map[string]float64{"frequency": 2}},
+					{Name: "osc", Kind: "sawtooth", Params: map[string]float64{"frequency": 110}},
+				},
+				Effects: []NodeReport{
+					{
+						Name: "filter", Kind: "lowpass", Params: map[string]float64{"cutoff": 1200, "q": 0.7},
+						Inputs: map[string][]string{"cutoff": {"lfo"}, "in": {"osc"}},
+					},
+					{Name: "out", Kind: "gain", Params: map[string]float64{"gain": 0.5}, Inputs: map[string][]string{"in": {"filter"}}},
+				},
+				Unused:  []string{"noise"},
+				Routing: []string{"filter -> out.in", "osc -> filter.in", "lfo -> filter.cutoff"},
+			},
+		},
+		{
+			name:  "missing output node",
+			graph: &Graph{Output: "out"},
+			want:  Report{Output: "out", Sources: []NodeReport{{Name: "out", Kind: "unknown"}}, Effects: []NodeReport{}, Routing: []string{}},
+		},
+		{
+			name:  "no output",
+			graph: &Graph{Nodes: []Node{{Name: "osc", Kind: "sine"}}},
+			want:  Report{Sources: []NodeReport{}, Effects: []NodeReport{}, Unused: []string{"osc"}, Routing: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.graph.Report(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: report = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReportText(t *testing.T) {
+	want := `Signal chain (output: out)
+
+Sources:
+  - lfo (sine): frequency=2
+  - osc (sawtooth): frequency=110
+
+Effects:
+  - filter (lowpass): cutoff=1200, q=0.7
+      cutoff <- lfo
+      in <- osc
+  - out (gain): gain=0.5
+      in <- filter
+
+Unused: noise
+
+Routing:
+  filter -> out.in
+  osc -> filter.in
+  lfo -> filter.cutoff
+`
+	if got := testGraph().Report().Text(); got != want {
+		t.Errorf("text =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	r := testGraph().Report()
+	b, err := r.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := Report{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, r) {
+		t.Errorf("decoded report = %+v, want %+v", decoded, r)
+	}
+
+	// Sources have no inputs, and empty fields are omitted
+	fields := map[string]any{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	source := fields["sources"].([]any)[0].(map[string]any)
+	if _, ok := source["inputs"]; ok {
+		t.Errorf("source %v has inputs", source)
+	}
+	if b, err := (&Graph{}).Report().JSON(); err != nil || string(b) != "{\n  \"output\": \"\",\n  \"sources\": [],\n  \"effects\": [],\n  \"routing\": []\n}" {
+		t.Errorf("report of an empty graph = %s (error: %v)", b, err)
+	}
+}
@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Built-in kinds of nodes:
+//   - "const": produces its "value" parameter.
+//   - "sine": sine oscillator, with a "frequency" (in Hz) parameter or input.
+//   - "gain": multiplies its "in" input by its "gain" parameter or input.
+//   - "sum": adds the values received on its "in" input.
+//   - "delay": repeats its "in" input after "time" seconds, it can close a feedback loop.
+func init() {
+	RegisterKind("const", func(params map[string]float64, sampleRate int) (any, error) {
+		return unitFunc(func(x time.Duration, in Inputs) float64 { return params["value"] }), nil
+	})
+	RegisterKind("sine", func(params map[string]float64, sampleRate int) (any, error) {
+		return &sineUnit{frequency: params["frequency"], last: -1}, nil
+	})
+	RegisterKind("gain", func(params map[string]float64, sampleRate int) (any, error) {
+		gain, ok := params["gain"]
+		if !ok {
+			gain = 1
+		}
+		return unitFunc(func(x time.Duration, in Inputs) float64 { return in["in"] * in.Value("gain", gain) }), nil
+	})
+	RegisterKind("sum", func(params map[string]float64, sampleRate int) (any, error) {
+		return unitFunc(func(x time.Duration, in Inputs) float64 { return in["in"] }), nil
+	})
+	RegisterKind("delay", func(params map[string]float64, sampleRate int) (any, error) {
+		size := int(params["time"] * float64(sampleRate))
+		if size < 1 {
+			return nil, fmt.Errorf("delay time should be at least one frame: %gs", params["time"])
+		}
+		return &delayUnit{buf: make([]float64, size)}, nil
+	})
+}
+
+type unitFunc func(x time.Duration, in Inputs) float64
+
+func (f unitFunc) Process(x time.Duration, in Inputs) float64 { return f(x, in) }
+
+// sineUnit accumulates its phase, so its frequency can be modulated without discontinuities.
+type sineUnit struct {
+	frequency float64
+	phase     float64
+	last      time.Duration
+}
+
+func (u *sineUnit) Process(x time.Duration, in Inputs) float64 {
+	if u.last >= 0 {
+		u.phase += in.Value("frequency", u.frequency) * (x - u.last).Seconds()
+		u.phase -= math.Floor(u.phase)
+	}
+	u.last = x
+	return math.Sin(2 * math.Pi * u.phase)
+}
+
+type delayUnit struct {
+	buf []float64
+	i   int
+}
+
+func (u *delayUnit) Output(x time.Duration) float64 { return u.buf[u.i] }
+
+func (u *delayUnit) Input(x time.Duration, in Inputs) {
+	u.buf[u.i] = in["in"]
+	u.i = (u.i + 1) % len(u.buf)
+}
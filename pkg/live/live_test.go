@@ -0,0 +1,75 @@
+package live
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestSwitch(t *testing.T) {
+	const fade = 100 * time.Millisecond
+	type set struct {
+		at  time.Duration
+		src wave.Wave
+	}
+	tests := []struct {
+		name string
+		sets []set
+		at   time.Duration
+		want float64
+	}{
+		{name: "initial", at: 50 * time.Millisecond, want: 1},
+		{name: "crossfading", sets: []set{{at: 0, src: wave.Const(3)}}, at: 50 * time.Millisecond, want: 2},
+		{name: "crossfaded", sets: []set{{at: 0, src: wave.Const(3)}}, at: fade, want: 3},
+		{
+			// The mix (2) is crossfaded with the new source (7)
+			name: "set while crossfading",
+			sets: []set{{at: 0, src: wave.Const(3)}, {at: 50 * time.Millisecond, src: wave.Const(7)}},
+			at:   75 * time.Millisecond,
+			want: 2 + (7-2)*0.25,
+		},
+		{
+			name: "set while crossfading, right after",
+			sets: []set{{at: 0, src: wave.Const(3)}, {at: 50 * time.Millisecond, src: wave.Const(7)}},
+			at:   50 * time.Millisecond,
+			want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSwitch(wave.Const(1), fade)
+			out := s.Wave()
+			for _, set := range tt.sets {
+				s.Set(set.src)
+				out(set.at)
+			}
+			if got := out(tt.at); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("output at %s = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+// raceEnabled is set when the tests are built with the race detector,
+// plugins built without it can't be loaded.
+var raceEnabled bool
+
+func TestLoadReloadsPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds plugins")
+	}
+	if raceEnabled {
+		t.Skip("plugins aren't built with the race detector")
+	}
+	for i := 0; i < 2; i++ {
+		w, err := Load("testdata/composition")
+		if err != nil {
+			t.Fatalf("load %d: %v", i, err)
+		}
+		if got := w(0); got != 0.5 {
+			t.Fatalf("load %d: composition = %v, want 0.5", i, got)
+		}
+	}
+}
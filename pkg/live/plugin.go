@@ -0,0 +1,144 @@
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"strings"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// CompositionSymbol is the name of the function that a composition plugin must export:
+//
+//	func Composition() wave.Wave
+const CompositionSymbol = "Composition"
+
+// BuildPlugin compiles a composition (a Go file or package directory of package main)
+// as a plugin using the go tool, and returns the path of the compiled plugin.
+//
+// Each build produces a new file with a unique plugin path, since a plugin can only be loaded once per process:
+// the files of the composition are built with an overlay adding a unique comment to one of them
+// (a package directory would otherwise always be built with the same plugin path, its import path).
+func BuildPlugin(src string) (string, error) {
+	_, err := exec.LookPath("go")
+	if err != nil {
+		return "", fmt.Errorf("go executable lookup: %w", err)
+	}
+
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", fmt.Errorf("get absolute path: %s: %w", src, err)
+	}
+	files, err := sourceFiles(abs)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(os.TempDir(), "ziq_composition_*.so")
+	if err != nil {
+		return "", fmt.Errorf("create plugin file: %w", err)
+	}
+	f.Close()
+	overlay, err := writeOverlay(files[0], f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	defer os.RemoveAll(filepath.Dir(overlay))
+
+	args := append([]string{"build", "-buildmode=plugin", "-overlay=" + overlay, "-o", f.Name()}, files...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = filepath.Dir(files[0])
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("build plugin: %w\n%s", err, output)
+	}
+	return f.Name(), nil
+}
+
+// sourceFiles returns the Go files of a composition (the file itself, or the non-test files of a package directory).
+func sourceFiles(src string) ([]string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("stat source: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{src}, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(src, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("list Go files: %s: %w", src, err)
+	}
+	files := []string{}
+	for _, m := range matches {
+		if !strings.HasSuffix(m, "_test.go") {
+			files = append(files, m)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", src)
+	}
+	return files, nil
+}
+
+// writeOverlay writes an overlay (see "go help build") replacing a file with a copy ending with a unique comment,
+// and returns the path of the overlay file (in a temporary directory that should be removed after the build).
+func writeOverlay(file, unique string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read file: %s: %w", file, err)
+	}
+	dir, err := os.MkdirTemp(os.TempDir(), "ziq_overlay_*")
+	if err != nil {
+		return "", fmt.Errorf("create overlay directory: %w", err)
+	}
+	replacement := filepath.Join(dir, filepath.Base(file))
+	content = append(content, "\n// "+filepath.Base(unique)+"\n"...)
+	if err := os.WriteFile(replacement, content, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("write file: %s: %w", replacement, err)
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{"Replace": {file: replacement}})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("encode overlay: %w", err)
+	}
+	path := filepath.Join(dir, "overlay.json")
+	if err := os.WriteFile(path, overlay, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("write file: %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// LoadPlugin loads a compiled composition plugin and returns its wave.
+func LoadPlugin(path string) (wave.Wave, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open plugin: %s: %w", path, err)
+	}
+	sym, err := p.Lookup(CompositionSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("lookup composition: %w", err)
+	}
+	composition, ok := sym.(func() wave.Wave)
+	if !ok {
+		return nil, fmt.Errorf("%s should be a func() wave.Wave: %T", CompositionSymbol, sym)
+	}
+	return composition(), nil
+}
+
+// Load builds and loads a composition.
+func Load(src string) (wave.Wave, error) {
+	path, err := BuildPlugin(src)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path) // the plugin is mapped in memory once loaded
+	return LoadPlugin(path)
+}
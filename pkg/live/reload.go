@@ -0,0 +1,108 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+type ReloaderConfig struct {
+	// Source is the Go file (or package directory) of the composition.
+	Source string
+	// Fade is the crossfade duration between two versions of the composition (default: 500ms).
+	Fade time.Duration
+	// Interval is how often the source is checked for modifications (default: 500ms).
+	Interval time.Duration
+	// OnReload is called after each reload with a nil error, or with the build error
+	// (in which case the previous version keeps playing). It can be nil.
+	OnReload func(err error)
+}
+
+// Reloader rebuilds a composition when its source is modified
+// and crossfades the output to the new version without stopping playback.
+type Reloader struct {
+	config ReloaderConfig
+	sw     *Switch
+}
+
+// NewReloader builds and loads the initial version of the composition.
+func NewReloader(config ReloaderConfig) (*Reloader, error) {
+	if config.Source == "" {
+		return nil, errors.New("no source was provided")
+	}
+	if config.Fade <= 0 {
+		config.Fade = 500 * time.Millisecond
+	}
+	if config.Interval <= 0 {
+		config.Interval = 500 * time.Millisecond
+	}
+	w, err := Load(config.Source)
+	if err != nil {
+		return nil, fmt.Errorf("load composition: %w", err)
+	}
+	return &Reloader{config: config, sw: NewSwitch(w, config.Fade)}, nil
+}
+
+// Wave returns the output of the composition (always the latest successfully built version).
+func (r *Reloader) Wave() wave.Wave { return r.sw.Wave() }
+
+// Run watches the source and reloads the composition until the context is cancelled.
+func (r *Reloader) Run(ctx context.Context) error {
+	last, err := lastModification(r.config.Source)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		modified, err := lastModification(r.config.Source)
+		if err != nil || !modified.After(last) {
+			continue
+		}
+		last = modified
+
+		w, err := Load(r.config.Source)
+		if err == nil {
+			r.sw.Set(w)
+		}
+		if r.config.OnReload != nil {
+			r.config.OnReload(err)
+		}
+	}
+}
+
+// lastModification returns the latest modification time of a file,
+// or of the Go files of a directory.
+func lastModification(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat: %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return info.ModTime(), nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.go"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("list Go files: %s: %w", path, err)
+	}
+	latest := info.ModTime()
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,80 @@
+package live
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Switch is a wave whose source can be replaced while it is being played.
+// The new source is crossfaded with the previous one to avoid clicks.
+type Switch struct {
+	mu      sync.Mutex
+	current wave.Wave
+	prev    wave.Wave
+	next    wave.Wave // set but not played yet
+	fade    time.Duration
+	started time.Duration // position at which the crossfade started
+}
+
+// NewSwitch creates a switch playing the initial wave, sources are crossfaded over the fade duration.
+func NewSwitch(initial wave.Wave, fade time.Duration) *Switch {
+	return &Switch{current: initial, fade: fade}
+}
+
+// Set replaces the source, the crossfade starts at the next position played.
+// When a crossfade is still in progress, the new source is crossfaded with the current mix.
+// It can be called from any goroutine.
+func (s *Switch) Set(src wave.Wave) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = src
+}
+
+// progress returns the progress of the crossfade at x (1 once it is done), it must be called with the mutex locked.
+func (s *Switch) progress(x time.Duration) float64 {
+	if s.prev != nil && s.fade > 0 && x-s.started < s.fade && x >= s.started {
+		return float64(x-s.started) / float64(s.fade)
+	}
+	return 1
+}
+
+// Wave returns the output of the switch.
+// Crossfades are timed from the positions at which the wave is evaluated (see wave.Wave).
+func (s *Switch) Wave() wave.Wave {
+	return func(x time.Duration) float64 {
+		s.mu.Lock()
+		if s.next != nil {
+			if p := s.progress(x); p < 1 {
+				// Fade out the current mix (frozen at its current balance)
+				current, prev := s.current, s.prev
+				s.prev = func(x time.Duration) float64 { return mix(current, prev, p, x) }
+			} else {
+				s.prev = s.current
+			}
+			s.current, s.next = s.next, nil
+			s.started = x
+		}
+		current, prev := s.current, s.prev
+		progress := s.progress(x)
+		if progress == 1 {
+			s.prev = nil
+		}
+		s.mu.Unlock()
+
+		return mix(current, prev, progress, x)
+	}
+}
+
+// mix crossfades from prev to current (nil waves are silent).
+func mix(current, prev wave.Wave, progress float64, x time.Duration) float64 {
+	out := 0.0
+	if current != nil {
+		out += current(x) * progress
+	}
+	if progress < 1 && prev != nil {
+		out += prev(x) * (1 - progress)
+	}
+	return out
+}
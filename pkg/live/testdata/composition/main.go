@@ -0,0 +1,7 @@
+package main
+
+import "github.com/ejuju/ziq/pkg/wave"
+
+func Composition() wave.Wave { return wave.Const(0.5) }
+
+func main() {}
@@ -0,0 +1,281 @@
+// Package markov generates melodies with Markov chains: the steps of melodies learned from patterns or MIDI files
+// are chained randomly, following the transitions heard in the sources, which makes endless variations of them.
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/midi"
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/pattern"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Chain holds the transitions learned from melodies: how often each step follows the previous steps (context).
+type Chain struct {
+	order       int
+	transitions map[string]*successors // by context key
+	starts      [][]pattern.Step       // contexts at the beginning of the learned melodies
+}
+
+// successors are the steps that followed a context, in the order they were learned (so generation is reproducible).
+type successors struct {
+	steps  []pattern.Step
+	counts []int
+	total  int
+}
+
+// NewChain returns an empty chain where the next step depends on the order previous steps (default: 1).
+// Higher orders stay closer to the learned melodies, lower orders wander more freely.
+func NewChain(order int) *Chain {
+	if order <= 0 {
+		order = 1
+	}
+	return &Chain{order: order, transitions: map[string]*successors{}}
+}
+
+// Learn adds the transitions of a melodic pattern (see pattern.ParseMelody) to the chain.
+// Like when patterns are played, the pattern loops: its last steps lead back to its first ones,
+// so generated melodies never reach a dead end.
+func (c *Chain) Learn(p pattern.Pattern) {
+	if len(p) == 0 {
+		return
+	}
+	at := func(i int) pattern.Step { return p[i%len(p)] }
+	start := make([]pattern.Step, c.order)
+	for i := range start {
+		start[i] = at(i)
+	}
+	c.starts = append(c.starts, start)
+
+	for i := range p {
+		context := make([]pattern.Step, c.order)
+		for j := range context {
+			context[j] = at(i + j)
+		}
+		c.add(context, at(i+c.order))
+	}
+}
+
+func (c *Chain) add(context []pattern.Step, next pattern.Step) {
+	k := key(context)
+	s, ok := c.transitions[k]
+	if !ok {
+		s = &successors{}
+		c.transitions[k] = s
+	}
+	s.total++
+	for i, step := range s.steps {
+		if step == next {
+			s.counts[i]++
+			return
+		}
+	}
+	s.steps = append(s.steps, next)
+	s.counts = append(s.counts, 1)
+}
+
+// LearnNotes adds the transitions of notes (for example: the notes of a channel of a MIDI file, see midi.File.Notes)
+// quantized to steps of the given duration.
+// The melody is monophonic: the highest note is kept when notes start on the same step,
+// and a note is cut when the next one starts.
+func (c *Chain) LearnNotes(notes []midi.NoteEvent, step time.Duration) {
+	c.Learn(Quantize(notes, step))
+}
+
+// Quantize returns a melodic pattern playing the notes (see LearnNotes) on steps of the given duration.
+func Quantize(notes []midi.NoteEvent, step time.Duration) pattern.Pattern {
+	if step <= 0 || len(notes) == 0 {
+		return nil
+	}
+	notes = append([]midi.NoteEvent(nil), notes...)
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Start < notes[j].Start })
+	round := func(d time.Duration) int { return int(math.Round(float64(d) / float64(step))) }
+
+	end := 0
+	for _, n := range notes {
+		if e := round(n.Start + n.Duration); e > end {
+			end = e
+		}
+	}
+	p := make(pattern.Pattern, end)
+	for i := range p {
+		p[i] = pattern.Step{Rest: true}
+	}
+	for _, n := range notes {
+		i := round(n.Start)
+		if i < 0 || i >= len(p) {
+			continue
+		}
+		if s := p[i]; !s.Rest && !s.Tie && s.Note >= n.Note {
+			continue
+		}
+		// The new note cuts the previous one.
+		for j := i + 1; j < len(p) && p[j].Tie; j++ {
+			p[j] = pattern.Step{Rest: true}
+		}
+		p[i] = pattern.Step{Note: n.Note, Velocity: n.Velocity}
+		for j := i + 1; j < round(n.Start+n.Duration) && j < len(p) && p[j].Rest; j++ {
+			p[j] = pattern.Step{Tie: true}
+		}
+	}
+	return p
+}
+
+// key encodes a context as a map key: the notes, rests and ties of its steps
+// (velocities are ignored, so the same phrase played with other dynamics leads to the same successors).
+func key(context []pattern.Step) string {
+	b := strings.Builder{}
+	for _, s := range context {
+		switch {
+		case s.Rest:
+			b.WriteString(".")
+		case s.Tie:
+			b.WriteString("-")
+		default:
+			b.WriteString(strconv.Itoa(int(s.Note)))
+		}
+		b.WriteString(" ")
+	}
+	return b.String()
+}
+
+// Melody is an endless melody generated by a chain from a seed: melodies generated with the same seed
+// (and chain) are identical, for example to reproduce a run of an installation piece.
+// The chain should not learn new melodies while its melodies play.
+//
+// Steps are generated lazily, in order, and only the latest ones are kept in memory, so the melody can play forever
+// (seeking back before them regenerates the melody from its beginning).
+// The melody is stateful, like the waves described in wave.Wave.
+type Melody struct {
+	chain *Chain
+	seed  int64
+	rng   *rand.Rand
+	first int // index of the first step kept in memory
+	steps []pattern.Step
+}
+
+// Number of steps kept in memory by melodies.
+const keptSteps = 1 << 14
+
+// Melody returns an endless melody generated from the seed (for example: wave.NextSeed()).
+func (c *Chain) Melody(seed int64) *Melody {
+	m := &Melody{chain: c, seed: seed}
+	m.reset()
+	return m
+}
+
+func (m *Melody) reset() {
+	m.rng = rand.New(rand.NewSource(m.seed))
+	m.first, m.steps = 0, nil
+}
+
+// Step returns the step at index i of the melody (steps before the first one are rests).
+func (m *Melody) Step(i int) pattern.Step {
+	if i < 0 || len(m.chain.starts) == 0 {
+		return pattern.Step{Rest: true}
+	}
+	if i < m.first {
+		m.reset()
+	}
+	for m.first+len(m.steps) <= i {
+		m.next()
+	}
+	return m.steps[i-m.first]
+}
+
+// next generates the next step.
+func (m *Melody) next() {
+	order := m.chain.order
+	if len(m.steps) > 2*keptSteps {
+		dropped := len(m.steps) - keptSteps
+		m.steps = append([]pattern.Step(nil), m.steps[dropped:]...)
+		m.first += dropped
+	}
+	if len(m.steps) < order {
+		m.restart()
+		return
+	}
+	s, ok := m.chain.transitions[key(m.steps[len(m.steps)-order:])]
+	if !ok {
+		m.restart()
+		return
+	}
+	r := m.rng.Intn(s.total)
+	for i, count := range s.counts {
+		if r < count {
+			m.steps = append(m.steps, s.steps[i])
+			return
+		}
+		r -= count
+	}
+}
+
+// restart continues the melody with the beginning of a learned melody.
+func (m *Melody) restart() {
+	start := m.chain.starts[m.rng.Intn(len(m.chain.starts))]
+	m.steps = append(m.steps, start...)
+}
+
+// Pattern returns the first n steps of the melody,
+// for example to play them in a loop or to export them to a MIDI file (see midi.FromPatterns).
+func (m *Melody) Pattern(n int) pattern.Pattern {
+	out := make(pattern.Pattern, n)
+	for i := range out {
+		out[i] = m.Step(i)
+	}
+	return out
+}
+
+// Play returns a wave playing the melody forever with the instrument, with steps of the given duration.
+// Tied steps extend the gate of the note they follow, and notes keep playing during the release once their gate is closed.
+// The velocity of notes is the level of their step.
+func (m *Melody) Play(instrument note.Instrument, step, release time.Duration) wave.Wave {
+	type voice struct {
+		wave wave.Wave
+		end  time.Duration // end of the release
+	}
+	voices := map[int]voice{} // by step index
+	return func(x time.Duration) float64 {
+		if x < 0 || step <= 0 {
+			return 0
+		}
+		current := int(x / step)
+		sum := 0.0
+		i := current
+		for ; i >= 0; i-- {
+			// Notes starting before a step that isn't tied have ended by the beginning of this step.
+			if i < current && !m.Step(i+1).Tie && x >= time.Duration(i+1)*step+release {
+				break
+			}
+			s := m.Step(i)
+			if s.Rest || s.Tie {
+				continue
+			}
+			v, ok := voices[i]
+			if !ok {
+				length := 1
+				for m.Step(i + length).Tie {
+					length++
+				}
+				gate := wave.Limit(wave.Const(1), wave.Const(0), time.Duration(length)*step)
+				v = voice{wave: instrument(s.Note, s.Level(), gate), end: time.Duration(i+length)*step + release}
+				voices[i] = v
+			}
+			if x < v.end {
+				sum += v.wave(x - time.Duration(i)*step)
+			}
+		}
+		for j := range voices {
+			if j < i || j > current {
+				delete(voices, j) // finished, or not started yet after seeking back
+			}
+		}
+		return sum
+	}
+}
@@ -0,0 +1,140 @@
+package markov
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/midi"
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/pattern"
+)
+
+func TestKey(t *testing.T) {
+	c4, e4 := note.MustParse("c4"), note.MustParse("e4")
+	tests := []struct {
+		name string
+		a, b []pattern.Step
+		same bool
+	}{
+		{name: "velocities", a: []pattern.Step{{Note: c4, Velocity: 0.5}}, b: []pattern.Step{{Note: c4}}, same: true},
+		{name: "notes", a: []pattern.Step{{Note: c4}}, b: []pattern.Step{{Note: e4}}},
+		{name: "rest and tie", a: []pattern.Step{{Rest: true}}, b: []pattern.Step{{Tie: true}}},
+		{name: "order", a: []pattern.Step{{Note: c4}, {Note: e4}}, b: []pattern.Step{{Note: e4}, {Note: c4}}},
+		{name: "joined notes", a: []pattern.Step{{Note: 1}, {Note: 11}}, b: []pattern.Step{{Note: 11}, {Note: 1}}},
+	}
+	for _, tt := range tests {
+		if same := key(tt.a) == key(tt.b); same != tt.same {
+			t.Errorf("%s: keys %q and %q, want same: %v", tt.name, key(tt.a), key(tt.b), tt.same)
+		}
+	}
+}
+
+func TestChainLearn(t *testing.T) {
+	c := NewChain(1)
+	c.Learn(pattern.MustParseMelody("c4@0.5 e4"))
+	c.Learn(pattern.MustParseMelody("c4 g4"))
+
+	// Both c4 lead to e4 or g4, whatever their velocity
+	s := c.transitions[key([]pattern.Step{{Note: note.MustParse("c4")}})]
+	if s == nil {
+		t.Fatal("no transitions after c4")
+	}
+	want := []pattern.Step{{Note: note.MustParse("e4")}, {Note: note.MustParse("g4")}}
+	if !reflect.DeepEqual(s.steps, want) || s.total != 2 {
+		t.Errorf("successors of c4 = %v (%d transitions), want %v (2 transitions)", s.steps, s.total, want)
+	}
+	// The learned velocities are kept in the generated steps
+	if s := c.transitions[key([]pattern.Step{{Note: note.MustParse("e4")}})]; s == nil || s.steps[0].Velocity != 0.5 {
+		t.Errorf("successors of e4 = %+v, want c4@0.5", s)
+	}
+}
+
+func TestMelody(t *testing.T) {
+	tests := []struct {
+		name   string
+		learn  []string
+		order  int
+		length int
+		want   string // empty if the melody is random
+	}{
+		{name: "single melody", learn: []string{"c4 d4 - e4 ."}, order: 1, length: 10, want: "c4 d4 - e4 . c4 d4 - e4 ."},
+		{name: "higher order", learn: []string{"c4 c4 e4 c4 g4"}, order: 2, length: 10, want: "c4 c4 e4 c4 g4 c4 c4 e4 c4 g4"},
+		{name: "random", learn: []string{"c4 e4 g4", "c4 g4 e4 d4"}, order: 1, length: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChain(tt.order)
+			for _, m := range tt.learn {
+				c.Learn(pattern.MustParseMelody(m))
+			}
+			got := c.Melody(7).Pattern(tt.length)
+			if tt.want != "" && !reflect.DeepEqual(got, pattern.MustParseMelody(tt.want)) {
+				t.Errorf("melody = %v, want %s", got, tt.want)
+			}
+			if again := c.Melody(7).Pattern(tt.length); !reflect.DeepEqual(again, got) {
+				t.Errorf("melodies with the same seed differ: %v and %v", got, again)
+			}
+		})
+	}
+}
+
+func TestMelodySeekBack(t *testing.T) {
+	c := NewChain(1)
+	c.Learn(pattern.MustParseMelody("c4 e4 g4 . c5"))
+	c.Learn(pattern.MustParseMelody("c4 g4 - e4"))
+	m := c.Melody(3)
+	first := m.Pattern(100)
+	late := m.Step(5 * keptSteps) // drops the first steps
+	if got := m.Pattern(100); !reflect.DeepEqual(got, first) {
+		t.Errorf("steps regenerated after seeking back differ")
+	}
+	if again := c.Melody(3).Step(5 * keptSteps); again != late {
+		t.Errorf("step %d = %v, want %v", 5*keptSteps, again, late)
+	}
+	if got := NewChain(1).Melody(3).Step(0); !got.Rest {
+		t.Errorf("step of an empty chain = %v, want a rest", got)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	const step = 100 * time.Millisecond
+	c4, e4, g4 := note.MustParse("c4"), note.MustParse("e4"), note.MustParse("g4")
+	tests := []struct {
+		name  string
+		notes []midi.NoteEvent
+		want  pattern.Pattern
+	}{
+		{
+			name: "notes and rests",
+			notes: []midi.NoteEvent{
+				{Note: c4, Velocity: 1, Start: 0, Duration: 200 * time.Millisecond},
+				{Note: e4, Velocity: 0.5, Start: 310 * time.Millisecond, Duration: 90 * time.Millisecond},
+			},
+			want: pattern.Pattern{{Note: c4, Velocity: 1}, {Tie: true}, {Rest: true}, {Note: e4, Velocity: 0.5}},
+		},
+		{
+			name: "the highest note of a chord",
+			notes: []midi.NoteEvent{
+				{Note: c4, Velocity: 1, Duration: step},
+				{Note: g4, Velocity: 1, Duration: step},
+				{Note: e4, Velocity: 1, Duration: step},
+			},
+			want: pattern.Pattern{{Note: g4, Velocity: 1}},
+		},
+		{
+			name: "a new note cuts the previous one",
+			notes: []midi.NoteEvent{
+				{Note: c4, Velocity: 1, Duration: 400 * time.Millisecond},
+				{Note: e4, Velocity: 1, Start: 200 * time.Millisecond, Duration: step},
+			},
+			want: pattern.Pattern{{Note: c4, Velocity: 1}, {Tie: true}, {Note: e4, Velocity: 1}, {Rest: true}},
+		},
+		{name: "no notes", notes: nil, want: nil},
+	}
+	for _, tt := range tests {
+		if got := Quantize(tt.notes, step); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Quantize = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package midi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+)
+
+// Status bytes of channel messages (without the channel).
+const (
+	NoteOff         byte = 0x80
+	NoteOn          byte = 0x90
+	PolyAftertouch  byte = 0xA0
+	ControlChange   byte = 0xB0
+	ProgramChange   byte = 0xC0
+	ChannelPressure byte = 0xD0
+	PitchBend       byte = 0xE0
+)
+
+// Status bytes of system messages used in files.
+const (
+	SysEx       byte = 0xF0
+	SysExEscape byte = 0xF7
+	Meta        byte = 0xFF
+)
+
+// Meta event types.
+const (
+	MetaTrackName  byte = 0x03
+	MetaEndOfTrack byte = 0x2F
+	MetaTempo      byte = 0x51
+)
+
+// Default tempo when a file doesn't specify one (120 BPM).
+const defaultMicrosecondsPerQuarter = 500_000
+
+// Event is a single MIDI event of a track.
+type Event struct {
+	// Tick is the absolute position of the event in the track.
+	Tick int
+	// Status is the status byte of the event (including the channel for channel messages).
+	Status byte
+	// MetaType is the type of a meta event (only used when Status is Meta).
+	MetaType byte
+	// Data holds the data bytes of the event.
+	Data []byte
+}
+
+// Type returns the type of a channel message (without the channel), or the status byte for other events.
+func (e Event) Type() byte {
+	if e.Status >= 0xF0 {
+		return e.Status
+	}
+	return e.Status & 0xF0
+}
+
+// Channel returns the channel of a channel message (between 0 and 15).
+func (e Event) Channel() int { return int(e.Status & 0x0F) }
+
+// Track is a list of events, ordered by tick.
+type Track []Event
+
+// File represents a Standard MIDI File.
+type File struct {
+	// Format is 0 (single track), 1 (simultaneous tracks) or 2 (independent tracks).
+	Format int
+	// TicksPerQuarter is the number of ticks per quarter note (0 for SMPTE-based timing).
+	TicksPerQuarter int
+	// TicksPerSecond is used instead of TicksPerQuarter for SMPTE-based timing.
+	TicksPerSecond float64
+	Tracks         []Track
+}
+
+// NoteEvent is a note played in a MIDI file.
+type NoteEvent struct {
+	Track    int
+	Channel  int
+	Note     note.Note
+	Velocity float64 // between 0 and 1
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// Parse decodes a Standard MIDI File.
+func Parse(r io.Reader) (*File, error) {
+	br := bufio.NewReader(r)
+
+	id, header, err := readChunk(br)
+	if err != nil {
+		return nil, fmt.Errorf("read header chunk: %w", err)
+	}
+	if id != "MThd" || len(header) < 6 {
+		return nil, errors.New("not a MIDI file: missing header chunk")
+	}
+
+	f := &File{Format: int(binary.BigEndian.Uint16(header[0:2]))}
+	numTracks := int(binary.BigEndian.Uint16(header[2:4]))
+	division := binary.BigEndian.Uint16(header[4:6])
+	if division&0x8000 == 0 {
+		f.TicksPerQuarter = int(division)
+	} else {
+		framesPerSecond := -float64(int8(division >> 8))
+		if framesPerSecond == 29 {
+			framesPerSecond = 29.97
+		}
+		f.TicksPerSecond = framesPerSecond * float64(division&0xFF)
+	}
+	if f.TicksPerQuarter == 0 && f.TicksPerSecond == 0 {
+		return nil, errors.New("invalid time division: 0")
+	}
+
+	for len(f.Tracks) < numTracks {
+		id, data, err := readChunk(br)
+		if err != nil {
+			return nil, fmt.Errorf("read track chunk %d: %w", len(f.Tracks), err)
+		}
+		if id != "MTrk" {
+			continue // unknown chunks must be ignored
+		}
+		track, err := parseTrack(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse track %d: %w", len(f.Tracks), err)
+		}
+		f.Tracks = append(f.Tracks, track)
+	}
+
+	return f, nil
+}
+
+// Reads and decodes a Standard MIDI File (.mid).
+func Import(filepath string) (*File, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %s: %w", filepath, err)
+	}
+	defer f.Close()
+
+	out, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse file: %s: %w", filepath, err)
+	}
+	return out, nil
+}
+
+func MustImport(filepath string) *File {
+	out, err := Import(filepath)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func readChunk(r io.Reader) (string, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", nil, err
+	}
+	return string(header[:4]), data, nil
+}
+
+func parseTrack(data []byte) (Track, error) {
+	track := Track{}
+	tick := 0
+	runningStatus := byte(0)
+	for i := 0; i < len(data); {
+		delta, n, err := readVarLen(data[i:])
+		if err != nil {
+			return nil, fmt.Errorf("read delta time: %w", err)
+		}
+		i += n
+		tick += delta
+		if i >= len(data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		status := data[i]
+		if status < 0x80 {
+			if runningStatus == 0 {
+				return nil, fmt.Errorf("data byte without status at offset %d", i)
+			}
+			status = runningStatus
+		} else {
+			i++
+		}
+
+		event := Event{Tick: tick, Status: status}
+		switch {
+		case status == Meta:
+			if i >= len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			event.MetaType = data[i]
+			i++
+			fallthrough
+		case status == SysEx || status == SysExEscape:
+			length, n, err := readVarLen(data[i:])
+			if err != nil {
+				return nil, fmt.Errorf("read event length: %w", err)
+			}
+			i += n
+			if i+length > len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			event.Data = data[i : i+length]
+			i += length
+			runningStatus = 0
+		case status < 0xF0:
+			length := channelMessageLength(status)
+			if i+length > len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			event.Data = data[i : i+length]
+			i += length
+			runningStatus = status
+		default:
+			return nil, fmt.Errorf("unsupported status byte in file: %#x", status)
+		}
+
+		track = append(track, event)
+		if event.Status == Meta && event.MetaType == MetaEndOfTrack {
+			break
+		}
+	}
+	return track, nil
+}
+
+// channelMessageLength returns the number of data bytes following the status byte of a channel message.
+func channelMessageLength(status byte) int {
+	switch status & 0xF0 {
+	case ProgramChange, ChannelPressure:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func readVarLen(data []byte) (int, int, error) {
+	value := 0
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		value = value<<7 | int(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("variable-length quantity is too long")
+}
+
+// tempoChange is an entry of a tempo map.
+type tempoChange struct {
+	tick                   int
+	at                     time.Duration
+	microsecondsPerQuarter int
+}
+
+// tempoMap collects the tempo changes of all tracks.
+func (f *File) tempoMap() []tempoChange {
+	changes := []tempoChange{{tick: 0, microsecondsPerQuarter: defaultMicrosecondsPerQuarter}}
+	for _, track := range f.Tracks {
+		for _, e := range track {
+			if e.Status == Meta && e.MetaType == MetaTempo && len(e.Data) == 3 {
+				us := int(e.Data[0])<<16 | int(e.Data[1])<<8 | int(e.Data[2])
+				changes = append(changes, tempoChange{tick: e.Tick, microsecondsPerQuarter: us})
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].tick < changes[j].tick })
+
+	for i := 1; i < len(changes); i++ {
+		prev := changes[i-1]
+		changes[i].at = prev.at + f.ticksToDuration(changes[i].tick-prev.tick, prev.microsecondsPerQuarter)
+	}
+	return changes
+}
+
+// TickToDuration converts an absolute position in ticks into a time position.
+func (f *File) TickToDuration(tick int) time.Duration {
+	return f.tickToDuration(f.tempoMap(), tick)
+}
+
+func (f *File) tickToDuration(tempos []tempoChange, tick int) time.Duration {
+	i := sort.Search(len(tempos), func(i int) bool { return tempos[i].tick > tick }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return tempos[i].at + f.ticksToDuration(tick-tempos[i].tick, tempos[i].microsecondsPerQuarter)
+}
+
+func (f *File) ticksToDuration(ticks int, microsecondsPerQuarter int) time.Duration {
+	if f.TicksPerQuarter == 0 {
+		return time.Duration(float64(ticks) / f.TicksPerSecond * float64(time.Second))
+	}
+	return time.Duration(ticks) * time.Duration(microsecondsPerQuarter) * time.Microsecond / time.Duration(f.TicksPerQuarter)
+}
+
+// Notes returns all notes played in the file, ordered by start time.
+// Notes that are never released end with the last event of their track.
+func (f *File) Notes() []NoteEvent {
+	tempos := f.tempoMap()
+	out := []NoteEvent{}
+
+	type key struct{ channel, note int }
+	for trackIndex, track := range f.Tracks {
+		pending := map[key][]Event{}
+		end := 0
+		for _, e := range track {
+			end = e.Tick
+			if e.Status >= 0xF0 || len(e.Data) < 2 {
+				continue
+			}
+			k := key{channel: e.Channel(), note: int(e.Data[0])}
+			switch {
+			case e.Type() == NoteOn && e.Data[1] > 0:
+				pending[k] = append(pending[k], e)
+			case e.Type() == NoteOff || e.Type() == NoteOn:
+				if len(pending[k]) == 0 {
+					continue
+				}
+				on := pending[k][0]
+				pending[k] = pending[k][1:]
+				out = append(out, f.newNoteEvent(tempos, trackIndex, on, e.Tick))
+			}
+		}
+		for _, ons := range pending {
+			for _, on := range ons {
+				out = append(out, f.newNoteEvent(tempos, trackIndex, on, end))
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+func (f *File) newNoteEvent(tempos []tempoChange, track int, on Event, offTick int) NoteEvent {
+	start := f.tickToDuration(tempos, on.Tick)
+	return NoteEvent{
+		Track:    track,
+		Channel:  on.Channel(),
+		Note:     note.Note(on.Data[0]),
+		Velocity: float64(on.Data[1]) / 127,
+		Start:    start,
+		Duration: f.tickToDuration(tempos, offTick) - start,
+	}
+}
+
+// Duration returns the time position of the last event of the file.
+func (f *File) Duration() time.Duration {
+	last := 0
+	for _, track := range f.Tracks {
+		if len(track) > 0 && track[len(track)-1].Tick > last {
+			last = track[len(track)-1].Tick
+		}
+	}
+	return f.TickToDuration(last)
+}
@@ -0,0 +1,126 @@
+package midi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// smf builds a Standard MIDI File with a header (format 0, 96 ticks per quarter note) and the given track chunks.
+func smf(tracks ...[]byte) []byte {
+	out := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, byte(len(tracks)), 0, 96}
+	for _, t := range tracks {
+		out = append(out, 'M', 'T', 'r', 'k', 0, 0, byte(len(t)>>8), byte(len(t)))
+		out = append(out, t...)
+	}
+	return out
+}
+
+func TestReadVarLen(t *testing.T) {
+	tests := []struct {
+		in      []byte
+		value   int
+		n       int
+		wantErr bool
+	}{
+		{in: []byte{0x00}, value: 0, n: 1},
+		{in: []byte{0x7F}, value: 127, n: 1},
+		{in: []byte{0x81, 0x00}, value: 128, n: 2},
+		{in: []byte{0xFF, 0x7F, 0x42}, value: 16383, n: 2},
+		{in: []byte{0xFF, 0xFF, 0xFF, 0x7F}, value: 0x0FFFFFFF, n: 4},
+		{in: []byte{0x81}, wantErr: true},
+		{in: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x7F}, wantErr: true},
+	}
+	for _, tt := range tests {
+		value, n, err := readVarLen(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readVarLen(% x): error = %v, want error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if value != tt.value || n != tt.n {
+			t.Errorf("readVarLen(% x) = %d, %d, want %d, %d", tt.in, value, n, tt.value, tt.n)
+		}
+		if !tt.wantErr && !bytes.Equal(appendVarLen(nil, value), tt.in[:n]) {
+			t.Errorf("appendVarLen(%d) = % x, want % x", value, appendVarLen(nil, value), tt.in[:n])
+		}
+	}
+}
+
+func TestParseNotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		want    []NoteEvent
+		wantErr bool
+	}{
+		{
+			name: "note on and note off",
+			in:   smf([]byte{0x00, 0x90, 60, 127, 0x60, 0x80, 60, 0, 0x00, 0xFF, 0x2F, 0x00}),
+			want: []NoteEvent{{Note: 60, Velocity: 1, Duration: 500 * time.Millisecond}},
+		},
+		{
+			name: "running status and note on with a velocity of 0",
+			in:   smf([]byte{0x00, 0x91, 60, 127, 0x00, 64, 127, 0x60, 60, 0, 0x30, 64, 0}),
+			want: []NoteEvent{
+				{Channel: 1, Note: 60, Velocity: 1, Duration: 500 * time.Millisecond},
+				{Channel: 1, Note: 64, Velocity: 1, Duration: 750 * time.Millisecond},
+			},
+		},
+		{
+			name: "tempo change (60 BPM after the first quarter note)",
+			in:   smf([]byte{0x00, 0x90, 60, 127, 0x60, 0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40, 0x60, 0x80, 60, 0}),
+			want: []NoteEvent{{Note: 60, Velocity: 1, Duration: 1500 * time.Millisecond}},
+		},
+		{
+			name: "note never released",
+			in:   smf([]byte{0x00, 0x90, 60, 127, 0x60, 0xFF, 0x2F, 0x00}),
+			want: []NoteEvent{{Note: 60, Velocity: 1, Duration: 500 * time.Millisecond}},
+		},
+		{name: "missing header", in: []byte("MTrk\x00\x00\x00\x00"), wantErr: true},
+		{name: "data byte without status", in: smf([]byte{0x00, 60, 127}), wantErr: true},
+		{name: "truncated event", in: smf([]byte{0x00, 0x90, 60}), wantErr: true},
+		{name: "truncated chunk", in: smf([]byte{0x00, 0x90, 60, 127})[:20], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(bytes.NewReader(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := f.Notes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	notes := []NoteEvent{
+		{Track: 0, Channel: 0, Note: 60, Velocity: 1, Start: 0, Duration: 250 * time.Millisecond},
+		{Track: 1, Channel: 9, Note: 36, Velocity: 100.0 / 127, Start: 125 * time.Millisecond, Duration: time.Second},
+		{Track: 0, Channel: 0, Note: 60, Velocity: 0.5, Start: 250 * time.Millisecond, Duration: 250 * time.Millisecond},
+	}
+	buf := &bytes.Buffer{}
+	if err := FromNotes(notes).Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.Notes()
+	if len(got) != len(notes) {
+		t.Fatalf("got %d notes, want %d", len(got), len(notes))
+	}
+	for i, want := range notes {
+		g := got[i]
+		want.Velocity = float64(int(want.Velocity*127+0.5)) / 127
+		if g != want {
+			t.Errorf("note %d = %+v, want %+v", i, g, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package midi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ejuju/ziq/pkg/note"
+)
+
+// Message is a channel message received from a MIDI input.
+type Message struct {
+	Status byte
+	Data   []byte
+}
+
+// Type returns the type of the message (without the channel).
+func (m Message) Type() byte { return m.Status & 0xF0 }
+
+// Channel returns the channel of the message (between 0 and 15).
+func (m Message) Channel() int { return int(m.Status & 0x0F) }
+
+// Input reads channel messages from a live MIDI byte stream.
+// System messages (clock, sysex, etc.) are ignored.
+type Input struct {
+	r       *bufio.Reader
+	closer  io.Closer
+	running byte // running status
+}
+
+// NewInput reads messages from a raw MIDI byte stream.
+func NewInput(r io.Reader) *Input {
+	in := &Input{r: bufio.NewReader(r)}
+	if c, ok := r.(io.Closer); ok {
+		in.closer = c
+	}
+	return in
+}
+
+// OpenInput opens a raw MIDI device (for example: "/dev/snd/midiC1D0" with ALSA on Linux).
+// The input must be closed by the caller.
+func OpenInput(device string) (*Input, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, fmt.Errorf("open MIDI device: %s: %w", device, err)
+	}
+	return NewInput(f), nil
+}
+
+// Devices lists the raw MIDI devices available with ALSA.
+func Devices() ([]string, error) {
+	return filepath.Glob("/dev/snd/midiC*D*")
+}
+
+// Close closes the underlying device (if it can be closed).
+func (in *Input) Close() error {
+	if in.closer == nil {
+		return nil
+	}
+	return in.closer.Close()
+}
+
+// Read blocks until the next channel message is received.
+func (in *Input) Read() (Message, error) {
+	msg := Message{Status: in.running}
+	for {
+		b, err := in.r.ReadByte()
+		if err != nil {
+			return Message{}, err
+		}
+
+		switch {
+		case b >= 0xF8:
+			continue // real-time messages can be interleaved with other messages
+		case b >= 0xF0:
+			msg = Message{} // system common messages cancel the running status
+			in.running = 0
+			continue
+		case b >= 0x80:
+			msg = Message{Status: b}
+			in.running = b
+			continue
+		case msg.Status == 0:
+			continue // data byte without status
+		}
+
+		msg.Data = append(msg.Data, b)
+		if len(msg.Data) == channelMessageLength(msg.Status) {
+			return msg, nil
+		}
+	}
+}
+
+// Handler handles the messages of a MIDI input.
+// Callbacks can be nil.
+type Handler struct {
+	// Channels to listen to (between 0 and 15), all channels are handled when empty.
+	Channels []int
+	// NoteOn is called with a velocity between 0 and 1.
+	NoteOn  func(n note.Note, velocity float64)
+	NoteOff func(n note.Note)
+	// ControlChange is called with the controller number and a value between 0 and 1.
+	ControlChange func(controller int, value float64)
+	// PitchBend is called with a value between -1 and 1.
+	PitchBend func(value float64)
+}
+
+// Listen reads messages and calls the handler until the input is closed or fails.
+func (in *Input) Listen(h Handler) error {
+	for {
+		msg, err := in.Read()
+		if errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read MIDI message: %w", err)
+		}
+		h.handle(msg)
+	}
+}
+
+func (h Handler) handle(msg Message) {
+	if len(h.Channels) > 0 {
+		found := false
+		for _, c := range h.Channels {
+			found = found || c == msg.Channel()
+		}
+		if !found {
+			return
+		}
+	}
+
+	switch {
+	case msg.Type() == NoteOn && msg.Data[1] > 0:
+		if h.NoteOn != nil {
+			h.NoteOn(note.Note(msg.Data[0]), float64(msg.Data[1])/127)
+		}
+	case msg.Type() == NoteOn || msg.Type() == NoteOff:
+		if h.NoteOff != nil {
+			h.NoteOff(note.Note(msg.Data[0]))
+		}
+	case msg.Type() == ControlChange:
+		if h.ControlChange != nil {
+			h.ControlChange(int(msg.Data[0]), float64(msg.Data[1])/127)
+		}
+	case msg.Type() == PitchBend:
+		if h.PitchBend != nil {
+			value := int(msg.Data[1])<<7 | int(msg.Data[0])
+			h.PitchBend(float64(value-8192) / 8192)
+		}
+	}
+}
+
+// NoteReceiver receives live notes (for example: voice.Allocator).
+type NoteReceiver interface {
+	NoteOn(n note.Note, velocity float64)
+	NoteOff(n note.Note)
+	Sustain(on bool)
+	AllNotesOff()
+}
+
+// Play forwards the notes of the input to the receiver until the input is closed or fails.
+// The sustain pedal (CC 64) and "all notes off" messages (CC 120 and 123) are forwarded too.
+func (in *Input) Play(to NoteReceiver, channels ...int) error {
+	return in.Listen(Handler{
+		Channels: channels,
+		NoteOn:   to.NoteOn,
+		NoteOff:  to.NoteOff,
+		ControlChange: func(controller int, value float64) {
+			switch controller {
+			case 64:
+				to.Sustain(value >= 0.5)
+			case 120, 123:
+				to.AllNotesOff()
+			}
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package midi
+
+import (
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// PitchBendController can be used as a mapping controller to map the pitch-bend wheel.
+const PitchBendController = -1
+
+// Mapping maps a MIDI controller to a named parameter.
+type Mapping struct {
+	// Controller is the CC number (between 0 and 127), or PitchBendController.
+	Controller int
+	// Param is the name of the controlled parameter (for example: "cutoff").
+	Param string
+	// Min and Max are the parameter values for the lowest and highest controller positions.
+	Min, Max float64
+	// Initial is the value of the parameter until the controller is moved.
+	Initial float64
+	// Smoothing is the time it takes for the parameter to (almost) reach a new value,
+	// to avoid audible steps (zipper noise) between the 128 controller positions (default: 20ms).
+	Smoothing time.Duration
+}
+
+// Mapper controls named parameters with MIDI controllers and the pitch-bend wheel.
+//
+// Its handler can be passed to Input.Listen (and called from any goroutine),
+// while the parameter waves are being played.
+type Mapper struct {
+	params      *wave.ParamSet
+	controllers map[int][]Mapping
+}
+
+func NewMapper(mappings ...Mapping) *Mapper {
+	m := &Mapper{params: wave.NewParamSet(), controllers: map[int][]Mapping{}}
+	for _, mapping := range mappings {
+		m.controllers[mapping.Controller] = append(m.controllers[mapping.Controller], mapping)
+		m.params.Add(mapping.Param, mapping.Initial, mapping.Smoothing)
+	}
+	return m
+}
+
+// Param returns a wave producing the smoothed value of the named parameter (see wave.ParamSet.Wave).
+func (m *Mapper) Param(name string) wave.Wave { return m.params.Wave(name) }
+
+// Set moves the parameters mapped to a controller, the position must be between 0 and 1
+// (or between -1 and 1 for the pitch-bend wheel).
+func (m *Mapper) Set(controller int, position float64) {
+	if controller == PitchBendController {
+		position = (position + 1) / 2
+	}
+	for _, mapping := range m.controllers[controller] {
+		m.params.Set(mapping.Param, mapping.Min+(mapping.Max-mapping.Min)*position)
+	}
+}
+
+// Handler returns a MIDI input handler that moves the mapped parameters.
+// Other callbacks (NoteOn, etc.) can be set on the returned handler.
+func (m *Mapper) Handler(channels ...int) Handler {
+	return Handler{
+		Channels:      channels,
+		ControlChange: m.Set,
+		PitchBend:     func(value float64) { m.Set(PitchBendController, value) },
+	}
+}
@@ -0,0 +1,64 @@
+package midi
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+type RenderConfig struct {
+	// Instruments maps MIDI channels (0 to 15) to the instrument playing them.
+	// Notes on channels without an instrument are ignored.
+	Instruments map[int]note.Instrument
+	// Release is how long a note keeps being played once it has been released,
+	// so instruments can let their envelope fade out.
+	Release time.Duration
+}
+
+// Render returns a wave playing the notes of the file with the configured instruments.
+// The waves of simultaneous notes are summed.
+func (f *File) Render(config RenderConfig) wave.Wave {
+	return RenderNotes(f.Notes(), config)
+}
+
+// RenderNotes returns a wave playing the provided notes with the configured instruments.
+func RenderNotes(notes []NoteEvent, config RenderConfig) wave.Wave {
+	type voice struct {
+		start, end time.Duration
+		wave       wave.Wave
+	}
+
+	voices := []voice{}
+	longest := time.Duration(0)
+	for _, n := range notes {
+		instrument, ok := config.Instruments[n.Channel]
+		if !ok || instrument == nil {
+			continue
+		}
+		gate := wave.Limit(wave.Const(1), wave.Const(0), n.Duration)
+		v := voice{
+			start: n.Start,
+			end:   n.Start + n.Duration + config.Release,
+			wave:  instrument(n.Note, n.Velocity, gate),
+		}
+		voices = append(voices, v)
+		if v.end-v.start > longest {
+			longest = v.end - v.start
+		}
+	}
+	sort.SliceStable(voices, func(i, j int) bool { return voices[i].start < voices[j].start })
+
+	return func(x time.Duration) float64 {
+		// Only voices that started before x (and not earlier than the longest voice) can be playing.
+		i := sort.Search(len(voices), func(i int) bool { return voices[i].start > x })
+		sum := 0.0
+		for i--; i >= 0 && voices[i].start >= x-longest; i-- {
+			if x < voices[i].end {
+				sum += voices[i].wave(x - voices[i].start)
+			}
+		}
+		return sum
+	}
+}
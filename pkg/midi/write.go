@@ -0,0 +1,214 @@
+package midi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/pattern"
+)
+
+// Resolution used for exported files.
+const exportTicksPerQuarter = 480
+
+// Write encodes the file as a Standard MIDI File.
+// An "end of track" event is added to tracks that don't have one.
+func (f *File) Write(w io.Writer) error {
+	if w == nil {
+		return errors.New("no io.Writer was provided")
+	}
+	if f.TicksPerQuarter <= 0 || f.TicksPerQuarter > 0x7FFF {
+		return fmt.Errorf("invalid ticks per quarter note: %d", f.TicksPerQuarter)
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint16(header[0:2], uint16(f.Format))
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(f.Tracks)))
+	binary.BigEndian.PutUint16(header[4:6], uint16(f.TicksPerQuarter))
+	if err := writeChunk(bw, "MThd", header); err != nil {
+		return fmt.Errorf("write header chunk: %w", err)
+	}
+
+	for i, track := range f.Tracks {
+		if err := writeChunk(bw, "MTrk", encodeTrack(track)); err != nil {
+			return fmt.Errorf("write track chunk %d: %w", i, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// Encodes and writes the file to disk (.mid).
+func (f *File) Export(filepath string) error {
+	out, err := os.Create(filepath)
+	if err != nil {
+		return fmt.Errorf("create file: %s: %w", filepath, err)
+	}
+	defer out.Close()
+
+	if err := f.Write(out); err != nil {
+		return fmt.Errorf("write file: %s: %w", filepath, err)
+	}
+	return out.Close()
+}
+
+func writeChunk(w io.Writer, id string, data []byte) error {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func encodeTrack(track Track) []byte {
+	out := []byte{}
+	tick := 0
+	ended := false
+	for _, e := range track {
+		out = appendVarLen(out, e.Tick-tick)
+		tick = e.Tick
+		out = append(out, e.Status)
+		switch e.Status {
+		case Meta:
+			out = append(out, e.MetaType)
+			fallthrough
+		case SysEx, SysExEscape:
+			out = appendVarLen(out, len(e.Data))
+		}
+		out = append(out, e.Data...)
+		ended = e.Status == Meta && e.MetaType == MetaEndOfTrack
+	}
+	if !ended {
+		out = append(out, 0x00, Meta, MetaEndOfTrack, 0x00)
+	}
+	return out
+}
+
+func appendVarLen(out []byte, value int) []byte {
+	if value < 0 {
+		value = 0
+	}
+	buf := []byte{byte(value & 0x7F)}
+	for value >>= 7; value > 0; value >>= 7 {
+		buf = append([]byte{byte(value&0x7F) | 0x80}, buf...)
+	}
+	return append(out, buf...)
+}
+
+// FromNotes creates a MIDI file (at 120 BPM) playing the provided notes.
+// Notes are grouped in tracks according to their Track field.
+func FromNotes(notes []NoteEvent) *File {
+	microsecondsPerQuarter := defaultMicrosecondsPerQuarter
+	tickDuration := time.Duration(microsecondsPerQuarter) * time.Microsecond / exportTicksPerQuarter
+	toTick := func(d time.Duration) int { return int((d + tickDuration/2) / tickDuration) }
+
+	numTracks := 1
+	for _, n := range notes {
+		if n.Track+1 > numTracks {
+			numTracks = n.Track + 1
+		}
+	}
+
+	f := &File{Format: 1, TicksPerQuarter: exportTicksPerQuarter, Tracks: make([]Track, numTracks)}
+	f.Tracks[0] = append(f.Tracks[0], Event{
+		Status:   Meta,
+		MetaType: MetaTempo,
+		Data:     []byte{byte(microsecondsPerQuarter >> 16), byte(microsecondsPerQuarter >> 8), byte(microsecondsPerQuarter)},
+	})
+	for _, n := range notes {
+		channel := byte(n.Channel & 0x0F)
+		velocity := byte(n.Velocity*127 + 0.5)
+		if velocity == 0 {
+			velocity = 1 // a note-on with a velocity of 0 is a note-off
+		} else if velocity > 127 {
+			velocity = 127
+		}
+		f.Tracks[n.Track] = append(f.Tracks[n.Track],
+			Event{Tick: toTick(n.Start), Status: NoteOn | channel, Data: []byte{byte(n.Note), velocity}},
+			Event{Tick: toTick(n.Start + n.Duration), Status: NoteOff | channel, Data: []byte{byte(n.Note), 0}},
+		)
+	}
+
+	// Sort events by tick (note-offs first so repeated notes don't overlap)
+	for _, track := range f.Tracks {
+		sort.SliceStable(track, func(i, j int) bool {
+			if track[i].Tick != track[j].Tick {
+				return track[i].Tick < track[j].Tick
+			}
+			return track[i].Type() == NoteOff && track[j].Type() != NoteOff
+		})
+	}
+	return f
+}
+
+// PatternTrack describes how a pattern is exported to a MIDI track.
+type PatternTrack struct {
+	Pattern pattern.Pattern
+	Step    time.Duration
+	// Repeat is the number of times the pattern is played (default: 1).
+	Repeat  int
+	Channel int
+	// Trigger is used as the note of all steps when not 0,
+	// for drum patterns (for example: 36 for a General MIDI kick on channel 9).
+	Trigger note.Note
+}
+
+// FromPatterns creates a MIDI file (at 120 BPM) with one track per pattern,
+// so generated sequences can be imported in a DAW.
+func FromPatterns(tracks ...PatternTrack) *File {
+	notes := []NoteEvent{}
+	for i, t := range tracks {
+		for _, n := range t.Notes() {
+			n.Track = i
+			notes = append(notes, n)
+		}
+	}
+	return FromNotes(notes)
+}
+
+// Notes returns the notes played by the pattern track.
+// Tied steps extend the duration of the note they follow.
+func (t PatternTrack) Notes() []NoteEvent {
+	repeat := t.Repeat
+	if repeat <= 0 {
+		repeat = 1
+	}
+	notes := []NoteEvent{}
+	for r := 0; r < repeat; r++ {
+		offset := time.Duration(r) * t.Pattern.Duration(t.Step)
+		for j, s := range t.Pattern {
+			if s.Rest || s.Tie {
+				continue
+			}
+			length := 1
+			for j+length < len(t.Pattern) && t.Pattern[j+length].Tie {
+				length++
+			}
+			n := s.Note
+			if t.Trigger != 0 {
+				n = t.Trigger
+			}
+			velocity := 100.0 / 127
+			if s.Velocity > 0 {
+				velocity = s.Velocity
+			}
+			notes = append(notes, NoteEvent{
+				Channel:  t.Channel,
+				Note:     n,
+				Velocity: velocity,
+				Start:    offset + time.Duration(j)*t.Step,
+				Duration: time.Duration(length) * t.Step,
+			})
+		}
+	}
+	return notes
+}
@@ -0,0 +1,219 @@
+// Package mixer mixes the parts of a song (tracks) to a stereo output, like the mixing console of a DAW.
+package mixer
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Effect processes the wave of a track (for example: a filter, see dsp.Effect).
+type Effect func(src wave.Wave) wave.Wave
+
+// StereoEffect processes a stereo wave (for example: a limiter on the master bus, or wave.Widen).
+type StereoEffect func(src wave.StereoWave) wave.StereoWave
+
+// Track is a part of the mix (for example: drums, bass or vocals).
+type Track struct {
+	Name string
+	// Wave is the (mono) source of the track.
+	Wave wave.Wave
+	// Stereo replaces Wave for stereo sources (when its channels are set),
+	// Pan then balances its channels (panning to the right lowers the left channel, centered sources are unchanged).
+	Stereo wave.StereoWave
+	// Effects are applied (in order) to the source of the track, before its gain and pan.
+	// They are applied to each channel of stereo sources, so they should create their state when applied
+	// (processors given to dsp.Effect would be shared by both channels).
+	Effects []Effect
+	// Gain is the level of the track (default: 1), it can be automated (or controlled with wave.Param).
+	Gain wave.Wave
+	// Pan places the track in the stereo image, from -1 (left) to 1 (right) (default: 0, center).
+	// It uses a constant-power law: centered tracks play at -3dB on each channel, so they don't get louder when panned.
+	Pan wave.Wave
+	// Mute silences the track (even when it is soloed).
+	Mute bool
+	// Solo silences the tracks that are not soloed.
+	Solo bool
+	// Sends route the track to auxiliary buses (for example: a shared reverb).
+	Sends []Send
+}
+
+// Send routes a part of a track to an auxiliary bus of the mixer.
+type Send struct {
+	// Bus is the name of the bus (sends to unknown buses are ignored).
+	Bus string
+	// Level is the gain of the send (default: 1), it can be automated.
+	Level wave.Wave
+	// PreFader sends the track before its gain and pan, so its level on the bus doesn't follow its fader
+	// (for example: to fade a track out while its reverb tail keeps playing).
+	PreFader bool
+}
+
+// Bus is an auxiliary bus (return) processing the sends of several tracks with the same effects,
+// so tracks can share one reverb or delay at different levels:
+// it is cheaper than an effect per track, and glues the tracks in the same space.
+//
+// Effects of buses usually only return the processed signal (fully wet), since the tracks already play the dry signal.
+type Bus struct {
+	Name string
+	// Effects are applied (in order) to the sum of the sends.
+	Effects []StereoEffect
+	// Gain is the level of the return (default: 1).
+	Gain wave.Wave
+	// Mute silences the return (soloing tracks doesn't silence buses).
+	Mute bool
+}
+
+// Mixer sums the tracks of a mix on a master bus.
+type Mixer struct {
+	Tracks []Track
+	// Buses are returned to the master bus with the tracks.
+	Buses []Bus
+	// Effects are applied (in order) to the sum of the tracks and buses (master bus).
+	Effects []StereoEffect
+	// Gain is the level of the master bus (default: 1).
+	Gain wave.Wave
+}
+
+// Track returns the track with the name (to change it before calling Output), or nil if there is none.
+func (m *Mixer) Track(name string) *Track {
+	for i := range m.Tracks {
+		if m.Tracks[i].Name == name {
+			return &m.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// Output returns the mix (see audio.StereoRoutes to play it, or its Mono method for mono players).
+// Unlike wave.Combine, the tracks are summed (not averaged): adding a track doesn't change the level of the others.
+//
+// Changes to the mixer (like muting a track) don't affect outputs that were already returned.
+// Each track is evaluated once per position, so stateful sources and effects can be used.
+func (m *Mixer) Output() wave.StereoWave {
+	solo := false
+	for _, t := range m.Tracks {
+		solo = solo || t.Solo
+	}
+	tracks := []wave.StereoWave{}
+	sends := map[string][]wave.StereoWave{}
+	for _, t := range m.Tracks {
+		if t.Mute || (solo && !t.Solo) {
+			continue
+		}
+		pre, post := t.output()
+		tracks = append(tracks, post)
+		for _, send := range t.Sends {
+			src := post
+			if send.PreFader {
+				src = pre
+			}
+			sends[send.Bus] = append(sends[send.Bus], applyGain(src, send.Level))
+		}
+	}
+	for _, b := range m.Buses {
+		if b.Mute || len(sends[b.Name]) == 0 {
+			continue
+		}
+		bus := sum(sends[b.Name])
+		for _, effect := range b.Effects {
+			bus = effect(bus)
+		}
+		tracks = append(tracks, applyGain(bus, b.Gain))
+	}
+
+	mix := sum(tracks)
+	for _, effect := range m.Effects {
+		mix = effect(mix)
+	}
+	return applyGain(mix, m.Gain)
+}
+
+// sum adds stereo waves.
+func sum(waves []wave.StereoWave) wave.StereoWave {
+	return wave.StereoWave{
+		Left: func(x time.Duration) float64 {
+			out := 0.0
+			for _, w := range waves {
+				out += w.Left(x)
+			}
+			return out
+		},
+		Right: func(x time.Duration) float64 {
+			out := 0.0
+			for _, w := range waves {
+				out += w.Right(x)
+			}
+			return out
+		},
+	}
+}
+
+// output returns the track after its effects (pre-fader), and after its gain and pan (post-fader).
+func (t Track) output() (pre, post wave.StereoWave) {
+	var src wave.StereoWave
+	gains := panGains
+	if t.Stereo.Left != nil && t.Stereo.Right != nil {
+		src = wave.StereoWave{Left: cache(t.Stereo.Left), Right: cache(t.Stereo.Right)}
+		for _, effect := range t.Effects {
+			src = wave.StereoWave{Left: effect(src.Left), Right: effect(src.Right)}
+		}
+		gains = balanceGains
+	} else if t.Wave != nil {
+		mono := t.Wave
+		for _, effect := range t.Effects {
+			mono = effect(mono)
+		}
+		mono = cache(mono)
+		src = wave.Centered(mono)
+	} else {
+		silence := wave.Centered(wave.Const(0))
+		return silence, silence
+	}
+	pre = src
+
+	pan := wave.Const(0)
+	if t.Pan != nil {
+		pan = cache(t.Pan)
+	}
+	left, right := src.Left, src.Right
+	src = wave.StereoWave{
+		Left:  func(x time.Duration) float64 { return left(x) * gains(pan(x))[0] },
+		Right: func(x time.Duration) float64 { return right(x) * gains(pan(x))[1] },
+	}
+	return pre, applyGain(src, t.Gain)
+}
+
+// panGains returns the gains of the left and right channels for a pan position (constant-power law).
+func panGains(pan float64) [2]float64 {
+	angle := (math.Max(-1, math.Min(1, pan)) + 1) * math.Pi / 4
+	return [2]float64{math.Cos(angle), math.Sin(angle)}
+}
+
+// balanceGains returns the gains of the left and right channels of a stereo source for a balance position.
+func balanceGains(pan float64) [2]float64 {
+	pan = math.Max(-1, math.Min(1, pan))
+	return [2]float64{math.Min(1, 1-pan), math.Min(1, 1+pan)}
+}
+
+// applyGain scales both channels (nil gains don't change the wave).
+func applyGain(src wave.StereoWave, gain wave.Wave) wave.StereoWave {
+	if gain == nil {
+		return src
+	}
+	gain = cache(gain)
+	return wave.StereoWave{Left: wave.Amplitude(src.Left, gain), Right: wave.Amplitude(src.Right, gain)}
+}
+
+// cache reuses the last value of a wave when it is evaluated again at the same position
+// (by both channels), so stateful waves process each position once.
+func cache(w wave.Wave) wave.Wave {
+	last, lastValue := time.Duration(-1), 0.0
+	return func(x time.Duration) float64 {
+		if x != last {
+			last, lastValue = x, w(x)
+		}
+		return lastValue
+	}
+}
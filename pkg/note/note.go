@@ -0,0 +1,83 @@
+package note
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Note represents a pitch as a MIDI note number (60 = C4 = middle C, 69 = A4 = 440Hz).
+type Note int
+
+// Frequency returns the frequency of the note in hertz (using equal temperament).
+func (n Note) Frequency() float64 {
+	return 440 * math.Pow(2, float64(n-69)/12)
+}
+
+var names = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// String returns the scientific pitch notation of the note (for example: "C#4").
+func (n Note) String() string {
+	octave := int(math.Floor(float64(n)/12)) - 1
+	index := int(n) % 12
+	if index < 0 {
+		index += 12
+	}
+	return names[index] + strconv.Itoa(octave)
+}
+
+var semitones = map[byte]int{'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11}
+
+// Parse parses a note written in scientific pitch notation (for example: "c4", "F#3" or "bb2").
+func Parse(s string) (Note, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid note: %q", s)
+	}
+	str := strings.ToLower(s)
+
+	semitone, ok := semitones[str[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid note name: %q", s)
+	}
+	str = str[1:]
+
+	// Parse accidentals
+	for len(str) > 0 && (str[0] == '#' || str[0] == 'b') {
+		if str[0] == '#' {
+			semitone++
+		} else {
+			semitone--
+		}
+		str = str[1:]
+	}
+
+	octave, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid note octave: %q", s)
+	}
+
+	n := Note((octave+1)*12 + semitone)
+	if n < 0 || n > 127 {
+		return 0, fmt.Errorf("note out of MIDI range: %q", s)
+	}
+	return n, nil
+}
+
+func MustParse(s string) Note {
+	out, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Instrument produces the wave of a single played note.
+//
+// The returned wave (just like the gate wave) is relative to the beginning of the note.
+// The gate wave produces 1 while the note is held and 0 once it has been released,
+// instruments can use it to shape their envelope (and their release tail).
+// Velocity is between 0 and 1.
+type Instrument func(n Note, velocity float64, gate wave.Wave) wave.Wave
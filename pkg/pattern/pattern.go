@@ -0,0 +1,218 @@
+package pattern
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Step is a single step of a pattern.
+type Step struct {
+	// Rest is true when nothing is played on this step.
+	Rest bool
+	// Tie is true when the step continues the previous note (melodic patterns only).
+	Tie bool
+	// Note is the pitch played on this step (melodic patterns only).
+	Note note.Note
+	// Velocity is the strength of the note or hit, between 0 and 1 (0 is the default velocity, see Level).
+	Velocity float64
+}
+
+// Level returns the velocity of the step (1 when it isn't set).
+func (s Step) Level() float64 {
+	if s.Velocity <= 0 {
+		return 1
+	}
+	return s.Velocity
+}
+
+// Pattern is a sequence of equally long steps.
+// Patterns repeat when they reach their end.
+type Pattern []Step
+
+// ParseTriggers parses a drum pattern.
+//
+// Each "x" (or "X") triggers a hit, each "-" (or ".") is a rest.
+// Digits from "1" to "9" trigger hits with a velocity of n/9 ("9" is the hardest hit).
+// Whitespace and "|" characters are ignored and can be used to separate bars:
+// "x---x---|x-x-x---", or with dynamics: "x-3-x-3-|x-5-x-7-"
+func ParseTriggers(s string) (Pattern, error) {
+	out := Pattern{}
+	for i, char := range s {
+		switch char {
+		case 'x', 'X':
+			out = append(out, Step{})
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			out = append(out, Step{Velocity: float64(char-'0') / 9})
+		case '-', '.':
+			out = append(out, Step{Rest: true})
+		case ' ', '\t', '\n', '\r', '|':
+			continue
+		default:
+			return nil, fmt.Errorf("invalid character at position %d: %q", i, char)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty pattern: %q", s)
+	}
+	return out, nil
+}
+
+func MustParseTriggers(s string) Pattern {
+	out, err := ParseTriggers(s)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// ParseMelody parses a melodic pattern.
+//
+// Steps are separated by whitespace, each step is either a note in scientific
+// pitch notation ("c4", "f#3", "bb2"), a rest (".") or a tie ("-") that holds the previous note.
+// Notes can have a velocity between 0 and 1 after "@" ("c4@0.5").
+// "|" tokens are ignored and can be used to separate bars:
+// "c4 e4 g4 . | c5 - - ."
+func ParseMelody(s string) (Pattern, error) {
+	out := Pattern{}
+	for _, token := range strings.Fields(s) {
+		switch token {
+		case "|":
+			continue
+		case ".":
+			out = append(out, Step{Rest: true})
+		case "-":
+			if len(out) == 0 {
+				return nil, fmt.Errorf("tie without a previous note: %q", s)
+			}
+			out = append(out, Step{Tie: true})
+		default:
+			velocity := 0.0
+			if i := strings.IndexByte(token, '@'); i >= 0 {
+				v, err := strconv.ParseFloat(token[i+1:], 64)
+				if err != nil || v <= 0 || v > 1 {
+					return nil, fmt.Errorf("invalid velocity (between 0 and 1): %q", token)
+				}
+				token, velocity = token[:i], v
+			}
+			n, err := note.Parse(token)
+			if err != nil {
+				return nil, fmt.Errorf("parse step: %w", err)
+			}
+			out = append(out, Step{Note: n, Velocity: velocity})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty pattern: %q", s)
+	}
+	return out, nil
+}
+
+func MustParseMelody(s string) Pattern {
+	out, err := ParseMelody(s)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Reads and parses a drum pattern from a text file.
+func ImportTriggers(filepath string) (Pattern, error) {
+	raw, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %s: %w", filepath, err)
+	}
+	return ParseTriggers(string(raw))
+}
+
+// Reads and parses a melodic pattern from a text file.
+func ImportMelody(filepath string) (Pattern, error) {
+	raw, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %s: %w", filepath, err)
+	}
+	return ParseMelody(string(raw))
+}
+
+// Duration returns the time needed to play the pattern once.
+func (p Pattern) Duration(step time.Duration) time.Duration {
+	return time.Duration(len(p)) * step
+}
+
+// Triggers returns a wave that plays the hit wave (from its beginning) on every non-rest step,
+// scaled by the velocity of the step.
+// A hit keeps playing until the next one is triggered.
+func (p Pattern) Triggers(step time.Duration, hit wave.Wave) wave.Wave {
+	return func(x time.Duration) float64 {
+		i, ok := p.lastStart(x, step)
+		if !ok {
+			return 0
+		}
+		return hit(x-time.Duration(i)*step) * p[i%len(p)].Level()
+	}
+}
+
+// Pitch returns a wave producing the frequency of the current note.
+// The frequency of the last note is held during rests.
+func (p Pattern) Pitch(step time.Duration) wave.Wave {
+	first, hasNote := p.firstNote()
+	return func(x time.Duration) float64 {
+		if !hasNote {
+			return 0
+		}
+		i, ok := p.lastStart(x, step)
+		if !ok {
+			return first.Frequency()
+		}
+		return p[i%len(p)].Note.Frequency()
+	}
+}
+
+// Gate returns a wave that produces 1 while a note is playing (or tied) and 0 during rests.
+func (p Pattern) Gate(step time.Duration) wave.Wave {
+	return func(x time.Duration) float64 {
+		if x < 0 {
+			return 0
+		}
+		current := int(x / step)
+		for i := current; i >= 0 && i > current-len(p); i-- {
+			s := p[i%len(p)]
+			switch {
+			case s.Rest:
+				return 0
+			case !s.Tie:
+				return 1
+			}
+		}
+		return 0
+	}
+}
+
+// lastStart returns the global index of the last step (at or before x) that started a note or hit.
+func (p Pattern) lastStart(x time.Duration, step time.Duration) (int, bool) {
+	if x < 0 {
+		return 0, false
+	}
+	current := int(x / step)
+	for i := current; i >= 0 && i > current-len(p); i-- {
+		s := p[i%len(p)]
+		if !s.Rest && !s.Tie {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (p Pattern) firstNote() (note.Note, bool) {
+	for _, s := range p {
+		if !s.Rest && !s.Tie {
+			return s.Note, true
+		}
+	}
+	return 0, false
+}
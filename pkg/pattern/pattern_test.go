@@ -0,0 +1,92 @@
+package pattern
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestParseTriggers(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Pattern
+		wantErr bool
+	}{
+		{in: "x-x.", want: Pattern{{}, {Rest: true}, {}, {Rest: true}}},
+		{in: "X- | x-", want: Pattern{{}, {Rest: true}, {}, {Rest: true}}},
+		{in: "9-3", want: Pattern{{Velocity: 1}, {Rest: true}, {Velocity: 3.0 / 9}}},
+		{in: "", wantErr: true},
+		{in: " | ", wantErr: true},
+		{in: "x-o", wantErr: true},
+		{in: "x0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTriggers(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTriggers(%q): error = %v, want error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTriggers(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMelody(t *testing.T) {
+	c4, e4 := note.MustParse("c4"), note.MustParse("e4")
+	tests := []struct {
+		in      string
+		want    Pattern
+		wantErr bool
+	}{
+		{in: "c4 e4", want: Pattern{{Note: c4}, {Note: e4}}},
+		{in: "c4 - . | e4@0.5", want: Pattern{{Note: c4}, {Tie: true}, {Rest: true}, {Note: e4, Velocity: 0.5}}},
+		{in: "- c4", wantErr: true},
+		{in: "c4@0", wantErr: true},
+		{in: "c4@1.5", wantErr: true},
+		{in: "c4@x", wantErr: true},
+		{in: "h4", wantErr: true},
+		{in: "|", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMelody(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMelody(%q): error = %v, want error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMelody(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPatternWaves(t *testing.T) {
+	const step = 100 * time.Millisecond
+	p := MustParseMelody("c4 - . e4@0.5")
+	tests := []struct {
+		at                     time.Duration
+		gate, pitch, triggered float64
+	}{
+		{at: -step, gate: 0, pitch: p[0].Note.Frequency(), triggered: 0},
+		{at: 50 * time.Millisecond, gate: 1, pitch: p[0].Note.Frequency(), triggered: 1},
+		{at: 150 * time.Millisecond, gate: 1, pitch: p[0].Note.Frequency(), triggered: 1},
+		{at: 250 * time.Millisecond, gate: 0, pitch: p[0].Note.Frequency(), triggered: 1},
+		{at: 350 * time.Millisecond, gate: 1, pitch: p[3].Note.Frequency(), triggered: 0.5},
+		{at: 450 * time.Millisecond, gate: 1, pitch: p[0].Note.Frequency(), triggered: 1}, // the pattern repeats
+	}
+	gate, pitch, triggers := p.Gate(step), p.Pitch(step), p.Triggers(step, wave.Const(1))
+	for _, tt := range tests {
+		if got := gate(tt.at); got != tt.gate {
+			t.Errorf("gate at %s = %v, want %v", tt.at, got, tt.gate)
+		}
+		if got := pitch(tt.at); got != tt.pitch {
+			t.Errorf("pitch at %s = %v, want %v", tt.at, got, tt.pitch)
+		}
+		if got := triggers(tt.at); got != tt.triggered {
+			t.Errorf("triggers at %s = %v, want %v", tt.at, got, tt.triggered)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package session
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/pattern"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Session holds the live state of a performance: named parameter values and named patterns.
+//
+// Every change is recorded so it can be undone and redone without restarting audio.
+// It is safe for concurrent use: the waves it provides can be played
+// while the session is being edited from another goroutine (REPL, UI, controller).
+type Session struct {
+	mu       sync.RWMutex
+	params   map[string]float64
+	patterns map[string]pattern.Pattern
+	undos    []change
+	redos    []change
+	version  uint64 // incremented by every change (atomically), so waves only rebuild patterns when they changed
+}
+
+// change is a recorded edit, it knows how to apply and revert itself.
+// Both functions are called with the session lock held.
+type change struct {
+	apply  func()
+	revert func()
+}
+
+func New() *Session {
+	return &Session{params: map[string]float64{}, patterns: map[string]pattern.Pattern{}}
+}
+
+// SetParam sets the value of a parameter.
+func (s *Session) SetParam(name string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, existed := s.params[name]
+	s.record(change{
+		apply: func() { s.params[name] = value },
+		revert: func() {
+			if !existed {
+				delete(s.params, name)
+				return
+			}
+			s.params[name] = before
+		},
+	})
+}
+
+// ParamValue returns the current value of a parameter.
+func (s *Session) ParamValue(name string) (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.params[name]
+	return v, ok
+}
+
+// Param returns a wave that always produces the current value of the parameter (or 0 if unset).
+func (s *Session) Param(name string) wave.Wave {
+	return func(time.Duration) float64 {
+		v, _ := s.ParamValue(name)
+		return v
+	}
+}
+
+// SetPattern sets (or replaces) a pattern.
+func (s *Session) SetPattern(name string, p pattern.Pattern) {
+	p = append(pattern.Pattern(nil), p...) // copy so later edits of the caller's slice are not applied silently
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, existed := s.patterns[name]
+	s.record(change{
+		apply: func() { s.patterns[name] = p },
+		revert: func() {
+			if !existed {
+				delete(s.patterns, name)
+				return
+			}
+			s.patterns[name] = before
+		},
+	})
+}
+
+// Pattern returns the current version of a pattern.
+func (s *Session) Pattern(name string) (pattern.Pattern, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.patterns[name]
+	return p, ok
+}
+
+// Triggers is the live version of pattern.Pattern.Triggers: it always uses the current version of the named pattern.
+// The wave produces 0 while the pattern does not exist.
+// It keeps the wave of the pattern until the session changes, so it is stateful (see wave.Wave).
+func (s *Session) Triggers(name string, step time.Duration, hit wave.Wave) wave.Wave {
+	return s.livePattern(name, func(p pattern.Pattern) wave.Wave { return p.Triggers(step, hit) })
+}
+
+// Pitch is the live version of pattern.Pattern.Pitch.
+func (s *Session) Pitch(name string, step time.Duration) wave.Wave {
+	return s.livePattern(name, func(p pattern.Pattern) wave.Wave { return p.Pitch(step) })
+}
+
+// Gate is the live version of pattern.Pattern.Gate.
+func (s *Session) Gate(name string, step time.Duration) wave.Wave {
+	return s.livePattern(name, func(p pattern.Pattern) wave.Wave { return p.Gate(step) })
+}
+
+func (s *Session) livePattern(name string, toWave func(pattern.Pattern) wave.Wave) wave.Wave {
+	var w wave.Wave // of the current version of the pattern, nil while it doesn't exist
+	version := ^uint64(0)
+	return func(x time.Duration) float64 {
+		if v := atomic.LoadUint64(&s.version); v != version {
+			version = v
+			w = nil
+			if p, ok := s.Pattern(name); ok && len(p) > 0 {
+				w = toWave(p)
+			}
+		}
+		if w == nil {
+			return 0
+		}
+		return w(x)
+	}
+}
+
+// Undo reverts the last change.
+// It returns false if there is nothing to undo.
+func (s *Session) Undo() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.undos) == 0 {
+		return false
+	}
+	c := s.undos[len(s.undos)-1]
+	s.undos = s.undos[:len(s.undos)-1]
+	c.revert()
+	atomic.AddUint64(&s.version, 1)
+	s.redos = append(s.redos, c)
+	return true
+}
+
+// Redo re-applies the last undone change.
+// It returns false if there is nothing to redo.
+func (s *Session) Redo() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.redos) == 0 {
+		return false
+	}
+	c := s.redos[len(s.redos)-1]
+	s.redos = s.redos[:len(s.redos)-1]
+	c.apply()
+	atomic.AddUint64(&s.version, 1)
+	s.undos = append(s.undos, c)
+	return true
+}
+
+// record applies a new change and adds it to the history.
+// Making a new change discards the changes that were undone.
+func (s *Session) record(c change) {
+	c.apply()
+	atomic.AddUint64(&s.version, 1)
+	s.undos = append(s.undos, c)
+	s.redos = nil
+}
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/pattern"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestUndoRedo(t *testing.T) {
+	s := New()
+	s.SetParam("cutoff", 100)
+	s.SetParam("cutoff", 200)
+	s.SetPattern("kick", pattern.MustParseTriggers("x---"))
+
+	tests := []struct {
+		name    string
+		do      func() bool
+		ok      bool
+		cutoff  float64 // 0 when unset
+		pattern string  // empty when unset
+	}{
+		{name: "undo pattern", do: s.Undo, ok: true, cutoff: 200},
+		{name: "undo second value", do: s.Undo, ok: true, cutoff: 100},
+		{name: "undo first value", do: s.Undo, ok: true},
+		{name: "nothing to undo", do: s.Undo},
+		{name: "redo first value", do: s.Redo, ok: true, cutoff: 100},
+		{name: "redo second value", do: s.Redo, ok: true, cutoff: 200},
+		{name: "new change", do: func() bool { s.SetParam("cutoff", 300); return true }, ok: true, cutoff: 300},
+		{name: "redo cleared by the new change", do: s.Redo, cutoff: 300},
+		{name: "undo new change", do: s.Undo, ok: true, cutoff: 200},
+		{name: "redo new change", do: s.Redo, ok: true, cutoff: 300},
+	}
+	for _, tt := range tests {
+		if ok := tt.do(); ok != tt.ok {
+			t.Errorf("%s: returned %v, want %v", tt.name, ok, tt.ok)
+		}
+		cutoff, ok := s.ParamValue("cutoff")
+		if cutoff != tt.cutoff || ok != (tt.cutoff != 0) {
+			t.Errorf("%s: cutoff = %v (set: %v), want %v", tt.name, cutoff, ok, tt.cutoff)
+		}
+		if _, ok := s.Pattern("kick"); ok != (tt.pattern != "") {
+			t.Errorf("%s: pattern set = %v, want %v", tt.name, ok, tt.pattern != "")
+		}
+	}
+}
+
+func TestSetPatternCopies(t *testing.T) {
+	s := New()
+	p := pattern.MustParseTriggers("x-")
+	s.SetPattern("kick", p)
+	p[1] = pattern.Step{}
+	if got, _ := s.Pattern("kick"); !got[1].Rest {
+		t.Errorf("editing the caller's pattern changed the session")
+	}
+}
+
+func TestLivePattern(t *testing.T) {
+	const step = 100 * time.Millisecond
+	s := New()
+	gate := s.Gate("bass", step)
+	triggers := s.Triggers("bass", step, wave.Const(1))
+
+	check := func(name string, at time.Duration, want float64) {
+		t.Helper()
+		if got := gate(at); got != want {
+			t.Errorf("%s: gate at %s = %v, want %v", name, at, got, want)
+		}
+	}
+	check("no pattern", 50*time.Millisecond, 0)
+	if got := triggers(50 * time.Millisecond); got != 0 {
+		t.Errorf("triggers without pattern = %v, want 0", got)
+	}
+	s.SetPattern("bass", pattern.MustParseMelody("c2 ."))
+	if got := triggers(50 * time.Millisecond); got != 1 {
+		t.Errorf("triggers of the pattern = %v, want 1", got)
+	}
+	check("pattern set", 50*time.Millisecond, 1)
+	check("pattern set", 150*time.Millisecond, 0)
+	s.SetPattern("bass", pattern.MustParseMelody(". c2"))
+	check("pattern replaced", 50*time.Millisecond, 0)
+	check("pattern replaced", 150*time.Millisecond, 1)
+	s.Undo()
+	check("replacement undone", 50*time.Millisecond, 1)
+	s.Undo()
+	check("pattern undone", 50*time.Millisecond, 0)
+	s.Redo()
+	check("pattern redone", 50*time.Millisecond, 1)
+}
@@ -0,0 +1,495 @@
+// Package sfz loads multi-sampled instruments in the SFZ format (used by many free sample libraries).
+package sfz
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Loop modes of regions.
+const (
+	NoLoop         = "no_loop"         // the sample plays until its end (or until the end of the release)
+	OneShot        = "one_shot"        // the sample plays until its end, even when the note is released
+	LoopContinuous = "loop_continuous" // the loop repeats until the end of the release
+	LoopSustain    = "loop_sustain"    // the loop repeats while the note is held, then the sample plays until its end
+)
+
+// Region is a sample of an instrument, with the notes and velocities it plays.
+// Its fields are the opcodes of the SFZ format that are supported (others are ignored).
+type Region struct {
+	// Sample is the path of the WAV file (relative paths are relative to the SFZ file and its default_path).
+	Sample string
+	// LoKey and HiKey are the range of notes played by the region (lokey, hikey, key).
+	LoKey, HiKey note.Note
+	// KeyCenter is the note played by the sample without transposition (pitch_keycenter, default: 60).
+	KeyCenter note.Note
+	// LoVel and HiVel are the range of velocities played by the region, between 0 and 127 (lovel, hivel).
+	LoVel, HiVel int
+	// Transpose (in semitones) and Tune (in cents) change the pitch of the region.
+	Transpose int
+	Tune      float64
+	// Volume is the gain of the region in decibels.
+	Volume float64
+	// LoopMode is one of NoLoop (the default), OneShot, LoopContinuous and LoopSustain.
+	// The loop repeats the frames from LoopStart to LoopEnd (included, the whole sample when they aren't set).
+	LoopMode           string
+	LoopStart, LoopEnd int
+	// Envelope of the amplitude (ampeg_*), the sustain level is between 0 and 1 (default: 1).
+	Attack, Hold, Decay, Release time.Duration
+	Sustain                      float64
+	// SeqLength and SeqPosition make round robins (seq_length, seq_position, default: 1):
+	// the region only plays the note at SeqPosition (starting at 1) of every SeqLength notes it matches.
+	SeqLength, SeqPosition int
+	// LoRand and HiRand are the range of the random number drawn for each note (between 0 and 1)
+	// for which the region plays (lorand, hirand, default: 0 to 1), to alternate samples randomly.
+	LoRand, HiRand float64
+
+	frames     []float64
+	sampleRate int
+}
+
+// Instrument is a multi-sampled instrument: regions playing samples depending on the note and its velocity.
+// Its Play method is a note.Instrument, for example to play it with a voice.Allocator:
+//
+//	voice.AllocatorConfig{Instrument: inst.Play, Release: inst.Release()}
+type Instrument struct {
+	Regions []Region
+	// Seed of the random numbers selecting regions (see Region.LoRand), Import sets it with wave.NextSeed.
+	Seed int64
+
+	rng *rand.Rand
+	seq []int // number of notes matched by each region (see Region.SeqLength)
+}
+
+// Import reads an SFZ file and the samples of its regions.
+func Import(path string) (*Instrument, error) {
+	text, err := readFile(path, filepath.Dir(path), 0)
+	if err != nil {
+		return nil, err
+	}
+	regions, err := parse(text, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("parse file: %s: %w", path, err)
+	}
+	samples := map[string]*Region{} // regions that loaded each sample
+	for i := range regions {
+		r := &regions[i]
+		if loaded, ok := samples[r.Sample]; ok {
+			r.frames, r.sampleRate = loaded.frames, loaded.sampleRate
+			continue
+		}
+		r.frames, r.sampleRate, err = wave.ImportWavFrames(r.Sample)
+		if err != nil {
+			return nil, fmt.Errorf("import sample: %w", err)
+		}
+		samples[r.Sample] = r
+	}
+	return &Instrument{Regions: regions, Seed: wave.NextSeed()}, nil
+}
+
+func MustImport(path string) *Instrument {
+	out, err := Import(path)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Headers of the SFZ format, from the most general to the most specific:
+// opcodes of a header apply to the regions that follow it, until a header of the same level (or a more general one).
+var headerLevels = map[string]int{"control": 0, "global": 1, "master": 2, "group": 3, "region": 4}
+
+var (
+	headerRegexp = regexp.MustCompile(`<(\w+)>`)
+	opcodeRegexp = regexp.MustCompile(`([A-Za-z0-9_$]+)=`)
+	blockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// readFile reads an SFZ file, with the content of the files it includes (#include "file.sfz").
+// The paths of included files are relative to the directory of the main file (root).
+func readFile(path, root string, depth int) (string, error) {
+	if depth > 16 {
+		return "", fmt.Errorf("too many nested includes: %s", path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %s: %w", path, err)
+	}
+	lines := strings.Split(blockComment.ReplaceAllString(string(raw), " "), "\n")
+	for i, line := range lines {
+		if s := strings.TrimSpace(line); strings.HasPrefix(s, "#include") {
+			name := strings.Trim(strings.TrimSpace(strings.TrimPrefix(s, "#include")), `"`)
+			if lines[i], err = readFile(filepath.Join(root, filepath.FromSlash(name)), root, depth+1); err != nil {
+				return "", err
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// parse returns the regions of an SFZ file (with its includes), the paths of samples are relative to dir.
+func parse(text, dir string) ([]Region, error) {
+	defines := map[string]string{}
+	names := []string{} // of the defines, the longest first (so a name doesn't replace the beginning of a longer one)
+	levels := [5]map[string]string{{}, {}, {}, {}, nil}
+	current := -1
+	regions := []Region{}
+	flush := func() error {
+		if current != headerLevels["region"] {
+			return nil
+		}
+		opcodes := map[string]string{}
+		for _, l := range levels {
+			for k, v := range l {
+				opcodes[k] = v
+			}
+		}
+		if t, ok := opcodes["trigger"]; ok && t != "attack" {
+			return nil // release triggers aren't supported
+		}
+		r, err := newRegion(opcodes, dir)
+		if err != nil {
+			return err
+		}
+		regions = append(regions, r)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		s := scanner.Text()
+		if i := strings.Index(s, "//"); i >= 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(s)
+
+		if strings.HasPrefix(s, "#define") {
+			fields := strings.Fields(s)
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: invalid define: %q", line, s)
+			}
+			if _, ok := defines[fields[1]]; !ok {
+				names = append(names, fields[1])
+				sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+			}
+			defines[fields[1]] = fields[2]
+			continue
+		}
+		for _, name := range names {
+			s = strings.ReplaceAll(s, name, defines[name])
+		}
+
+		// Headers and opcodes: the value of an opcode runs until the next header or opcode (sample paths can have spaces)
+		type token struct {
+			start, end int
+			header     string
+			opcode     string
+		}
+		tokens := []token{}
+		for _, m := range headerRegexp.FindAllStringSubmatchIndex(s, -1) {
+			tokens = append(tokens, token{start: m[0], end: m[1], header: s[m[2]:m[3]]})
+		}
+		for _, m := range opcodeRegexp.FindAllStringSubmatchIndex(s, -1) {
+			if m[0] > 0 && s[m[0]-1] != ' ' && s[m[0]-1] != '\t' && s[m[0]-1] != '>' {
+				continue // part of a value
+			}
+			tokens = append(tokens, token{start: m[0], end: m[1], opcode: s[m[2]:m[3]]})
+		}
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i].start < tokens[j].start })
+
+		for i, t := range tokens {
+			if t.header != "" {
+				level, ok := headerLevels[t.header]
+				if !ok {
+					return nil, fmt.Errorf("line %d: unsupported header: <%s>", line, t.header)
+				}
+				if err := flush(); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				for l := level; l < len(levels); l++ {
+					levels[l] = map[string]string{}
+				}
+				current = level
+				continue
+			}
+			end := len(s)
+			if i+1 < len(tokens) {
+				end = tokens[i+1].start
+			}
+			if current < 0 {
+				return nil, fmt.Errorf("line %d: opcode outside of a header: %s", line, t.opcode)
+			}
+			levels[current][t.opcode] = strings.TrimSpace(s[t.end:end])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// newRegion creates a region from its opcodes (including the ones inherited from its headers).
+func newRegion(opcodes map[string]string, dir string) (Region, error) {
+	r := Region{
+		LoKey: 0, HiKey: 127, KeyCenter: 60, LoVel: 0, HiVel: 127, LoopMode: NoLoop, Sustain: 1, Release: time.Millisecond,
+		SeqLength: 1, SeqPosition: 1, LoRand: 0, HiRand: 1,
+	}
+	var err error
+	keyValue := func(s string) note.Note {
+		if err != nil {
+			return 0
+		}
+		if n, convErr := strconv.Atoi(s); convErr == nil {
+			return note.Note(n)
+		}
+		var n note.Note
+		n, err = note.Parse(s)
+		return n
+	}
+	number := func(s string) float64 {
+		if err != nil {
+			return 0
+		}
+		var v float64
+		v, err = strconv.ParseFloat(s, 64)
+		return v
+	}
+	seconds := func(s string) time.Duration { return time.Duration(number(s) * float64(time.Second)) }
+
+	// Opcodes are applied in a fixed order: key first, so lokey and hikey can narrow its range.
+	keys := make([]string, 0, len(opcodes))
+	for k := range opcodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i] == "key" && keys[j] != "key" })
+	for _, k := range keys {
+		v := opcodes[k]
+		switch k {
+		case "sample":
+			r.Sample = strings.ReplaceAll(v, `\`, "/")
+		case "key":
+			r.LoKey = keyValue(v)
+			r.HiKey, r.KeyCenter = r.LoKey, r.LoKey
+		case "lokey":
+			r.LoKey = keyValue(v)
+		case "hikey":
+			r.HiKey = keyValue(v)
+		case "lovel":
+			r.LoVel = int(number(v))
+		case "hivel":
+			r.HiVel = int(number(v))
+		case "transpose":
+			r.Transpose = int(number(v))
+		case "tune":
+			r.Tune = number(v)
+		case "volume":
+			r.Volume = number(v)
+		case "loop_mode", "loopmode":
+			switch v {
+			case NoLoop, OneShot, LoopContinuous, LoopSustain:
+				r.LoopMode = v
+			default:
+				return Region{}, fmt.Errorf("invalid loop mode: %q", v)
+			}
+		case "loop_start", "loopstart":
+			r.LoopStart = int(number(v))
+		case "loop_end", "loopend":
+			r.LoopEnd = int(number(v))
+		case "ampeg_attack":
+			r.Attack = seconds(v)
+		case "ampeg_hold":
+			r.Hold = seconds(v)
+		case "ampeg_decay":
+			r.Decay = seconds(v)
+		case "ampeg_sustain":
+			r.Sustain = number(v) / 100
+		case "ampeg_release":
+			r.Release = seconds(v)
+		case "seq_length":
+			r.SeqLength = int(number(v))
+		case "seq_position":
+			r.SeqPosition = int(number(v))
+		case "lorand":
+			r.LoRand = number(v)
+		case "hirand":
+			r.HiRand = number(v)
+		}
+		if err != nil {
+			return Region{}, fmt.Errorf("invalid value of %s: %q: %w", k, v, err)
+		}
+	}
+	// pitch_keycenter is applied last since key also sets it
+	if v, ok := opcodes["pitch_keycenter"]; ok {
+		if r.KeyCenter = keyValue(v); err != nil {
+			return Region{}, fmt.Errorf("invalid value of pitch_keycenter: %q: %w", v, err)
+		}
+	}
+
+	if r.SeqLength < 1 || r.SeqPosition < 1 || r.SeqPosition > r.SeqLength {
+		return Region{}, fmt.Errorf("invalid round robin: position %d of %d", r.SeqPosition, r.SeqLength)
+	}
+
+	if r.Sample == "" {
+		return Region{}, errors.New("region without sample")
+	}
+	if !filepath.IsAbs(r.Sample) {
+		defaultPath := strings.ReplaceAll(opcodes["default_path"], `\`, "/")
+		r.Sample = filepath.Join(dir, filepath.FromSlash(defaultPath), filepath.FromSlash(r.Sample))
+	}
+	return r, nil
+}
+
+// Release returns how long notes should keep playing once they are released:
+// the longest release of the regions, or the longest one-shot sample (played until its end, at the lowest note of its region).
+func (inst *Instrument) Release() time.Duration {
+	out := time.Duration(0)
+	for _, r := range inst.Regions {
+		if r.Release > out {
+			out = r.Release
+		}
+		if r.LoopMode == OneShot && r.sampleRate > 0 {
+			semitones := float64(r.LoKey-r.KeyCenter+note.Note(r.Transpose)) + r.Tune/100
+			speed := float64(r.sampleRate) * math.Pow(2, semitones/12)
+			if d := time.Duration(float64(len(r.frames)) / speed * float64(time.Second)); d > out {
+				out = d
+			}
+		}
+	}
+	return out
+}
+
+// Play returns the wave of a note (see note.Instrument): the sum of the regions playing the note at its velocity
+// (and selected by their round robin and random range).
+// Louder velocities are louder (with the square of the velocity, like most SFZ players).
+//
+// Round robins advance at each note, so notes should be played in the order they start
+// (the waves of notes also keep state between evaluations to follow their gate, see wave.Wave).
+func (inst *Instrument) Play(n note.Note, velocity float64, gate wave.Wave) wave.Wave {
+	if inst.rng == nil {
+		inst.rng = rand.New(rand.NewSource(inst.Seed))
+	}
+	if len(inst.seq) != len(inst.Regions) {
+		inst.seq = make([]int, len(inst.Regions))
+	}
+	v := int(math.Round(math.Max(0, math.Min(1, velocity)) * 127))
+	random := inst.rng.Float64()
+	waves := []wave.Wave{}
+	for i := range inst.Regions {
+		r := &inst.Regions[i]
+		if n < r.LoKey || n > r.HiKey || v < r.LoVel || v > r.HiVel || len(r.frames) == 0 {
+			continue
+		}
+		count := inst.seq[i]
+		inst.seq[i]++
+		if r.SeqLength > 1 && count%r.SeqLength != r.SeqPosition-1 {
+			continue
+		}
+		if random >= r.LoRand && random < r.HiRand {
+			waves = append(waves, r.play(n, velocity, gate))
+		}
+	}
+	return func(x time.Duration) float64 {
+		sum := 0.0
+		for _, w := range waves {
+			sum += w(x)
+		}
+		return sum
+	}
+}
+
+// play returns the wave of the region playing a note.
+func (r *Region) play(n note.Note, velocity float64, gate wave.Wave) wave.Wave {
+	semitones := float64(n-r.KeyCenter+note.Note(r.Transpose)) + r.Tune/100
+	speed := float64(r.sampleRate) * math.Pow(2, semitones/12) // frames of the sample per second
+	gain := wave.DecibelsToGain(r.Volume) * velocity * velocity
+
+	loopStart, loopEnd := r.LoopStart, r.LoopEnd
+	if loopEnd <= 0 || loopEnd >= len(r.frames) {
+		loopEnd = len(r.frames) - 1
+	}
+	if loopStart < 0 || loopStart >= loopEnd {
+		loopStart = 0
+	}
+	loopLength := float64(loopEnd - loopStart + 1)
+
+	last, released := time.Duration(-1), time.Duration(-1)
+	releasedPosition, releasedLevel := 0.0, 0.0
+	position := func(x time.Duration) float64 {
+		p := x.Seconds() * speed
+		if r.LoopMode == LoopContinuous || (r.LoopMode == LoopSustain && released < 0) {
+			if p > float64(loopEnd)+1 {
+				p = float64(loopStart) + math.Mod(p-float64(loopStart), loopLength)
+			}
+		} else if r.LoopMode == LoopSustain {
+			p = releasedPosition + (x-released).Seconds()*speed
+		}
+		return p
+	}
+	return func(x time.Duration) float64 {
+		if x < 0 {
+			return 0
+		}
+		if x < last {
+			released = -1
+		}
+		last = x
+		if released < 0 && r.LoopMode != OneShot && gate(x) <= 0 {
+			releasedPosition, releasedLevel = position(x), r.envelope(x)
+			released = x
+		}
+
+		level := r.envelope(x)
+		if released >= 0 {
+			if r.Release <= 0 || x-released >= r.Release {
+				return 0
+			}
+			level = releasedLevel * (1 - float64(x-released)/float64(r.Release))
+		}
+		return r.frameAt(position(x)) * level * gain
+	}
+}
+
+// envelope returns the level of the envelope while the note is held.
+func (r *Region) envelope(x time.Duration) float64 {
+	switch {
+	case x < r.Attack:
+		return float64(x) / float64(r.Attack)
+	case x < r.Attack+r.Hold:
+		return 1
+	case x < r.Attack+r.Hold+r.Decay:
+		return 1 - (1-r.Sustain)*float64(x-r.Attack-r.Hold)/float64(r.Decay)
+	default:
+		return r.Sustain
+	}
+}
+
+// frameAt returns the value of the sample at a (fractional) frame position, with linear interpolation.
+func (r *Region) frameAt(p float64) float64 {
+	i := int(p)
+	if p < 0 || i >= len(r.frames) {
+		return 0
+	}
+	next := 0.0
+	if i+1 < len(r.frames) {
+		next = r.frames[i+1]
+	}
+	t := p - float64(i)
+	return r.frames[i]*(1-t) + next*t
+}
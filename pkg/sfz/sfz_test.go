@@ -0,0 +1,209 @@
+package sfz
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// region returns a region with the defaults of parsed regions.
+func region(sample string, edit func(r *Region)) Region {
+	r := Region{
+		Sample: filepath.Join("dir", sample), LoKey: 0, HiKey: 127, KeyCenter: 60, LoVel: 0, HiVel: 127,
+		LoopMode: NoLoop, Sustain: 1, Release: time.Millisecond, SeqLength: 1, SeqPosition: 1, LoRand: 0, HiRand: 1,
+	}
+	if edit != nil {
+		edit(&r)
+	}
+	return r
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []Region
+		wantErr bool
+	}{
+		{
+			name: "headers",
+			in: `<global> volume=-6
+				<group> lovel=64 // loud samples
+				<region> sample=a.wav key=60
+				<region> sample=b c.wav lokey=c4 hikey=e4 pitch_keycenter=d4
+				<group> /* soft samples */ hivel=63
+				<region> sample=d.wav ampeg_release=0.5 ampeg_sustain=50`,
+			want: []Region{
+				region("a.wav", func(r *Region) { r.Volume, r.LoVel, r.LoKey, r.HiKey = -6, 64, 60, 60 }),
+				region("b c.wav", func(r *Region) { r.Volume, r.LoVel, r.LoKey, r.HiKey, r.KeyCenter = -6, 64, 60, 64, 62 }),
+				region("d.wav", func(r *Region) { r.Volume, r.HiVel, r.Release, r.Sustain = -6, 63, 500*time.Millisecond, 0.5 }),
+			},
+		},
+		{
+			name: "key before lokey and hikey",
+			in:   `<group> lokey=50 hikey=70 <region> sample=a.wav key=60`,
+			want: []Region{region("a.wav", func(r *Region) { r.LoKey, r.HiKey, r.KeyCenter = 50, 70, 60 })},
+		},
+		{
+			name: "defines, the longest first",
+			in: `#define $VEL 10
+				#define $VELHI 100
+				<region> sample=a.wav lovel=$VEL hivel=$VELHI`,
+			want: []Region{region("a.wav", func(r *Region) { r.LoVel, r.HiVel = 10, 100 })},
+		},
+		{
+			name: "round robins and random regions",
+			in: `<group> seq_length=2 <region> sample=a.wav seq_position=1 <region> sample=b.wav seq_position=2
+				<group> <region> sample=c.wav hirand=0.5 <region> sample=d.wav lorand=0.5`,
+			want: []Region{
+				region("a.wav", func(r *Region) { r.SeqLength = 2 }),
+				region("b.wav", func(r *Region) { r.SeqLength, r.SeqPosition = 2, 2 }),
+				region("c.wav", func(r *Region) { r.HiRand = 0.5 }),
+				region("d.wav", func(r *Region) { r.LoRand = 0.5 }),
+			},
+		},
+		{
+			name: "default path and release triggers",
+			in:   `<control> default_path=samples\piano\ <region> sample=a.wav <region> sample=b.wav trigger=release`,
+			want: []Region{{
+				Sample: filepath.Join("dir", "samples", "piano", "a.wav"), LoKey: 0, HiKey: 127, KeyCenter: 60, HiVel: 127,
+				LoopMode: NoLoop, Sustain: 1, Release: time.Millisecond, SeqLength: 1, SeqPosition: 1, HiRand: 1,
+			}},
+		},
+		{name: "opcode outside of a header", in: `sample=a.wav`, wantErr: true},
+		{name: "unsupported header", in: `<curve> v000=0`, wantErr: true},
+		{name: "invalid define", in: `#define $A`, wantErr: true},
+		{name: "region without sample", in: `<region> key=60`, wantErr: true},
+		{name: "invalid key", in: `<region> sample=a.wav key=h4`, wantErr: true},
+		{name: "invalid loop mode", in: `<region> sample=a.wav loop_mode=forever`, wantErr: true},
+		{name: "invalid round robin", in: `<region> sample=a.wav seq_length=2 seq_position=3`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Opcodes are stored in maps, parsing many times catches an order depending on map iteration
+			for i := 0; i < 20; i++ {
+				got, err := parse(tt.in, "dir")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+				}
+				if err == nil && !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("regions = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// constRegion returns a region playing a constant sample (value) of 1s at 1000Hz.
+func constRegion(value float64, edit func(r *Region)) Region {
+	r := region("", edit)
+	r.sampleRate = 1000
+	r.frames = make([]float64, 1000)
+	for i := range r.frames {
+		r.frames[i] = value
+	}
+	return r
+}
+
+func TestInstrumentRoundRobin(t *testing.T) {
+	inst := &Instrument{Regions: []Region{
+		constRegion(1, func(r *Region) { r.SeqLength, r.SeqPosition = 3, 1 }),
+		constRegion(2, func(r *Region) { r.SeqLength, r.SeqPosition = 3, 2 }),
+		constRegion(4, func(r *Region) { r.SeqLength, r.SeqPosition = 3, 3 }),
+		constRegion(8, nil), // always plays
+	}}
+	want := []float64{9, 10, 12, 9, 10}
+	for i, w := range want {
+		if got := inst.Play(60, 1, wave.Const(1))(100 * time.Millisecond); got != w {
+			t.Errorf("note %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestInstrumentRandom(t *testing.T) {
+	play := func(seed int64) []float64 {
+		inst := &Instrument{Seed: seed, Regions: []Region{
+			constRegion(1, func(r *Region) { r.HiRand = 0.5 }),
+			constRegion(2, func(r *Region) { r.LoRand = 0.5 }),
+		}}
+		out := []float64{}
+		for i := 0; i < 100; i++ {
+			out = append(out, inst.Play(60, 1, wave.Const(1))(100*time.Millisecond))
+		}
+		return out
+	}
+	notes := play(1)
+	counts := map[float64]int{}
+	for _, v := range notes {
+		counts[v]++
+	}
+	if len(counts) != 2 || counts[1] < 30 || counts[2] < 30 {
+		t.Errorf("played regions = %v, want about 50 notes of each region (and only one region per note)", counts)
+	}
+	if again := play(1); !reflect.DeepEqual(again, notes) {
+		t.Errorf("notes played with the same seed differ")
+	}
+}
+
+func TestInstrumentRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions []Region
+		want    time.Duration
+	}{
+		{name: "longest release", regions: []Region{
+			constRegion(1, func(r *Region) { r.Release = 200 * time.Millisecond }),
+			constRegion(1, func(r *Region) { r.Release = 500 * time.Millisecond }),
+		}, want: 500 * time.Millisecond},
+		{name: "one-shot sample", regions: []Region{
+			constRegion(1, func(r *Region) { r.LoopMode = OneShot; r.LoKey, r.HiKey = 60, 72 }),
+		}, want: time.Second},
+		{name: "one-shot sample played an octave lower", regions: []Region{
+			constRegion(1, func(r *Region) { r.LoopMode = OneShot; r.LoKey, r.HiKey = 48, 60 }),
+			constRegion(1, func(r *Region) { r.Release = 500 * time.Millisecond }),
+		}, want: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := (&Instrument{Regions: tt.regions}).Release(); got != tt.want {
+			t.Errorf("%s: release = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "samples"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := audio.ExportWav(filepath.Join(dir, "samples", "a.wav"), []float64{0.5, 0.5, 0.5, 0.5}, 8000, 1); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"inst.sfz":    "#include \"regions.sfz\"\n",
+		"regions.sfz": "<control> default_path=samples/\n<region> sample=a.wav key=c4\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inst, err := Import(filepath.Join(dir, "inst.sfz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inst.Regions) != 1 || inst.Regions[0].LoKey != note.MustParse("c4") || inst.Regions[0].sampleRate != 8000 {
+		t.Fatalf("regions = %+v", inst.Regions)
+	}
+	if got := inst.Play(note.MustParse("c4"), 1, wave.Const(1))(0); got < 0.49 || got > 0.51 {
+		t.Errorf("first frame = %v, want 0.5", got)
+	}
+	if _, err := Import(filepath.Join(dir, "missing.sfz")); err == nil {
+		t.Errorf("importing a missing file didn't fail")
+	}
+}
@@ -0,0 +1,225 @@
+package song
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/midi"
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/pattern"
+	"github.com/ejuju/ziq/pkg/timeline"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Default release of sample instruments: samples are one-shots, they keep playing after their step.
+const defaultSampleRelease = time.Second
+
+// EffectFactory creates an effect from the parameters found in a song file.
+type EffectFactory func(params map[string]float64) (timeline.Effect, error)
+
+var effects = map[string]EffectFactory{
+	"gain": func(params map[string]float64) (timeline.Effect, error) {
+		gain, ok := params["gain"]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter: gain")
+		}
+		return func(src wave.Wave) wave.Wave { return wave.Amplitude(src, wave.Const(gain)) }, nil
+	},
+	"tremolo": func(params map[string]float64) (timeline.Effect, error) {
+		rate, depth := params["rate"], params["depth"]
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid rate: %g", rate)
+		}
+		lfo := func(x time.Duration) float64 {
+			return 1 - depth*(0.5+0.5*math.Sin(2*math.Pi*rate*x.Seconds()))
+		}
+		return func(src wave.Wave) wave.Wave { return wave.Amplitude(src, lfo) }, nil
+	},
+}
+
+// RegisterEffect makes an effect type available to song files.
+// It should be called before songs are built (for example: in an init function).
+func RegisterEffect(name string, factory EffectFactory) { effects[name] = factory }
+
+// Timeline builds the arrangement of the song, with one clip per (non-muted) track.
+func (s *Song) Timeline() (*timeline.Timeline, error) {
+	s.setDefaults()
+
+	patterns := map[string]pattern.Pattern{}
+	for name, str := range s.Patterns {
+		p, err := pattern.ParseTriggers(str)
+		if err != nil {
+			p, err = pattern.ParseMelody(str)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse pattern %q: %w", name, err)
+		}
+		patterns[name] = p
+	}
+
+	t := &timeline.Timeline{}
+	for _, track := range s.Tracks {
+		if track.Mute {
+			continue
+		}
+		clip, err := s.buildTrack(track, patterns)
+		if err != nil {
+			return nil, fmt.Errorf("build track %q: %w", track.Name, err)
+		}
+		t.Clips = append(t.Clips, clip)
+	}
+	return t, nil
+}
+
+// Wave builds the wave of the song.
+func (s *Song) Wave() (wave.Wave, error) {
+	t, err := s.Timeline()
+	if err != nil {
+		return nil, err
+	}
+	return t.Wave(), nil
+}
+
+// Duration returns the time needed to play the whole song (including release tails).
+func (s *Song) Duration() (time.Duration, error) {
+	t, err := s.Timeline()
+	if err != nil {
+		return 0, err
+	}
+	return t.Duration(), nil
+}
+
+func (s *Song) setDefaults() {
+	if s.Tempo <= 0 {
+		s.Tempo = 120
+	}
+	if s.StepsPerBeat <= 0 {
+		s.StepsPerBeat = 4
+	}
+	if s.BeatsPerBar <= 0 {
+		s.BeatsPerBar = 4
+	}
+}
+
+func (s *Song) buildTrack(track Track, patterns map[string]pattern.Pattern) (timeline.Clip, error) {
+	inst, ok := s.Instruments[track.Instrument]
+	if !ok {
+		return timeline.Clip{}, fmt.Errorf("instrument not found: %q", track.Instrument)
+	}
+	instrument, release, err := s.buildInstrument(inst)
+	if err != nil {
+		return timeline.Clip{}, fmt.Errorf("build instrument %q: %w", track.Instrument, err)
+	}
+
+	notes := []midi.NoteEvent{}
+	end := time.Duration(0)
+	for _, c := range track.Clips {
+		p, ok := patterns[c.Pattern]
+		if !ok {
+			return timeline.Clip{}, fmt.Errorf("pattern not found: %q", c.Pattern)
+		}
+		start := time.Duration(c.Bar * float64(s.Bar()))
+		for _, n := range (midi.PatternTrack{Pattern: p, Step: s.Step(), Repeat: c.Repeat}).Notes() {
+			n.Start += start
+			notes = append(notes, n)
+			if n.Start+n.Duration > end {
+				end = n.Start + n.Duration
+			}
+		}
+	}
+
+	clip := timeline.Clip{
+		Name:   track.Name,
+		Length: end + release,
+		Wave:   midi.RenderNotes(notes, midi.RenderConfig{Instruments: map[int]note.Instrument{0: instrument}, Release: release}),
+	}
+	for _, e := range track.Effects {
+		factory, ok := effects[e.Type]
+		if !ok {
+			return timeline.Clip{}, fmt.Errorf("unknown effect type: %q", e.Type)
+		}
+		effect, err := factory(e.Params)
+		if err != nil {
+			return timeline.Clip{}, fmt.Errorf("create effect %q: %w", e.Type, err)
+		}
+		clip.Effects = append(clip.Effects, effect)
+	}
+	if track.Gain != nil {
+		gain := *track.Gain
+		clip.Effects = append(clip.Effects, func(src wave.Wave) wave.Wave { return wave.Amplitude(src, wave.Const(gain)) })
+	}
+	return clip, nil
+}
+
+// buildInstrument returns the instrument and its release duration.
+func (s *Song) buildInstrument(inst Instrument) (note.Instrument, time.Duration, error) {
+	gain := 1.0
+	if inst.Gain != nil {
+		gain = *inst.Gain
+	}
+	attack, release := time.Duration(inst.Attack), time.Duration(inst.Release)
+
+	switch {
+	case inst.Sample != "":
+		path := inst.Sample
+		if !filepath.IsAbs(path) && s.dir != "" {
+			path = filepath.Join(s.dir, path)
+		}
+		sample, err := wave.ImportWav(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("import sample: %w", err)
+		}
+		if release <= 0 {
+			release = defaultSampleRelease
+		}
+		return func(n note.Note, velocity float64, gate wave.Wave) wave.Wave {
+			return wave.Amplitude(sample, wave.Const(velocity*gain))
+		}, release, nil
+
+	case inst.Oscillator == "sine":
+		return func(n note.Note, velocity float64, gate wave.Wave) wave.Wave {
+			osc := wave.OscillateSine(wave.Const(n.Frequency()))
+			return wave.Amplitude(osc, envelope(gate, attack, release, velocity*gain))
+		}, release, nil
+
+	default:
+		return nil, 0, fmt.Errorf("instrument needs a sample or a supported oscillator (\"sine\"): %q", inst.Oscillator)
+	}
+}
+
+// envelope returns a linear attack/release envelope following the gate of a note.
+func envelope(gate wave.Wave, attack, release time.Duration, level float64) wave.Wave {
+	releasedAt := time.Duration(-1)
+	return func(x time.Duration) float64 {
+		if x < 0 {
+			return 0
+		}
+		out := level
+		if x < attack {
+			out = level * float64(x) / float64(attack)
+		}
+		if gate(x) > 0 {
+			return out
+		}
+
+		// The gate of a note goes from 1 to 0 once, find when (once) with a binary search.
+		if releasedAt < 0 || releasedAt > x {
+			low, high := time.Duration(0), x
+			for high-low > time.Microsecond {
+				mid := low + (high-low)/2
+				if gate(mid) > 0 {
+					low = mid
+				} else {
+					high = mid
+				}
+			}
+			releasedAt = high
+		}
+		if release <= 0 || x-releasedAt >= release {
+			return 0
+		}
+		return out * (1 - float64(x-releasedAt)/float64(release))
+	}
+}
@@ -0,0 +1,147 @@
+package song
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Song is a declarative description of a composition, loaded from a JSON file
+// (JSON is the only supported format, YAML files should be converted first):
+//
+//	{
+//	  "tempo": 120,
+//	  "instruments": {
+//	    "lead": {"oscillator": "sine", "attack": "10ms", "release": "200ms", "gain": 0.5},
+//	    "kick": {"sample": "audio_files/kick.wav"}
+//	  },
+//	  "patterns": {"beat": "x---x---x---x---", "melody": "c4 e4 g4 . c5 - - ."},
+//	  "tracks": [
+//	    {"name": "drums", "instrument": "kick", "clips": [{"pattern": "beat", "bar": 0, "repeat": 4}]},
+//	    {"name": "lead", "instrument": "lead", "effects": [{"type": "tremolo", "params": {"rate": 4, "depth": 0.3}}],
+//	     "clips": [{"pattern": "melody", "bar": 2, "repeat": 2}]}
+//	  ]
+//	}
+type Song struct {
+	// Tempo in beats per minute (default: 120).
+	Tempo float64 `json:"tempo"`
+	// StepsPerBeat is the number of pattern steps per beat (default: 4, sixteenth notes).
+	StepsPerBeat int `json:"steps_per_beat"`
+	// BeatsPerBar is used to place clips on the arrangement (default: 4).
+	BeatsPerBar int                   `json:"beats_per_bar"`
+	Instruments map[string]Instrument `json:"instruments"`
+	// Patterns are written in the text notation of the pattern package (drum triggers or melodies).
+	Patterns map[string]string `json:"patterns"`
+	Tracks   []Track           `json:"tracks"`
+
+	// dir is used to resolve relative sample paths.
+	dir string
+}
+
+// Instrument describes how notes are played: either with an oscillator or with a sample.
+type Instrument struct {
+	// Oscillator is the waveform of a synthesized instrument ("sine").
+	Oscillator string `json:"oscillator,omitempty"`
+	// Sample is the path of a WAV file (relative to the song file), played from its beginning on each note.
+	Sample  string   `json:"sample,omitempty"`
+	Attack  Duration `json:"attack,omitempty"`
+	Release Duration `json:"release,omitempty"`
+	// Gain defaults to 1.
+	Gain *float64 `json:"gain,omitempty"`
+}
+
+// Track plays the clips of the arrangement with an instrument and a chain of effects.
+type Track struct {
+	Name       string   `json:"name"`
+	Instrument string   `json:"instrument"`
+	Effects    []Effect `json:"effects,omitempty"`
+	Clips      []Clip   `json:"clips"`
+	// Gain defaults to 1.
+	Gain *float64 `json:"gain,omitempty"`
+	Mute bool     `json:"mute,omitempty"`
+}
+
+// Effect is an effect applied to a track (see RegisterEffect for available types).
+type Effect struct {
+	Type   string             `json:"type"`
+	Params map[string]float64 `json:"params,omitempty"`
+}
+
+// Clip places a pattern on the arrangement.
+type Clip struct {
+	Pattern string `json:"pattern"`
+	// Bar is the position of the clip on the arrangement (starting at 0).
+	Bar float64 `json:"bar"`
+	// Repeat is the number of times the pattern is played (default: 1).
+	Repeat int `json:"repeat,omitempty"`
+}
+
+// Duration is a time.Duration encoded as a string in JSON (for example: "250ms").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("duration should be a string (for example: \"250ms\"): %w", err)
+	}
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Parse decodes a JSON song.
+func Parse(r io.Reader) (*Song, error) {
+	s := &Song{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("decode JSON: %w", err)
+	}
+	s.setDefaults()
+	return s, nil
+}
+
+// Reads and decodes a JSON song file.
+// Sample paths are resolved relative to the song file.
+func Import(path string) (*Song, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %s: %w", path, err)
+	}
+	defer f.Close()
+
+	s, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse file: %s: %w", path, err)
+	}
+	s.dir = filepath.Dir(path)
+	return s, nil
+}
+
+func MustImport(path string) *Song {
+	out, err := Import(path)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Step returns the duration of a pattern step.
+func (s *Song) Step() time.Duration {
+	return time.Duration(float64(time.Minute) / s.Tempo / float64(s.StepsPerBeat))
+}
+
+// Bar returns the duration of a bar.
+func (s *Song) Bar() time.Duration {
+	return time.Duration(float64(time.Minute) / s.Tempo * float64(s.BeatsPerBar))
+}
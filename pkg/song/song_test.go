@@ -0,0 +1,113 @@
+package song
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    func(s *Song) bool
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			in:   `{}`,
+			want: func(s *Song) bool { return s.Tempo == 120 && s.StepsPerBeat == 4 && s.BeatsPerBar == 4 },
+		},
+		{
+			name: "durations",
+			in:   `{"tempo": 90, "instruments": {"lead": {"oscillator": "sine", "attack": "10ms", "release": "1.5s"}}}`,
+			want: func(s *Song) bool {
+				lead := s.Instruments["lead"]
+				return s.Tempo == 90 && lead.Attack == Duration(10*time.Millisecond) && lead.Release == Duration(1500*time.Millisecond)
+			},
+		},
+		{name: "unknown field", in: `{"bpm": 120}`, wantErr: true},
+		{name: "invalid duration", in: `{"instruments": {"lead": {"attack": "10"}}}`, wantErr: true},
+		{name: "duration without quotes", in: `{"instruments": {"lead": {"attack": 10}}}`, wantErr: true},
+		{name: "invalid JSON", in: `{"tempo": }`, wantErr: true},
+	}
+	for _, tt := range tests {
+		s, err := Parse(strings.NewReader(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error: %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !tt.want(s) {
+			t.Errorf("%s: song = %+v", tt.name, s)
+		}
+	}
+}
+
+// testSong returns a song playing a4 (a sine) during a step at the beginning of the second half of the second bar
+// (at 3s, since bars last 2s at 120 beats per minute).
+func testSong(edit func(s *Song)) *Song {
+	s := &Song{
+		Instruments: map[string]Instrument{"lead": {Oscillator: "sine"}},
+		Patterns:    map[string]string{"melody": "a4"},
+		Tracks:      []Track{{Name: "lead", Instrument: "lead", Clips: []Clip{{Pattern: "melody", Bar: 1.5}}}},
+	}
+	if edit != nil {
+		edit(s)
+	}
+	return s
+}
+
+func TestTimeline(t *testing.T) {
+	const peak = time.Second / 4 / 440 // first peak of a4
+	const velocity = 100.0 / 127       // velocity of pattern steps without a velocity (see midi.PatternTrack)
+	tests := []struct {
+		name     string
+		song     *Song
+		at       time.Duration
+		want     float64
+		duration time.Duration
+		wantErr  bool
+	}{
+		{name: "before the clip", song: testSong(nil), at: 3*time.Second - peak, want: 0, duration: 3125 * time.Millisecond},
+		{name: "clip placed in bars", song: testSong(nil), at: 3*time.Second + peak, want: velocity, duration: 3125 * time.Millisecond},
+		{name: "after the clip", song: testSong(nil), at: 3125*time.Millisecond + peak, want: 0, duration: 3125 * time.Millisecond},
+		{
+			name: "tempo and repeats",
+			song: testSong(func(s *Song) { s.Tempo, s.Tracks[0].Clips[0].Repeat = 60, 2 }),
+			at:   6*time.Second + 250*time.Millisecond + peak, want: velocity, duration: 6500 * time.Millisecond,
+		},
+		{
+			name: "effects and gain",
+			song: testSong(func(s *Song) {
+				gain := 0.5
+				s.Tracks[0].Gain = &gain
+				s.Tracks[0].Effects = []Effect{{Type: "gain", Params: map[string]float64{"gain": 0.5}}}
+			}),
+			at: 3*time.Second + peak, want: 0.25 * velocity, duration: 3125 * time.Millisecond,
+		},
+		{name: "muted track", song: testSong(func(s *Song) { s.Tracks[0].Mute = true }), at: 3*time.Second + peak, want: 0},
+		{name: "unknown effect", song: testSong(func(s *Song) { s.Tracks[0].Effects = []Effect{{Type: "chorus"}} }), wantErr: true},
+		{name: "invalid effect", song: testSong(func(s *Song) { s.Tracks[0].Effects = []Effect{{Type: "gain"}} }), wantErr: true},
+		{name: "missing pattern", song: testSong(func(s *Song) { s.Tracks[0].Clips[0].Pattern = "bass" }), wantErr: true},
+		{name: "missing instrument", song: testSong(func(s *Song) { s.Tracks[0].Instrument = "bass" }), wantErr: true},
+		{name: "invalid pattern", song: testSong(func(s *Song) { s.Patterns["melody"] = "h4" }), wantErr: true},
+		{name: "invalid instrument", song: testSong(func(s *Song) { s.Instruments["lead"] = Instrument{Oscillator: "square"} }), wantErr: true},
+	}
+	for _, tt := range tests {
+		tl, err := tt.song.Timeline()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error: %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := tl.Wave()(tt.at); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s: wave at %s = %v, want %v", tt.name, tt.at, got, tt.want)
+		}
+		if got := tl.Duration(); got != tt.duration {
+			t.Errorf("%s: duration = %s, want %s", tt.name, got, tt.duration)
+		}
+	}
+}
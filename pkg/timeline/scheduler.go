@@ -0,0 +1,154 @@
+package timeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Event is triggered by a Scheduler at a position of the song.
+type Event struct {
+	At time.Duration
+	// Func is called when the event is triggered, with the position of the frame triggering it
+	// (for example: to synchronize visuals, or to schedule the next events of a sequencer).
+	// It is called from the render loop, so it should return quickly.
+	Func func(at time.Duration)
+	// Wave is played from its beginning (at the position of the event) when the event is triggered
+	// (for example: a note or a clip).
+	Wave wave.Wave
+	// Duration is how long the wave plays (0 plays it until the scheduler is cleared).
+	Duration time.Duration
+}
+
+// Scheduler triggers events at precise positions while its wave is rendered:
+// events are triggered by the first frame at (or after) their position,
+// and their waves start exactly at their position (not at the next frame).
+//
+// The positions of the scheduler are the positions at which its wave is evaluated,
+// so playing it through an audio.Transport makes them relative to the transport (pausing it pauses the events).
+// Since audio is rendered ahead of playback, functions are called a little before the frame is heard (by the output latency).
+//
+// Its methods can be called from any goroutine (and from the functions of events).
+type Scheduler struct {
+	mu      sync.Mutex
+	events  []Event // sorted by position
+	next    int     // index of the first event that wasn't triggered
+	late    []Event // events scheduled before the current position, triggered by the next frame
+	voices  []scheduledVoice
+	last    time.Duration
+	started bool
+}
+
+type scheduledVoice struct {
+	start, end time.Duration // end is 0 for voices playing until the scheduler is cleared
+	wave       wave.Wave
+}
+
+// NewScheduler returns a scheduler with the events (in any order).
+func NewScheduler(events ...Event) *Scheduler {
+	s := &Scheduler{}
+	for _, e := range events {
+		s.Schedule(e)
+	}
+	return s
+}
+
+// Schedule adds an event.
+// Events scheduled before the current position are triggered by the next frame
+// (their waves play from where they would be, not from their beginning).
+// Events at the same position are triggered in the order in which they were scheduled.
+func (s *Scheduler) Schedule(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.events), func(i int) bool { return s.events[i].At > e.At })
+	s.events = append(s.events, Event{})
+	copy(s.events[i+1:], s.events[i:])
+	s.events[i] = e
+	if i < s.next {
+		s.next++
+		s.late = append(s.late, e)
+	}
+}
+
+// ScheduleClip plays the clip (with its effects) at its position.
+func (s *Scheduler) ScheduleClip(c Clip) {
+	s.Schedule(Event{At: c.Start, Wave: c.Output(), Duration: c.Length})
+}
+
+// Clear removes all events and stops the waves they play.
+func (s *Scheduler) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events, s.late, s.voices, s.next = nil, nil, nil, 0
+}
+
+// Wave returns the sum of the waves played by the events, and triggers the events while it is evaluated.
+//
+// Evaluating a position before the last one (for example: when the transport seeks backward)
+// triggers the events again from there, and restarts the waves that would be playing at that position.
+// The wave keeps state between evaluations (see wave.Wave).
+func (s *Scheduler) Wave() wave.Wave {
+	triggered := []Event{}
+	return func(x time.Duration) float64 {
+		s.mu.Lock()
+		if s.started && x < s.last {
+			s.seek(x)
+		}
+		s.started, s.last = true, x
+
+		triggered = append(triggered[:0], s.late...)
+		s.late = s.late[:0]
+		for s.next < len(s.events) && s.events[s.next].At <= x {
+			triggered = append(triggered, s.events[s.next])
+			s.next++
+		}
+		for _, e := range triggered {
+			s.start(e)
+		}
+
+		sum := 0.0
+		playing := s.voices[:0]
+		for _, v := range s.voices {
+			if v.end > 0 && x >= v.end {
+				continue
+			}
+			playing = append(playing, v)
+			sum += v.wave(x - v.start)
+		}
+		s.voices = playing
+		s.mu.Unlock()
+
+		// Functions are called without holding the lock, so they can schedule events
+		for _, e := range triggered {
+			if e.Func != nil {
+				e.Func(x)
+			}
+		}
+		return sum
+	}
+}
+
+// start plays the wave of an event.
+func (s *Scheduler) start(e Event) {
+	if e.Wave == nil {
+		return
+	}
+	v := scheduledVoice{start: e.At, wave: e.Wave}
+	if e.Duration > 0 {
+		v.end = e.At + e.Duration
+	}
+	s.voices = append(s.voices, v)
+}
+
+// seek restarts the events from a position before the current one.
+func (s *Scheduler) seek(x time.Duration) {
+	s.voices, s.late = s.voices[:0], s.late[:0]
+	s.next = sort.Search(len(s.events), func(i int) bool { return s.events[i].At >= x })
+	for _, e := range s.events[:s.next] {
+		if e.Duration <= 0 || e.At+e.Duration > x {
+			s.start(e)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package timeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Effect processes a wave (for example: a filter or a reverb).
+type Effect func(src wave.Wave) wave.Wave
+
+// Clip is a wave placed on the timeline.
+type Clip struct {
+	Name string
+	// Start is the position of the clip on the timeline.
+	Start time.Duration
+	// Length is how long the clip plays.
+	Length time.Duration
+	// Wave is the source of the clip (relative to the beginning of the clip).
+	Wave wave.Wave
+	// Effects are applied (in order) to the wave of the clip.
+	Effects []Effect
+}
+
+// Output returns the wave of the clip with its effects applied.
+func (c Clip) Output() wave.Wave {
+	out := c.Wave
+	for _, effect := range c.Effects {
+		out = effect(out)
+	}
+	return out
+}
+
+// Timeline holds the clips of an arrangement.
+type Timeline struct {
+	Clips []Clip
+}
+
+// Wave returns the sum of all clips, each playing at its position.
+func (t *Timeline) Wave() wave.Wave {
+	type placed struct {
+		start, end time.Duration
+		wave       wave.Wave
+	}
+	clips := make([]placed, 0, len(t.Clips))
+	for _, c := range t.Clips {
+		clips = append(clips, placed{start: c.Start, end: c.Start + c.Length, wave: c.Output()})
+	}
+
+	return func(x time.Duration) float64 {
+		sum := 0.0
+		for _, c := range clips {
+			if x >= c.start && x < c.end {
+				sum += c.wave(x - c.start)
+			}
+		}
+		return sum
+	}
+}
+
+// Duration returns the end position of the last clip.
+func (t *Timeline) Duration() time.Duration {
+	end := time.Duration(0)
+	for _, c := range t.Clips {
+		if c.Start+c.Length > end {
+			end = c.Start + c.Length
+		}
+	}
+	return end
+}
+
+// Bounce renders the named clip (with its effects) and replaces it in place
+// with the rendered frames, which reduces the CPU needed for further playback.
+//
+// Frames are rendered on the same frame grid as the timeline (for the provided sample rate),
+// so the bounced clip sounds exactly the same when the timeline is rendered at that rate with audio.FrameRange.
+func (t *Timeline) Bounce(name string, sampleRate int) error {
+	if sampleRate <= 0 {
+		return fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	for i, c := range t.Clips {
+		if c.Name != name {
+			continue
+		}
+		if c.Length <= 0 {
+			return fmt.Errorf("cannot bounce a clip without length: %s", name)
+		}
+
+		// Render the timeline frames covered by the clip, on the frame grid of audio.FrameRange
+		start := c.Start
+		first := frameIndex(c.Start, sampleRate)
+		count := frameIndex(c.Start+c.Length, sampleRate) - first
+		frames := audio.FrameRange(wave.Shift(c.Output(), -start), sampleRate, int(first), int(count))
+
+		c.Wave = func(x time.Duration) float64 {
+			index := frameIndex(start+x, sampleRate) - first
+			if index < 0 || index >= int64(len(frames)) {
+				return 0
+			}
+			return frames[index]
+		}
+		c.Effects = nil
+		t.Clips[i] = c
+		return nil
+	}
+	return fmt.Errorf("clip not found: %s", name)
+}
+
+// frameIndex returns the index of the first frame at or after x on the frame grid of audio.FrameRange.
+func frameIndex(x time.Duration, sampleRate int) int64 {
+	n := int64(x) * int64(sampleRate)
+	if n <= 0 {
+		return n / int64(time.Second)
+	}
+	return (n + int64(time.Second) - 1) / int64(time.Second)
+}
@@ -0,0 +1,49 @@
+package timeline
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestBounce(t *testing.T) {
+	const sampleRate = 44100
+	// A sine ramping up, so a shifted frame changes the output
+	src := func(x time.Duration) float64 { return x.Seconds() * math.Sin(2*math.Pi*440*x.Seconds()) }
+	double := func(src wave.Wave) wave.Wave { return func(x time.Duration) float64 { return 2 * src(x) } }
+	for _, start := range []time.Duration{0, 10 * time.Millisecond, 10*time.Millisecond + 12345} {
+		tl := &Timeline{Clips: []Clip{{Name: "clip", Start: start, Length: 50*time.Millisecond + 678, Wave: src, Effects: []Effect{double}}}}
+		count := int((tl.Duration() + 100*time.Millisecond).Seconds() * sampleRate)
+		want := audio.FrameRange(tl.Wave(), sampleRate, 0, count)
+		if err := tl.Bounce("clip", sampleRate); err != nil {
+			t.Fatal(err)
+		}
+		got := audio.FrameRange(tl.Wave(), sampleRate, 0, count)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("start %s: frame %d = %v, want %v", start, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestBounceErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		clip       string
+		sampleRate int
+	}{
+		{name: "missing clip", clip: "missing", sampleRate: 44100},
+		{name: "invalid sample rate", clip: "clip", sampleRate: 0},
+		{name: "clip without length", clip: "empty", sampleRate: 44100},
+	}
+	for _, tt := range tests {
+		tl := &Timeline{Clips: []Clip{{Name: "clip", Length: time.Second, Wave: wave.Const(1)}, {Name: "empty", Wave: wave.Const(1)}}}
+		if err := tl.Bounce(tt.clip, tt.sampleRate); err == nil {
+			t.Errorf("%s: bounced without error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package viz visualizes audio: in a terminal while it is played, or as images of renders.
+package viz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope draws an oscilloscope and a level meter of the played audio in a terminal (with ANSI escape codes),
+// so every player backend gets visual feedback.
+//
+// Its Write method can be used as the Monitor of a player config.
+type Scope struct {
+	width, height, channels int
+
+	mu      sync.Mutex
+	samples []float64 // last samples (mixed to mono), ring buffer
+	next    int
+	peak    float64 // since the last draw
+	sum     float64 // sum of squares since the last draw
+	count   int
+}
+
+// NewScope creates a scope of width by height characters, for interleaved frames with the given number of channels.
+func NewScope(width, height, channels int) *Scope {
+	if width <= 0 {
+		width = 64
+	}
+	if height <= 0 {
+		height = 12
+	}
+	if channels <= 0 {
+		channels = 1
+	}
+	return &Scope{width: width, height: height, channels: channels, samples: make([]float64, 4*width)}
+}
+
+// Write receives played frames, it is safe to call from the render loop.
+func (s *Scope) Write(frames []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+s.channels <= len(frames); i += s.channels {
+		v := 0.0
+		for c := 0; c < s.channels; c++ {
+			v += frames[i+c]
+		}
+		v /= float64(s.channels)
+		s.samples[s.next] = v
+		s.next = (s.next + 1) % len(s.samples)
+		if math.Abs(v) > s.peak {
+			s.peak = math.Abs(v)
+		}
+		s.sum += v * v
+		s.count++
+	}
+}
+
+// Draw returns the current view: the waveform of the last samples, and the peak and RMS levels since the last draw.
+func (s *Scope) Draw() string {
+	s.mu.Lock()
+	rows := make([][]byte, s.height)
+	for r := range rows {
+		rows[r] = []byte(strings.Repeat(" ", s.width))
+	}
+	step := len(s.samples) / s.width
+	for col := 0; col < s.width; col++ {
+		v := s.samples[(s.next+col*step)%len(s.samples)]
+		v = math.Max(-1, math.Min(1, v))
+		rows[int(math.Round((1-v)/2*float64(s.height-1)))][col] = '*'
+	}
+	peak, rms := s.peak, 0.0
+	if s.count > 0 {
+		rms = math.Sqrt(s.sum / float64(s.count))
+	}
+	s.peak, s.sum, s.count = 0, 0, 0
+	s.mu.Unlock()
+
+	b := &strings.Builder{}
+	for _, row := range rows {
+		b.WriteString("|" + string(row) + "|\n")
+	}
+	b.WriteString(meter("peak", peak, s.width) + "\n")
+	b.WriteString(meter("rms ", rms, s.width) + "\n")
+	return b.String()
+}
+
+// meter returns a bar between -60dB and 0dB.
+func meter(label string, level float64, width int) string {
+	db := 20 * math.Log10(level)
+	if level <= 0 || db < -60 {
+		db = -60
+	}
+	size := width - 16
+	if size < 1 {
+		size = 1
+	}
+	n := int(math.Min(1, (db+60)/60) * float64(size))
+	clip := " "
+	if level >= 1 {
+		clip = "!" // clipping
+	}
+	return fmt.Sprintf("%s %6.1fdB [%s%s]%s", label, db, strings.Repeat("#", n), strings.Repeat(".", size-n), clip)
+}
+
+// Run redraws the scope in place on the writer (usually os.Stderr) every interval (default: 50ms),
+// until the context is cancelled.
+func (s *Scope) Run(ctx context.Context, w io.Writer, interval time.Duration) {
+	if interval <= 0 {
+		interval = 50 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lines := s.height + 2
+	for first := true; ; first = false {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !first {
+			fmt.Fprintf(w, "\x1b[%dA", lines) // move back to the top of the previous view
+		}
+		fmt.Fprint(w, s.Draw())
+	}
+}
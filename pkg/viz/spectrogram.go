@@ -0,0 +1,139 @@
+package viz
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/cmplx"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+)
+
+// SpectrogramConfig describes how a spectrogram is computed and drawn.
+type SpectrogramConfig struct {
+	// SampleRate of the frames (default: 44100).
+	SampleRate int
+	// WindowSize is the number of frames analyzed for each column (a power of 2, default: 2048):
+	// larger windows separate close frequencies better, smaller windows show faster changes.
+	WindowSize int
+	// MinDecibels is the level drawn in the darkest color (default: -90dB), louder levels are brighter up to 0dB.
+	MinDecibels float64
+	// Window weights the analyzed frames (default: analyze.Hann).
+	Window analyze.Window
+	// LogFrequency draws frequencies on a logarithmic scale (from 20Hz, like musical pitch) instead of a linear one.
+	LogFrequency bool
+}
+
+func (c *SpectrogramConfig) setDefaults() error {
+	if c.SampleRate <= 0 {
+		c.SampleRate = 44100
+	}
+	if c.WindowSize <= 0 {
+		c.WindowSize = 2048
+	}
+	if c.WindowSize&(c.WindowSize-1) != 0 {
+		return fmt.Errorf("window size should be a power of 2: %d", c.WindowSize)
+	}
+	if c.Window == nil {
+		c.Window = analyze.Hann
+	}
+	if c.MinDecibels >= 0 {
+		c.MinDecibels = -90
+	}
+	return nil
+}
+
+// Spectrogram draws the frequency content of (mono) frames over time (short-time Fourier transform):
+// time goes from left to right, frequencies from the bottom (0Hz) to the top (half the sample rate).
+func Spectrogram(frames []float64, w, h int, config SpectrogramConfig) (*image.RGBA, error) {
+	if err := config.setDefaults(); err != nil {
+		return nil, err
+	}
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid image size: %dx%d", w, h)
+	}
+	n := config.WindowSize
+	window, gain := make([]float64, n), 0.0
+	for i := range window {
+		window[i] = config.Window(i, n)
+		gain += window[i]
+	}
+
+	// Range of FFT bins drawn in each row (from the top)
+	nyquist := float64(config.SampleRate) / 2
+	binOf := func(row float64) float64 {
+		t := 1 - row/float64(h) // 1 at the top, 0 at the bottom
+		f := t * nyquist
+		if config.LogFrequency {
+			f = 20 * math.Pow(nyquist/20, t)
+		}
+		return f / float64(config.SampleRate) * float64(n)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	buf := make([]complex128, n)
+	for x := 0; x < w; x++ {
+		start := x*len(frames)/w - n/2
+		for i := range buf {
+			v := 0.0
+			if j := start + i; j >= 0 && j < len(frames) {
+				v = frames[j] * window[i]
+			}
+			buf[i] = complex(v, 0)
+		}
+		analyze.FFT(buf)
+
+		for y := 0; y < h; y++ {
+			low, high := int(binOf(float64(y+1))), int(binOf(float64(y)))
+			if high <= low {
+				high = low + 1
+			}
+			magnitude := 0.0
+			for b := low; b < high && b <= n/2; b++ {
+				magnitude = math.Max(magnitude, cmplx.Abs(buf[b]))
+			}
+			// The amplitude of a full-scale sine is 1 (corrected for the window)
+			db := 20 * math.Log10(2*magnitude/gain)
+			img.Set(x, y, heatColor((db-config.MinDecibels)/-config.MinDecibels))
+		}
+	}
+	return img, nil
+}
+
+// SpectrogramPNG draws a spectrogram of the frames (see Spectrogram) and encodes it as PNG.
+func SpectrogramPNG(frames []float64, w, h int, config SpectrogramConfig) ([]byte, error) {
+	img, err := Spectrogram(frames, w, h, config)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Colors of the heat map, from silent to loud.
+var heatColors = []color.RGBA{
+	{R: 0x00, G: 0x00, B: 0x04, A: 0xff},
+	{R: 0x42, G: 0x0a, B: 0x68, A: 0xff},
+	{R: 0x93, G: 0x26, B: 0x67, A: 0xff},
+	{R: 0xdd, G: 0x51, B: 0x3a, A: 0xff},
+	{R: 0xfc, G: 0xa5, B: 0x0a, A: 0xff},
+	{R: 0xfc, G: 0xff, B: 0xa4, A: 0xff},
+}
+
+// heatColor interpolates the heat map (t between 0 and 1).
+func heatColor(t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t)) * float64(len(heatColors)-1)
+	i := int(t)
+	if i >= len(heatColors)-1 {
+		return heatColors[len(heatColors)-1]
+	}
+	a, b, f := heatColors[i], heatColors[i+1], t-float64(i)
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
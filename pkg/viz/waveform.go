@@ -0,0 +1,71 @@
+package viz
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// Colors of waveform images.
+var (
+	backgroundColor = color.RGBA{R: 0x16, G: 0x16, B: 0x1d, A: 0xff}
+	axisColor       = color.RGBA{R: 0x40, G: 0x40, B: 0x4c, A: 0xff}
+	peakColor       = color.RGBA{R: 0x4f, G: 0x9d, B: 0xde, A: 0xff}
+	rmsColor        = color.RGBA{R: 0xa8, G: 0xd4, B: 0xf5, A: 0xff}
+)
+
+// Waveform draws an overview of (mono) frames: for each column, the range between the lowest and highest values
+// (values outside of [-1, 1] are clipped), and the RMS level in a lighter color.
+func Waveform(frames []float64, w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, backgroundColor)
+		}
+	}
+	toY := func(v float64) int {
+		v = math.Max(-1, math.Min(1, v))
+		return int(math.Round((1 - v) / 2 * float64(h-1)))
+	}
+	for x := 0; x < w; x++ {
+		img.Set(x, toY(0), axisColor)
+	}
+	if len(frames) == 0 {
+		return img
+	}
+
+	for x := 0; x < w; x++ {
+		start, end := x*len(frames)/w, (x+1)*len(frames)/w
+		if end <= start {
+			end = start + 1
+		}
+		low, high, sum := math.Inf(1), math.Inf(-1), 0.0
+		for _, v := range frames[start:end] {
+			low, high = math.Min(low, v), math.Max(high, v)
+			sum += v * v
+		}
+		rms := math.Sqrt(sum / float64(end-start))
+		for y := toY(high); y <= toY(low); y++ {
+			img.Set(x, y, peakColor)
+		}
+		for y := toY(math.Min(rms, high)); y <= toY(math.Max(-rms, low)); y++ {
+			img.Set(x, y, rmsColor)
+		}
+	}
+	return img
+}
+
+// WaveformPNG draws an overview of the frames (see Waveform) and encodes it as PNG.
+func WaveformPNG(frames []float64, w, h int) ([]byte, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid image size: %dx%d", w, h)
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, Waveform(frames, w, h)); err != nil {
+		return nil, fmt.Errorf("encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
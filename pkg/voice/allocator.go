@@ -0,0 +1,219 @@
+package voice
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+type AllocatorConfig struct {
+	// Instrument produces the wave of each voice.
+	Instrument note.Instrument
+	// MaxVoices is the maximum number of simultaneous voices (default: 16).
+	// When a new note exceeds it, a voice is stolen (the oldest released voice, or the oldest voice).
+	MaxVoices int
+	// Release is how long a voice keeps playing once its note has been released,
+	// so the instrument can let its envelope fade out.
+	Release time.Duration
+}
+
+// Allocator plays notes received in real time (from a MIDI keyboard, a UI, etc.) on a limited set of voices.
+//
+// NoteOn and NoteOff can be called from any goroutine while the wave is being played.
+// Notes start (and are released) at the position of the wave that is rendered when they are received.
+type Allocator struct {
+	config  AllocatorConfig
+	mu      sync.Mutex
+	now     time.Duration
+	pending []event
+	voices  []*voice
+	sustain bool
+}
+
+type eventKind int
+
+const (
+	noteOn eventKind = iota
+	noteOff
+	sustainOn
+	sustainOff
+)
+
+type event struct {
+	kind     eventKind
+	note     note.Note
+	velocity float64
+}
+
+type voice struct {
+	note     note.Note
+	start    time.Duration
+	released time.Duration // negative while the note is held
+	held     bool          // note released while the sustain pedal is down
+	wave     wave.Wave
+}
+
+func NewAllocator(config AllocatorConfig) (*Allocator, error) {
+	if config.Instrument == nil {
+		return nil, errors.New("no instrument was provided")
+	}
+	if config.MaxVoices <= 0 {
+		config.MaxVoices = 16
+	}
+	return &Allocator{config: config}, nil
+}
+
+// NoteOn starts a new voice playing the note.
+func (a *Allocator) NoteOn(n note.Note, velocity float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, event{kind: noteOn, note: n, velocity: velocity})
+}
+
+// NoteOff releases the voices playing the note.
+func (a *Allocator) NoteOff(n note.Note) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, event{kind: noteOff, note: n})
+}
+
+// Sustain sets the state of the sustain pedal.
+// While the pedal is down, released notes keep playing until the pedal is up.
+func (a *Allocator) Sustain(on bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if on {
+		a.pending = append(a.pending, event{kind: sustainOn})
+		return
+	}
+	a.pending = append(a.pending, event{kind: sustainOff})
+}
+
+// AllNotesOff releases all voices.
+func (a *Allocator) AllNotesOff() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = nil
+	a.sustain = false
+	for _, v := range a.voices {
+		if v.released < 0 {
+			v.held = false
+			v.released = a.now
+		}
+	}
+}
+
+// SetMaxVoices changes the maximum number of simultaneous voices,
+// for example to lower the CPU load when a render watchdog reports an overload.
+func (a *Allocator) SetMaxVoices(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.config.MaxVoices = n
+	for len(a.voices) > n {
+		a.steal()
+	}
+}
+
+// MaxVoices returns the maximum number of simultaneous voices.
+func (a *Allocator) MaxVoices() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.config.MaxVoices
+}
+
+// Voices returns the number of voices currently playing.
+func (a *Allocator) Voices() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.voices)
+}
+
+// Wave returns the sum of all playing voices.
+// It should be played by a single (real-time) render loop, with increasing positions.
+func (a *Allocator) Wave() wave.Wave {
+	return func(x time.Duration) float64 {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if x > a.now {
+			a.now = x
+		}
+		a.handlePending()
+
+		sum := 0.0
+		playing := a.voices[:0]
+		for _, v := range a.voices {
+			if v.released >= 0 && x >= v.released+a.config.Release {
+				continue // voice is done
+			}
+			playing = append(playing, v)
+			sum += v.wave(x - v.start)
+		}
+		a.voices = playing
+		return sum
+	}
+}
+
+func (a *Allocator) handlePending() {
+	for _, e := range a.pending {
+		switch e.kind {
+		case noteOn:
+			a.start(e.note, e.velocity)
+		case noteOff:
+			for _, v := range a.voices {
+				if v.note != e.note || v.released >= 0 || v.held {
+					continue
+				}
+				if a.sustain {
+					v.held = true
+					continue
+				}
+				v.released = a.now
+			}
+		case sustainOn:
+			a.sustain = true
+		case sustainOff:
+			a.sustain = false
+			for _, v := range a.voices {
+				if v.held {
+					v.held = false
+					v.released = a.now
+				}
+			}
+		}
+	}
+	a.pending = a.pending[:0]
+}
+
+func (a *Allocator) start(n note.Note, velocity float64) {
+	for len(a.voices) >= a.config.MaxVoices {
+		a.steal()
+	}
+	v := &voice{note: n, start: a.now, released: -1}
+	gate := func(x time.Duration) float64 {
+		if v.released >= 0 && x >= v.released-v.start {
+			return 0
+		}
+		return 1
+	}
+	v.wave = a.config.Instrument(n, velocity, gate)
+	a.voices = append(a.voices, v)
+}
+
+// steal removes the oldest released voice, or the oldest voice if all voices are held.
+func (a *Allocator) steal() {
+	index := 0
+	for i, v := range a.voices {
+		if v.released >= 0 {
+			index = i
+			break
+		}
+	}
+	a.voices = append(a.voices[:index], a.voices[index+1:]...)
+}
@@ -0,0 +1,55 @@
+package voice
+
+import (
+	"math"
+
+	"github.com/ejuju/ziq/pkg/dsp"
+	"github.com/ejuju/ziq/pkg/note"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Dynamics describes how an instrument responds to the velocity of its notes (between 0 and 1).
+type Dynamics struct {
+	// Amplitude maps the velocity to the gain of notes (default: wave.Linear).
+	// wave.Exponential makes soft notes much quieter, wave.EaseOutQuad makes them louder.
+	Amplitude wave.Curve
+	// Cutoff maps the velocity to the cutoff frequency of a low-pass filter, from MinCutoff to MaxCutoff
+	// (default: wave.Linear, exponentially between the frequencies), so soft notes sound darker,
+	// like most acoustic instruments. There is no filter when MaxCutoff is 0.
+	Cutoff               wave.Curve
+	MinCutoff, MaxCutoff float64
+	// SampleRate is the sample rate of the filter (default: 44100), notes should be played at this rate.
+	SampleRate int
+}
+
+func (d *Dynamics) setDefaults() {
+	if d.Amplitude == nil {
+		d.Amplitude = wave.Linear
+	}
+	if d.Cutoff == nil {
+		d.Cutoff = wave.Linear
+	}
+	if d.MinCutoff <= 0 {
+		d.MinCutoff = math.Min(200, d.MaxCutoff)
+	}
+	if d.SampleRate <= 0 {
+		d.SampleRate = 44100
+	}
+}
+
+// Apply returns an instrument playing the notes of src with the dynamics.
+// src receives a velocity of 1, since the dynamics apply the velocity.
+//
+// With a filter, the waves of notes are stateful (see wave.Wave).
+func (d Dynamics) Apply(src note.Instrument) note.Instrument {
+	d.setDefaults()
+	return func(n note.Note, velocity float64, gate wave.Wave) wave.Wave {
+		velocity = math.Max(0, math.Min(1, velocity))
+		out := wave.Amplitude(src(n, 1, gate), wave.Const(d.Amplitude(velocity)))
+		if d.MaxCutoff <= 0 {
+			return out
+		}
+		cutoff := d.MinCutoff * math.Pow(d.MaxCutoff/d.MinCutoff, d.Cutoff(velocity))
+		return dsp.ToWave(dsp.Apply(dsp.FromWave(out), dsp.NewLowPass(d.SampleRate, cutoff, math.Sqrt2/2)))
+	}
+}
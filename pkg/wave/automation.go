@@ -0,0 +1,130 @@
+package wave
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Step is a curve holding the previous value until the end of the transition, then jumping to the next one.
+func Step(t float64) float64 {
+	if t >= 1 {
+		return 1
+	}
+	return 0
+}
+
+// Keyframe is a value of an automation lane at a position of the song.
+type Keyframe struct {
+	At    time.Duration
+	Value float64
+	// Curve shapes the transition from the previous keyframe to this one (default: Linear, see also Step).
+	Curve Curve
+}
+
+// Automation is a lane of keyframes arranging the changes of a value over a song
+// (for example: a filter sweep or a volume ride), either defined or recorded from a Param.
+// Its wave can be used wherever a wave controls a value (like the gain of a mixer track).
+type Automation struct {
+	mu        sync.Mutex
+	keyframes []Keyframe // sorted by position
+}
+
+// NewAutomation returns a lane with the keyframes (in any order).
+func NewAutomation(keyframes ...Keyframe) *Automation {
+	a := &Automation{}
+	for _, k := range keyframes {
+		a.Add(k)
+	}
+	return a
+}
+
+// Add inserts a keyframe (replacing the keyframe at the same position, if any).
+func (a *Automation) Add(k Keyframe) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := sort.Search(len(a.keyframes), func(i int) bool { return a.keyframes[i].At >= k.At })
+	if i < len(a.keyframes) && a.keyframes[i].At == k.At {
+		a.keyframes[i] = k
+		return
+	}
+	a.keyframes = append(a.keyframes, Keyframe{})
+	copy(a.keyframes[i+1:], a.keyframes[i:])
+	a.keyframes[i] = k
+}
+
+// Keyframes returns a copy of the keyframes (sorted by position).
+func (a *Automation) Keyframes() []Keyframe {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Keyframe(nil), a.keyframes...)
+}
+
+// Value returns the value of the lane at x: the value of the first keyframe before it,
+// the value of the last keyframe after it, and the value on the curves in between (0 for empty lanes).
+func (a *Automation) Value(x time.Duration) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.keyframes) == 0 {
+		return 0
+	}
+	i := sort.Search(len(a.keyframes), func(i int) bool { return a.keyframes[i].At > x })
+	if i == 0 {
+		return a.keyframes[0].Value
+	}
+	if i == len(a.keyframes) {
+		return a.keyframes[i-1].Value
+	}
+	prev, next := a.keyframes[i-1], a.keyframes[i]
+	curve := next.Curve
+	if curve == nil {
+		curve = Linear
+	}
+	return prev.Value + (next.Value-prev.Value)*curve(float64(x-prev.At)/float64(next.At-prev.At))
+}
+
+// Wave returns a wave playing the lane, it follows keyframes added while it is played.
+func (a *Automation) Wave() Wave { return a.Value }
+
+// Shortest interval between two keyframes recorded while a parameter moves.
+const recordInterval = 5 * time.Millisecond
+
+// Record returns a wave playing the parameter (like Param.Wave), and writing its moves to the lane
+// as linear keyframes (at most every 5ms while it moves), for example to record the moves of a MIDI knob
+// and replay them later (with Wave).
+//
+// Playing from a position before the last recorded keyframe replaces the keyframes after it (punch-in).
+// The wave keeps state between evaluations (see Wave).
+func (a *Automation) Record(p *Param) Wave {
+	src := p.Wave()
+	recorded := false
+	last, previous := time.Duration(-1), 0.0 // last evaluation
+	keyAt, keyValue := time.Duration(0), 0.0 // last recorded keyframe
+	add := func(x time.Duration, v float64) {
+		a.Add(Keyframe{At: x, Value: v})
+		recorded, keyAt, keyValue = true, x, v
+	}
+	return func(x time.Duration) float64 {
+		v := src(x)
+		if x < last {
+			a.mu.Lock()
+			a.keyframes = a.keyframes[:sort.Search(len(a.keyframes), func(i int) bool { return a.keyframes[i].At >= x })]
+			a.mu.Unlock()
+			recorded = false
+		}
+
+		switch {
+		case !recorded:
+			add(x, v)
+		case v != previous && previous == keyValue && keyAt < last:
+			// The parameter starts moving: hold its value until now
+			add(last, previous)
+		}
+		// Record the moves, and the value where the parameter stops
+		if v != keyValue && (x-keyAt >= recordInterval || v == previous) {
+			add(x, v)
+		}
+		last, previous = x, v
+		return v
+	}
+}
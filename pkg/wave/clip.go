@@ -0,0 +1,114 @@
+package wave
+
+import (
+	"sort"
+	"time"
+)
+
+// Clip is a finite wave: a wave that knows its own duration (for example: an imported sample).
+// Its wave starts at 0 and is silent after the end of the clip.
+type Clip struct {
+	Wave     Wave
+	Duration time.Duration
+}
+
+// NewClip cuts the source wave after the given duration.
+func NewClip(src Wave, d time.Duration) Clip {
+	return Clip{
+		Wave: func(x time.Duration) float64 {
+			if x < 0 || x >= d {
+				return 0
+			}
+			return src(x)
+		},
+		Duration: d,
+	}
+}
+
+// Segment returns a sequence segment lasting the duration of the clip.
+func (c Clip) Segment() Segment { return Segment{Wave: c.Wave, Duration: c.Duration} }
+
+// Loop repeats the clip from its beginning each time it ends.
+func (c Clip) Loop() Wave { return LoopSequence(c.Segment()) }
+
+// Concat plays the clips end-to-end.
+// The resulting clip lasts the sum of their durations.
+func Concat(clips ...Clip) Clip { return ConcatCrossfade(0, clips...) }
+
+// ConcatCrossfade plays the clips end-to-end, each clip fading in (linearly) while the previous one fades out.
+// A fade is shortened to half of the shortest of the two clips when needed.
+func ConcatCrossfade(fade time.Duration, clips ...Clip) Clip {
+	if fade < 0 {
+		fade = 0
+	}
+	starts := make([]time.Duration, len(clips))
+	// fades[i] is the overlap between clip i-1 and clip i (fades[0] is always 0).
+	fades := make([]time.Duration, len(clips)+1)
+	end := time.Duration(0)
+	for i, c := range clips {
+		if i > 0 {
+			f := fade
+			if limit := minDuration(clips[i-1].Duration, c.Duration) / 2; f > limit {
+				f = limit
+			}
+			fades[i] = f
+		}
+		starts[i] = end - fades[i]
+		end = starts[i] + c.Duration
+	}
+
+	gain := func(i int, t time.Duration) float64 {
+		out := 1.0
+		if fadeIn := fades[i]; t < fadeIn {
+			out *= float64(t) / float64(fadeIn)
+		}
+		if fadeOut := fades[i+1]; t > clips[i].Duration-fadeOut {
+			out *= float64(clips[i].Duration-t) / float64(fadeOut)
+		}
+		return out
+	}
+
+	return Clip{
+		Wave: func(x time.Duration) float64 {
+			if x < 0 || x >= end {
+				return 0
+			}
+			i := sort.Search(len(starts), func(i int) bool { return starts[i] > x }) - 1
+			out := 0.0
+			// Only two adjacent clips can overlap.
+			for j := i - 1; j <= i; j++ {
+				if j < 0 {
+					continue
+				}
+				t := x - starts[j]
+				if t < clips[j].Duration {
+					out += clips[j].Wave(t) * gain(j, t)
+				}
+			}
+			return out
+		},
+		Duration: end,
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Reverse plays the clip backwards (for example: a reversed cymbal).
+func Reverse(src Clip) Clip {
+	// The end of a clip is excluded, so the reversed clip starts just before it (1ns).
+	last := src.Duration - 1
+	return Clip{
+		Wave: func(x time.Duration) float64 {
+			if x < 0 || x >= src.Duration {
+				return 0
+			}
+			return src.Wave(last - x)
+		},
+		Duration: src.Duration,
+	}
+}
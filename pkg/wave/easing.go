@@ -0,0 +1,39 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Easing curves (as found in animation libraries), to be used with Ease, FadeInCurve or FadeOutCurve.
+// "In" curves start slowly, "Out" curves end slowly and "InOut" curves do both.
+
+func EaseInQuad(t float64) float64  { return t * t }
+func EaseOutQuad(t float64) float64 { return 1 - (1-t)*(1-t) }
+func EaseInOutQuad(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return 1 - 2*(1-t)*(1-t)
+}
+
+func EaseInCubic(t float64) float64  { return t * t * t }
+func EaseOutCubic(t float64) float64 { return 1 - (1-t)*(1-t)*(1-t) }
+func EaseInOutCubic(t float64) float64 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	return 1 - 4*(1-t)*(1-t)*(1-t)
+}
+
+func EaseInSine(t float64) float64    { return 1 - math.Cos(t*math.Pi/2) }
+func EaseOutSine(t float64) float64   { return math.Sin(t * math.Pi / 2) }
+func EaseInOutSine(t float64) float64 { return (1 - math.Cos(t*math.Pi)) / 2 }
+
+// Ease goes from start to end during d, following the curve.
+// The value stays at start before 0 and at end after d.
+func Ease(start, end float64, d time.Duration, curve Curve) Wave {
+	return ramp(func(x time.Duration) float64 {
+		return start + (end-start)*curve(float64(x)/float64(d))
+	}, start, end, d)
+}
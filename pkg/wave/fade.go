@@ -0,0 +1,52 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Curve shapes a transition: it maps the progress of the transition (from 0 to 1)
+// to a gain (from 0 to 1).
+type Curve func(t float64) float64
+
+// Linear is a straight curve, fades sound like they speed up towards the end.
+func Linear(t float64) float64 { return t }
+
+// Exponential is a curve rising slowly first (over a range of about 60dB),
+// fades sound more even to the ear.
+func Exponential(t float64) float64 {
+	const k = 6.9 // ln(1000)
+	return (math.Exp(k*t) - 1) / (math.Exp(k) - 1)
+}
+
+// FadeIn raises the volume of the source wave from silence during the first d (linearly).
+func FadeIn(src Wave, d time.Duration) Wave { return FadeInCurve(src, d, Linear) }
+
+// FadeInCurve is like FadeIn, with a custom curve.
+func FadeInCurve(src Wave, d time.Duration, curve Curve) Wave {
+	return func(x time.Duration) float64 {
+		if x <= 0 {
+			return 0
+		}
+		if x >= d {
+			return src(x)
+		}
+		return src(x) * curve(float64(x)/float64(d))
+	}
+}
+
+// FadeOut lowers the volume of the source wave to silence during d, starting at the given time (linearly).
+func FadeOut(src Wave, at, d time.Duration) Wave { return FadeOutCurve(src, at, d, Linear) }
+
+// FadeOutCurve is like FadeOut, with a custom curve.
+func FadeOutCurve(src Wave, at, d time.Duration, curve Curve) Wave {
+	return func(x time.Duration) float64 {
+		if x < at {
+			return src(x)
+		}
+		if x >= at+d {
+			return 0
+		}
+		return src(x) * curve(1-float64(x-at)/float64(d))
+	}
+}
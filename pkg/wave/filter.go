@@ -0,0 +1,31 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Cutoff frequency of DCBlock (in Hz), low enough to keep the lowest audible frequencies.
+const dcBlockCutoff = 10.0
+
+// DCBlock removes the DC offset of the source wave (with a one-pole high-pass filter at 10Hz).
+// Asymmetric waveshapes and some samples carry an offset that wastes headroom and thumps on start/stop.
+//
+// The filter keeps the state of the previous evaluation (see Wave).
+// Going back in time (for example: when seeking) resets the filter.
+func DCBlock(src Wave) Wave {
+	var lastIn, lastOut float64
+	last := time.Duration(-1)
+	return func(x time.Duration) float64 {
+		in := src(x)
+		switch elapsed := x - last; {
+		case last < 0 || elapsed < 0:
+			lastOut = 0
+		case elapsed > 0:
+			r := math.Exp(-2 * math.Pi * dcBlockCutoff * elapsed.Seconds())
+			lastOut = in - lastIn + r*lastOut
+		}
+		lastIn, last = in, x
+		return lastOut
+	}
+}
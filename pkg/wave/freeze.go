@@ -0,0 +1,15 @@
+package wave
+
+import "time"
+
+// Freeze renders the first d of the source wave once (at the given sample rate) and returns a clip
+// playing the rendered frames, so an expensive wave used inside Loop isn't recomputed every repetition.
+// The source is rendered when Freeze is called.
+func Freeze(src Wave, sampleRate int, d time.Duration) Clip {
+	count := int(int64(d) * int64(sampleRate) / int64(time.Second))
+	frames := make([]float64, count)
+	for i := range frames {
+		frames[i] = src(time.Duration(int64(i) * int64(time.Second) / int64(sampleRate)))
+	}
+	return pcmFramesToClip(sampleRate, frames)
+}
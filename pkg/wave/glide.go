@@ -0,0 +1,38 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Glide slews a stepped wave (for example: the pitch of a pattern) toward each new value during d (portamento).
+// Positive values (like frequencies) glide exponentially, so the pitch moves evenly between notes.
+//
+// The wave keeps the current glide between evaluations (see Wave).
+// Going back in time (for example: when seeking) jumps directly to the current value.
+func Glide(src Wave, d time.Duration) Wave {
+	var from, to float64
+	changedAt, last := time.Duration(0), time.Duration(-1)
+	at := func(x time.Duration) float64 {
+		elapsed := x - changedAt
+		if elapsed >= d || d <= 0 {
+			return to
+		}
+		t := float64(elapsed) / float64(d)
+		if from > 0 && to > 0 {
+			return from * math.Pow(to/from, t)
+		}
+		return from + (to-from)*t
+	}
+
+	return func(x time.Duration) float64 {
+		target := src(x)
+		if last < 0 || x < last {
+			from, to, changedAt = target, target, x
+		} else if target != to {
+			from, to, changedAt = at(x), target, x
+		}
+		last = x
+		return at(x)
+	}
+}
@@ -0,0 +1,35 @@
+package wave
+
+import "time"
+
+// Hold samples the source wave at regular intervals and holds the sampled value until the next interval
+// (sample-and-hold), for example to step a Lerp into discrete values.
+// The interval wave provides the duration between samples in seconds,
+// the source wave is followed as is while the interval isn't positive.
+//
+// The wave keeps the time of the next sample between evaluations (see Wave).
+// Going back in time (for example: when seeking) restarts the intervals at the new position.
+func Hold(src, interval Wave) Wave {
+	held := 0.0
+	last, next := time.Duration(-1), time.Duration(0)
+	return func(x time.Duration) float64 {
+		if last < 0 || x < last {
+			next = x
+		}
+		last = x
+		for next <= x {
+			step := time.Duration(interval(next) * float64(time.Second))
+			if step <= 0 {
+				next = x
+				return src(x)
+			}
+			held = src(next)
+			next += step
+			if next <= x && x-next > 1000*step {
+				// Far jump ahead (for example: when seeking), don't go through every interval.
+				next = x
+			}
+		}
+		return held
+	}
+}
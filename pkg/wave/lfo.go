@@ -0,0 +1,52 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Shape is the waveform of a cycle: it maps a position in the cycle (from 0 to 1) to a value (from -1 to 1).
+type Shape func(phase float64) float64
+
+// Sine starts at 0 and rises first.
+func Sine(phase float64) float64 {
+	if UseLookupTables {
+		return sineTable.at(phase)
+	}
+	return math.Sin(2 * math.Pi * phase)
+}
+
+// Triangle starts at 0 and rises first, like Sine.
+func Triangle(phase float64) float64 {
+	switch {
+	case phase < 0.25:
+		return 4 * phase
+	case phase < 0.75:
+		return 2 - 4*phase
+	default:
+		return 4*phase - 4
+	}
+}
+
+// Sawtooth rises from -1 to 1 during the cycle.
+func Sawtooth(phase float64) float64 { return 2*phase - 1 }
+
+// Square is 1 during the first half of the cycle and -1 during the second half.
+func Square(phase float64) float64 {
+	if phase < 0.5 {
+		return 1
+	}
+	return -1
+}
+
+// LFO produces a modulation signal: offset + depth * shape, cycling rate times per second.
+// The phase shifts the start of the cycle (from 0 to 1, 0.25 is a quarter of a cycle).
+//
+// For example: LFO(Sine, Const(2), 1, 0, 0) goes from -1 to 1 (bipolar)
+// and LFO(Sine, Const(2), 0.5, 0.5, 0) goes from 0 to 1 (unipolar).
+func LFO(shape Shape, rate Wave, depth, offset, phase float64) Wave {
+	return func(x time.Duration) float64 {
+		pos := x.Seconds()*rate(x) + phase
+		return offset + depth*shape(pos-math.Floor(pos))
+	}
+}
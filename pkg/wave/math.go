@@ -0,0 +1,57 @@
+package wave
+
+import "time"
+
+// Offset adds the values of another wave to the source wave (without averaging, unlike Combine).
+// For example: Offset(Amplitude(lfo, Const(0.5)), Const(0.5)) turns a -1..1 oscillator into a 0..1 modulation.
+func Offset(src, by Wave) Wave {
+	return func(x time.Duration) float64 { return src(x) + by(x) }
+}
+
+// Multiply multiplies the values of several waves (Amplitude is Multiply with two waves).
+func Multiply(waves ...Wave) Wave {
+	return func(x time.Duration) float64 {
+		out := 1.0
+		for _, w := range waves {
+			out *= w(x)
+		}
+		return out
+	}
+}
+
+// Subtract subtracts the values of a wave from the source wave.
+func Subtract(src, by Wave) Wave {
+	return func(x time.Duration) float64 { return src(x) - by(x) }
+}
+
+// Min produces the lowest value of several waves (or 0 when no waves are provided).
+func Min(waves ...Wave) Wave {
+	if len(waves) == 0 {
+		return Const(0)
+	}
+	return func(x time.Duration) float64 {
+		out := waves[0](x)
+		for _, w := range waves[1:] {
+			if v := w(x); v < out {
+				out = v
+			}
+		}
+		return out
+	}
+}
+
+// Max produces the highest value of several waves (or 0 when no waves are provided).
+func Max(waves ...Wave) Wave {
+	if len(waves) == 0 {
+		return Const(0)
+	}
+	return func(x time.Duration) float64 {
+		out := waves[0](x)
+		for _, w := range waves[1:] {
+			if v := w(x); v > out {
+				out = v
+			}
+		}
+		return out
+	}
+}
@@ -0,0 +1,31 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Oscillate produces a periodic wave with the given shape and frequency (in Hz).
+// The phase shifts the start of the cycle (from 0 to 1, 0.25 is a quarter of a cycle),
+// for example to layer detuned oscillators without having all of them start at the same value.
+func Oscillate(shape Shape, frequency Wave, phase float64) Wave {
+	return func(x time.Duration) float64 {
+		pos := x.Seconds()*frequency(x) + phase
+		return shape(pos - math.Floor(pos))
+	}
+}
+
+// OscillateSync is like Oscillate, but its cycle restarts each time a master oscillator
+// (running at the master frequency) starts a new cycle (hard sync), as used for sync-lead sounds.
+// The oscillator runs freely while the master frequency isn't positive.
+func OscillateSync(shape Shape, frequency, masterFrequency Wave, phase float64) Wave {
+	return func(x time.Duration) float64 {
+		elapsed := x.Seconds()
+		if mf := masterFrequency(x); mf > 0 {
+			// Time elapsed since the start of the current master cycle
+			elapsed -= math.Floor(elapsed*mf) / mf
+		}
+		pos := elapsed*frequency(x) + phase
+		return shape(pos - math.Floor(pos))
+	}
+}
@@ -0,0 +1,23 @@
+package wave
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOscillateSinePhase(t *testing.T) {
+	defer func(v bool) { UseLookupTables = v }(UseLookupTables)
+	for _, tables := range []bool{false, true} {
+		UseLookupTables = tables
+		for _, phase := range []float64{0, 0.25, 0.5, 1.75, -0.25} {
+			got := OscillateSinePhase(Const(440), phase)
+			want := func(x time.Duration) float64 { return math.Sin(2 * math.Pi * (440*x.Seconds() + phase)) }
+			for x := time.Duration(0); x < 10*time.Millisecond; x += time.Second / 44100 {
+				if math.Abs(got(x)-want(x)) > 1e-6 {
+					t.Fatalf("lookup tables: %v, phase %v: sine at %s = %v, want %v", tables, phase, x, got(x), want(x))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package wave
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Param is a value that can be changed from any goroutine (for example: by a MIDI, OSC or keyboard handler)
+// while the waves using it are being played.
+//
+// Its waves move smoothly (one-pole low-pass) toward the new value,
+// to avoid audible steps (zipper noise) when it changes.
+type Param struct {
+	value     uint64 // bits of the float64 value, accessed atomically (first field, to be 64-bit aligned)
+	smoothing time.Duration
+}
+
+// NewParam returns a parameter with an initial value.
+// Smoothing is the time it takes for its waves to (almost) reach a new value (0 to jump immediately).
+func NewParam(initial float64, smoothing time.Duration) *Param {
+	return &Param{value: math.Float64bits(initial), smoothing: smoothing}
+}
+
+// Set changes the value of the parameter, it is safe to call from any goroutine.
+func (p *Param) Set(value float64) { atomic.StoreUint64(&p.value, math.Float64bits(value)) }
+
+// Get returns the latest value of the parameter (without smoothing).
+func (p *Param) Get() float64 { return math.Float64frombits(atomic.LoadUint64(&p.value)) }
+
+// Wave returns a wave producing the smoothed value of the parameter,
+// starting from its current value.
+// Smoothing is computed from the positions at which the wave is evaluated,
+// so the wave is stateful (see Wave): call Wave again for each use.
+func (p *Param) Wave() Wave {
+	current, last := p.Get(), time.Duration(0)
+	return func(x time.Duration) float64 {
+		target := p.Get()
+		if p.smoothing <= 0 {
+			current = target
+		} else if elapsed := x - last; elapsed > 0 {
+			// Reach ~99% of the target after the smoothing time
+			current += (target - current) * (1 - math.Exp(-4.6*float64(elapsed)/float64(p.smoothing)))
+		}
+		last = x
+		return current
+	}
+}
+
+// ParamSet holds named parameters, for example the parameters controlled by the knobs of a MIDI controller
+// or the faders of an OSC control surface.
+type ParamSet struct {
+	params map[string]*Param
+}
+
+func NewParamSet() *ParamSet { return &ParamSet{params: map[string]*Param{}} }
+
+// Add creates the named parameter, unless it already exists.
+// Smoothing defaults to 20ms (see NewParam).
+// Parameters should be added before their values are set from other goroutines.
+func (s *ParamSet) Add(name string, initial float64, smoothing time.Duration) {
+	if _, ok := s.params[name]; ok {
+		return
+	}
+	if smoothing <= 0 {
+		smoothing = 20 * time.Millisecond
+	}
+	s.params[name] = NewParam(initial, smoothing)
+}
+
+// Set changes the value of the named parameter (if it exists), it is safe to call from any goroutine.
+func (s *ParamSet) Set(name string, value float64) {
+	if p, ok := s.params[name]; ok {
+		p.Set(value)
+	}
+}
+
+// Wave returns a wave producing the smoothed value of the named parameter (or 0 if it doesn't exist).
+// Like the waves of Param, it is stateful.
+func (s *ParamSet) Wave(name string) Wave {
+	p, ok := s.params[name]
+	if !ok {
+		return Const(0)
+	}
+	return p.Wave()
+}
@@ -0,0 +1,32 @@
+package wave
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParamSet(t *testing.T) {
+	s := NewParamSet()
+	s.Add("cutoff", 100, time.Nanosecond)
+	s.Add("cutoff", 200, 0) // already added
+	s.Add("volume", 0.5, 0)
+	s.Set("missing", 1)
+
+	cutoff, volume, missing := s.Wave("cutoff"), s.Wave("volume"), s.Wave("missing")
+	s.Set("cutoff", 1000)
+	s.Set("volume", 1)
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "cutoff, smoothed in 1ns", got: cutoff(time.Millisecond), want: 1000},
+		{name: "volume, smoothed in 20ms by default", got: volume(time.Millisecond), want: 0.5 + 0.5*0.2},
+		{name: "missing parameter", got: missing(time.Millisecond), want: 0},
+	}
+	for _, tt := range tests {
+		if d := tt.got - tt.want; d > 0.01 || d < -0.01 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
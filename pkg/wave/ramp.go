@@ -0,0 +1,48 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// ExpRamp goes from start to end during d, following an exponential curve:
+// equal durations multiply the value by the same ratio, which sounds even for frequency sweeps.
+// The value stays at start before 0 and at end after d.
+// Start and end should be both positive (or both negative), otherwise the ramp is linear.
+func ExpRamp(start, end float64, d time.Duration) Wave {
+	if start*end <= 0 {
+		return ramp(Lerp(start, end, d), start, end, d)
+	}
+	ratio := end / start
+	return ramp(func(x time.Duration) float64 {
+		return start * math.Pow(ratio, float64(x)/float64(d))
+	}, start, end, d)
+}
+
+// DecibelRamp goes from a gain of startDB to a gain of endDB during d, linearly in decibels
+// (for example: DecibelRamp(-60, 0, time.Second) for a fade in that sounds even).
+// The produced values are linear gains, to be used with Amplitude.
+func DecibelRamp(startDB, endDB float64, d time.Duration) Wave {
+	return ramp(func(x time.Duration) float64 {
+		return DecibelsToGain(startDB + (endDB-startDB)*float64(x)/float64(d))
+	}, DecibelsToGain(startDB), DecibelsToGain(endDB), d)
+}
+
+// DecibelsToGain converts decibels to a linear gain (0dB is 1, -6dB is about 0.5).
+func DecibelsToGain(db float64) float64 { return math.Pow(10, db/20) }
+
+// GainToDecibels converts a linear gain to decibels (a gain of 0 is -Inf).
+func GainToDecibels(gain float64) float64 { return 20 * math.Log10(math.Abs(gain)) }
+
+// ramp holds the start value before 0 and the end value after d.
+func ramp(w Wave, start, end float64, d time.Duration) Wave {
+	return func(x time.Duration) float64 {
+		if x <= 0 {
+			return start
+		}
+		if x >= d {
+			return end
+		}
+		return w(x)
+	}
+}
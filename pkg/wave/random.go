@@ -0,0 +1,96 @@
+package wave
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Global random source: random waves created without a chosen seed take theirs from it (see NextSeed).
+var random = struct {
+	sync.Mutex
+	seed  int64
+	count int64 // number of seeds drawn
+}{}
+
+// SetSeed seeds the global random source and restarts its sequence of seeds (the default seed is 0),
+// for example to render variations of a generative composition, or to reproduce one of them.
+// It should be called before creating the waves of a composition.
+func SetSeed(seed int64) {
+	random.Lock()
+	defer random.Unlock()
+	random.seed, random.count = seed, 0
+}
+
+// NextSeed returns the next seed of the global random source, to create random waves
+// (for example: RandomHold(rate, 0, 1, NextSeed())).
+// Seeds only depend on the global seed and on the order they are drawn in,
+// so compositions creating their waves in the same order render identically across runs and machines.
+func NextSeed() int64 {
+	random.Lock()
+	defer random.Unlock()
+	random.count++
+	return int64(splitmix64(random.seed, random.count))
+}
+
+// RandomHold produces random values between min and max, changing rate times per second
+// (random sample-and-hold), for example for randomized filter wobbles.
+// The value is frozen while the rate isn't positive.
+//
+// Values are drawn from a generator initialized with the seed, so renders played from the beginning are reproducible.
+// Like Hold, the wave is stateful.
+func RandomHold(rate Wave, min, max float64, seed int64) Wave {
+	rng := rand.New(rand.NewSource(seed))
+	current := min + rng.Float64()*(max-min)
+	random := func(x time.Duration) float64 {
+		if rate(x) > 0 {
+			current = min + rng.Float64()*(max-min)
+		}
+		return current
+	}
+	interval := func(x time.Duration) float64 {
+		if r := rate(x); r > 0 {
+			return 1 / r
+		}
+		return 0
+	}
+	return Hold(random, interval)
+}
+
+// SmoothRandom produces a smoothly varying random wave (interpolated noise) between -amplitude and amplitude,
+// taking rate new random directions per second, for example for an organic drift of pitch or cutoff.
+//
+// Values only depend on the seed and on the position, so the wave can be evaluated at any time
+// and renders are reproducible.
+func SmoothRandom(rate, amplitude Wave, seed int64) Wave {
+	return func(x time.Duration) float64 {
+		pos := x.Seconds() * rate(x)
+		i := math.Floor(pos)
+		t := pos - i
+		t = t * t * t * (t*(t*6-15) + 10) // smooth interpolation (as in Perlin noise)
+		a, b := latticeNoise(seed, int64(i)), latticeNoise(seed, int64(i)+1)
+		return amplitude(x) * (a + (b-a)*t)
+	}
+}
+
+// Noise produces white noise between -amplitude and amplitude, for example for percussions or breath.
+//
+// Values only depend on the seed and on the position, so the wave can be evaluated at any time
+// and renders are reproducible.
+func Noise(amplitude Wave, seed int64) Wave {
+	return func(x time.Duration) float64 { return amplitude(x) * latticeNoise(seed, int64(x)) }
+}
+
+// latticeNoise returns a random value in [-1, 1) for the seed and the index.
+func latticeNoise(seed, index int64) float64 {
+	return float64(splitmix64(seed, index)>>11)/float64(1<<52) - 1
+}
+
+// splitmix64 hashes the seed and the index into random bits.
+func splitmix64(seed, index int64) uint64 {
+	z := uint64(seed) + uint64(index)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
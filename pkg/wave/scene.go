@@ -0,0 +1,84 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Scene is a snapshot of named parameter values (for example: "cutoff", "drums.gain").
+type Scene map[string]float64
+
+// SceneMorph interpolates between two scenes.
+//
+// The position wave acts as a single automation lane for all parameters:
+// 0 produces the values of the first scene, 1 produces the values of the second one.
+// Values outside of [0, 1] are clamped.
+type SceneMorph struct {
+	from, to Scene
+	position Wave
+}
+
+// NewSceneMorph creates a morph between two scenes driven by the position wave.
+func NewSceneMorph(from, to Scene, position Wave) *SceneMorph {
+	return &SceneMorph{from: from, to: to, position: position}
+}
+
+// NewTimedSceneMorph creates a morph that stays on the first scene until start,
+// then moves smoothly to the second scene over the duration d.
+func NewTimedSceneMorph(from, to Scene, start, d time.Duration) *SceneMorph {
+	return NewSceneMorph(from, to, morphPosition(start, d))
+}
+
+// Param returns a wave producing the morphed value of the named parameter.
+//
+// When the parameter is missing from one of the scenes, the value of the other scene is used.
+// When it is missing from both scenes, the wave always produces 0.
+func (m *SceneMorph) Param(name string) Wave {
+	from, okFrom := m.from[name]
+	to, okTo := m.to[name]
+	switch {
+	case !okFrom && !okTo:
+		return Const(0)
+	case !okFrom:
+		from = to
+	case !okTo:
+		to = from
+	}
+
+	return func(x time.Duration) float64 {
+		pos := m.position(x)
+		if pos < 0 {
+			pos = 0
+		} else if pos > 1 {
+			pos = 1
+		}
+		return from + (to-from)*pos
+	}
+}
+
+// Scene returns a snapshot of all morphed parameter values at the given time.
+func (m *SceneMorph) Scene(x time.Duration) Scene {
+	out := Scene{}
+	for name := range m.from {
+		out[name] = m.Param(name)(x)
+	}
+	for name := range m.to {
+		out[name] = m.Param(name)(x)
+	}
+	return out
+}
+
+// morphPosition returns a smooth (cosine-shaped) ramp from 0 to 1,
+// starting at start and lasting d.
+func morphPosition(start, d time.Duration) Wave {
+	return func(x time.Duration) float64 {
+		if x <= start {
+			return 0
+		}
+		if d <= 0 || x >= start+d {
+			return 1
+		}
+		progress := float64(x-start) / float64(d)
+		return (1 - math.Cos(math.Pi*progress)) / 2
+	}
+}
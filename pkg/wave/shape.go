@@ -0,0 +1,45 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Clamp limits the values of the source wave to the range [min, max].
+// For example: Clamp(src, -1, 1) guards against values that would distort when encoded.
+func Clamp(src Wave, min, max float64) Wave {
+	return func(x time.Duration) float64 {
+		v := src(x)
+		if v < min {
+			return min
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+}
+
+// SoftClip smoothly limits the values of the source wave to the range (-1, 1) (using a tanh curve).
+// Unlike Clamp, values are compressed progressively as they get close to the limits,
+// which sounds warmer than hard clipping.
+func SoftClip(src Wave) Wave {
+	return func(x time.Duration) float64 { return math.Tanh(src(x)) }
+}
+
+// Invert flips the polarity of the source wave.
+func Invert(src Wave) Wave {
+	return func(x time.Duration) float64 { return -src(x) }
+}
+
+// Abs produces the absolute value of the source wave (full-wave rectification):
+// negative half-cycles are flipped, which doubles the frequency of an oscillator (octave up).
+func Abs(src Wave) Wave {
+	return func(x time.Duration) float64 { return math.Abs(src(x)) }
+}
+
+// Rectify keeps the positive values of the source wave and silences the negative ones (half-wave rectification).
+// Followed by smoothing, it can be used to follow the envelope of a signal.
+func Rectify(src Wave) Wave {
+	return func(x time.Duration) float64 { return math.Max(src(x), 0) }
+}
@@ -0,0 +1,123 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// StereoWave is a pair of waves played on the left and right channels (see audio.StereoRoutes).
+type StereoWave struct {
+	Left, Right Wave
+}
+
+// Centered plays the same wave on both channels.
+func Centered(w Wave) StereoWave { return StereoWave{Left: w, Right: w} }
+
+// Mono returns the average of both channels (what a mono speaker plays).
+func (s StereoWave) Mono() Wave {
+	return func(x time.Duration) float64 { return (s.Left(x) + s.Right(x)) / 2 }
+}
+
+// MidSide encodes the channels as their sum (mid signal, the center of the image, same as Mono)
+// and their difference (side signal, the width of the image), so they can be processed separately (see ProcessMidSide).
+func (s StereoWave) MidSide() (mid, side Wave) {
+	mid = s.Mono()
+	side = func(x time.Duration) float64 { return (s.Left(x) - s.Right(x)) / 2 }
+	return mid, side
+}
+
+// FromMidSide decodes mid and side signals (see StereoWave.MidSide) back to left and right channels.
+func FromMidSide(mid, side Wave) StereoWave {
+	return StereoWave{
+		Left:  func(x time.Duration) float64 { return mid(x) + side(x) },
+		Right: func(x time.Duration) float64 { return mid(x) - side(x) },
+	}
+}
+
+// ProcessMidSide applies effects separately to the mid and side signals of a stereo wave (nil effects don't change the signal),
+// for example to compress the center of a mix, or to cut the low frequencies of its sides (see dsp.Effect).
+// Stateful effects should cache their value for the current time, since both channels evaluate them.
+func ProcessMidSide(src StereoWave, midEffect, sideEffect func(Wave) Wave) StereoWave {
+	mid, side := src.MidSide()
+	if midEffect != nil {
+		mid = midEffect(mid)
+	}
+	if sideEffect != nil {
+		side = sideEffect(side)
+	}
+	return FromMidSide(mid, side)
+}
+
+// Longest micro-delay of Widen and Haas, longer delays are heard as echoes.
+const maxStereoDelay = 30 * time.Millisecond
+
+// WidenerConfig describes how Widen widens a stereo image.
+type WidenerConfig struct {
+	// Width scales the difference between the channels (side signal): 0 is mono, 1 doesn't change the image
+	// (default: 1.5). It is clamped between 0 and 2, wider images sound hollow and out of phase.
+	Width Wave
+	// Delay (up to 30ms, for example 12ms) adds a delayed copy of the sum of the channels (mid signal) to their difference,
+	// so even mono sources get a stereo image. 0 doesn't add it.
+	Delay time.Duration
+	// Spread is the level of the delayed copy (default: 0.3).
+	Spread float64
+}
+
+func (c *WidenerConfig) setDefaults() {
+	if c.Width == nil {
+		c.Width = Const(1.5)
+	}
+	if c.Delay < 0 {
+		c.Delay = 0
+	} else if c.Delay > maxStereoDelay {
+		c.Delay = maxStereoDelay
+	}
+	if c.Spread <= 0 {
+		c.Spread = 0.3
+	}
+}
+
+// Widen makes the stereo image of the source wider (or narrower) by processing the difference between its channels,
+// for example to make pads surround the listener.
+//
+// The image stays mono-compatible: the sum of the channels (heard on mono speakers, like phones or club systems)
+// isn't changed, so the source doesn't lose body or get comb-filtered when summed to mono.
+// With a delay, the source is also evaluated in the past, so it shouldn't hold state.
+func Widen(src StereoWave, config WidenerConfig) StereoWave {
+	config.setDefaults()
+	mid, side := src.MidSide()
+	return FromMidSide(mid, func(x time.Duration) float64 {
+		s := side(x) * math.Max(0, math.Min(2, config.Width(x)))
+		if config.Delay > 0 && x >= config.Delay {
+			s += mid(x-config.Delay) * config.Spread
+		}
+		return s
+	})
+}
+
+// Haas places a mono source in the stereo image by delaying one of its channels (Haas effect):
+// the source is heard on the side of the channel playing first, with the same level on both channels.
+// A positive delay (up to 30ms, for example 10ms) delays the right channel (the source is heard on the left),
+// a negative delay delays the left channel.
+//
+// Unlike panning, it doesn't make the source quieter on one side, but it comb-filters the source when summed to mono.
+// Since the source is also evaluated in the past, it shouldn't hold state.
+func Haas(src Wave, delay time.Duration) StereoWave {
+	if delay > maxStereoDelay {
+		delay = maxStereoDelay
+	} else if delay < -maxStereoDelay {
+		delay = -maxStereoDelay
+	}
+	delayed := func(d time.Duration) Wave {
+		return func(x time.Duration) float64 {
+			if x < d {
+				return 0
+			}
+			return src(x - d)
+		}
+	}
+	if delay < 0 {
+		return StereoWave{Left: delayed(-delay), Right: src}
+	}
+	return StereoWave{Left: src, Right: delayed(delay)}
+}
@@ -0,0 +1,41 @@
+package wave
+
+import "math"
+
+// UseLookupTables makes sine oscillators (OscillateSine and the Sine shape) read a precomputed table
+// (with linear interpolation) instead of calling math.Sin, which is much faster when rendering
+// hundreds of partials (the error stays below 1e-6).
+// It should be set before rendering starts.
+var UseLookupTables = false
+
+// Size of lookup tables (one cycle).
+const tableSize = 4096
+
+var sineTable = newTable(func(phase float64) float64 { return math.Sin(2 * math.Pi * phase) })
+
+// TableShape precomputes one cycle of a shape into a table, the returned shape
+// interpolates linearly between table values (for example to speed up a costly shape).
+func TableShape(shape Shape) Shape { return newTable(shape).at }
+
+type table []float64
+
+func newTable(shape Shape) table {
+	t := make(table, tableSize+1) // the extra value (a copy of the first one) avoids wrapping when interpolating
+	for i := range t[:tableSize] {
+		t[i] = shape(float64(i) / tableSize)
+	}
+	t[tableSize] = t[0]
+	return t
+}
+
+// at returns the interpolated value at a phase (in cycles, wrapped to [0, 1)).
+func (t table) at(phase float64) float64 {
+	size := len(t) - 1
+	pos := (phase - math.Floor(phase)) * float64(size)
+	i := int(pos)
+	if i >= size { // rounding of phases very close to 1
+		i = size - 1
+	}
+	frac := pos - float64(i)
+	return t[i] + (t[i+1]-t[i])*frac
+}
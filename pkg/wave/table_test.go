@@ -0,0 +1,67 @@
+package wave
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSineTableError(t *testing.T) {
+	const maxError = 1e-6
+	tests := []struct {
+		name  string
+		shape Shape
+		exact func(phase float64) float64
+	}{
+		{name: "sine table", shape: sineTable.at, exact: func(phase float64) float64 { return math.Sin(2 * math.Pi * phase) }},
+		{name: "table shape", shape: TableShape(Triangle), exact: Triangle},
+	}
+	for _, tt := range tests {
+		worst := 0.0
+		for i := -10_000; i <= 1_000_000; i++ {
+			phase := float64(i) / 999_983 // not aligned with the table
+			if e := math.Abs(tt.shape(phase) - tt.exact(phase-math.Floor(phase))); e > worst {
+				worst = e
+			}
+		}
+		if worst > maxError {
+			t.Errorf("%s: max error = %g, want below %g", tt.name, worst, maxError)
+		}
+	}
+}
+
+func TestOscillateSineLookupTables(t *testing.T) {
+	defer func(v bool) { UseLookupTables = v }(UseLookupTables)
+	exact := OscillateSine(Const(440))
+	UseLookupTables = true
+	for x := time.Duration(0); x < time.Second; x += time.Second / 44100 {
+		got := OscillateSine(Const(440))(x)
+		UseLookupTables = false
+		want := exact(x)
+		UseLookupTables = true
+		if math.Abs(got-want) > 1e-6 {
+			t.Fatalf("at %s: %v, want %v", x, got, want)
+		}
+	}
+}
+
+func BenchmarkOscillateSine(b *testing.B) {
+	defer func(v bool) { UseLookupTables = v }(UseLookupTables)
+	for _, tables := range []bool{false, true} {
+		name := "math.Sin"
+		if tables {
+			name = "table"
+		}
+		b.Run(name, func(b *testing.B) {
+			UseLookupTables = tables
+			w := OscillateSine(Const(440))
+			step := time.Second / 44100
+			sum := 0.0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sum += w(time.Duration(i%44100) * step)
+			}
+			_ = sum
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package wave
+
+import (
+	"math"
+	"time"
+)
+
+// Tempo is the pace of a song: beats per minute and beats per bar (time signature).
+type Tempo struct {
+	BPM float64
+	// BeatsPerBar defaults to 4.
+	BeatsPerBar int
+}
+
+func (t Tempo) beatsPerBar() int {
+	if t.BeatsPerBar <= 0 {
+		return 4
+	}
+	return t.BeatsPerBar
+}
+
+// Beat returns the duration of a beat.
+func (t Tempo) Beat() time.Duration { return t.Beats(1) }
+
+// Bar returns the duration of a bar.
+func (t Tempo) Bar() time.Duration { return t.Beats(float64(t.beatsPerBar())) }
+
+// Beats returns the duration of a number of beats (for example: 0.25 for a sixteenth note in 4/4).
+func (t Tempo) Beats(n float64) time.Duration {
+	return time.Duration(n * float64(time.Minute) / t.BPM)
+}
+
+// BeatAt returns the position of x in beats (for example: 4.5 is half of the first beat of the second bar in 4/4).
+func (t Tempo) BeatAt(x time.Duration) float64 { return x.Minutes() * t.BPM }
+
+// MetronomeConfig describes the sounds of a metronome.
+type MetronomeConfig struct {
+	// Accent is played on the first beat of bars, and Beat on the other beats,
+	// from their beginning (until the next beat).
+	// The defaults are short blips at 1760Hz and 880Hz.
+	Accent, Beat Wave
+}
+
+func (c *MetronomeConfig) setDefaults() {
+	if c.Accent == nil {
+		c.Accent = blip(1760)
+	}
+	if c.Beat == nil {
+		c.Beat = blip(880)
+	}
+}
+
+// Length of the default sounds of a metronome.
+const blipDuration = 40 * time.Millisecond
+
+// blip is a sine decaying quickly, like the click of a metronome.
+func blip(frequency float64) Wave {
+	return func(x time.Duration) float64 {
+		if x < 0 || x >= blipDuration {
+			return 0
+		}
+		return math.Sin(2*math.Pi*frequency*x.Seconds()) * math.Exp(-x.Seconds()/0.008) * 0.5
+	}
+}
+
+// Metronome plays a click on each beat of the tempo (click track), with an accent on the first beat of bars,
+// for example to record live takes in time (see the Click option of audio players).
+func Metronome(tempo Tempo, config MetronomeConfig) Wave {
+	config.setDefaults()
+	beat := tempo.Beat()
+	if beat <= 0 {
+		return Const(0)
+	}
+	return func(x time.Duration) float64 {
+		if x < 0 {
+			return 0
+		}
+		i := int64(x / beat)
+		sound := config.Beat
+		if i%int64(tempo.beatsPerBar()) == 0 {
+			sound = config.Accent
+		}
+		return sound(x - time.Duration(i)*beat)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/go-audio/wav"
@@ -19,6 +20,11 @@ import (
 // over time (frequency modulation wave).
 // You can then produce a sine oscillation (= another wave) using the previously
 // created frequency modulation wave.
+//
+// Some waves keep state between evaluations (for example: filters, smoothed parameters or sequencers).
+// Their state follows the positions at which they are evaluated, in increasing order,
+// so a stateful wave should be played by a single render loop
+// (create a new wave for each use instead of sharing one between voices or goroutines).
 type Wave func(time.Duration) float64
 
 // A utility wave that always produces the same value.
@@ -26,9 +32,15 @@ func Const(value float64) Wave { return func(d time.Duration) float64 { return v
 
 // Sinusoidal oscillation wave.
 // Can be used to produce notes.
-func OscillateSine(frequency Wave) Wave {
+func OscillateSine(frequency Wave) Wave { return OscillateSinePhase(frequency, 0) }
+
+// OscillateSinePhase is like OscillateSine, but the phase shifts the start of the cycle (see Oscillate).
+func OscillateSinePhase(frequency Wave, phase float64) Wave {
 	return func(x time.Duration) float64 {
-		return math.Sin(math.Pi * 2 * x.Seconds() * frequency(x))
+		if UseLookupTables {
+			return sineTable.at(x.Seconds()*frequency(x) + phase)
+		}
+		return math.Sin(math.Pi * 2 * (x.Seconds()*frequency(x) + phase))
 	}
 }
 
@@ -42,6 +54,23 @@ func Loop(src Wave, period time.Duration) Wave {
 	return func(x time.Duration) float64 { return src(x % period) }
 }
 
+// LoopCrossfade is like Loop, but removes the click at the loop point:
+// at the beginning of each repetition, the source wave fades in while its continuation
+// after the period (for example: the tail of a sample) fades out during the fade duration.
+func LoopCrossfade(src Wave, period, fade time.Duration) Wave {
+	if fade > period {
+		fade = period
+	}
+	return func(x time.Duration) float64 {
+		t := x % period
+		if x < period || t >= fade {
+			return src(t)
+		}
+		g := float64(t) / float64(fade)
+		return src(t)*g + src(t+period)*(1-g)
+	}
+}
+
 // Returns the wave value until the duration has elapsed.
 // Then it returns the provided value.
 func Limit(before, after Wave, d time.Duration) Wave {
@@ -84,41 +113,68 @@ func Speed(src Wave, by float64) Wave {
 	return func(x time.Duration) float64 { return src(time.Duration(float64(x) * by)) }
 }
 
-func pcmFramesToWave(sampleRate int, frames []float64) Wave {
-	timePerFrame := time.Second / time.Duration(sampleRate)
-	audioDuration := time.Duration(len(frames)) * timePerFrame
-
-	return func(x time.Duration) float64 {
-		if x > audioDuration {
-			return 0
-		}
-		return frames[x/timePerFrame]
+func pcmFramesToClip(sampleRate int, frames []float64) Clip {
+	d := time.Duration(int64(len(frames)) * int64(time.Second) / int64(sampleRate))
+	return Clip{
+		Wave: func(x time.Duration) float64 {
+			if x < 0 || x >= d {
+				return 0
+			}
+			// Index of the last frame starting at or before x
+			// (frame i starts at i*time.Second/sampleRate, rounded down to the nanosecond).
+			i := int((int64(x+1)*int64(sampleRate) - 1) / int64(time.Second))
+			if i >= len(frames) {
+				return 0
+			}
+			return frames[i]
+		},
+		Duration: d,
 	}
 }
 
 // Creates a wave from a PCM audio file.
 func ImportPCM(filepath string, sampleRate int) (Wave, error) {
+	c, err := ImportPCMClip(filepath, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return c.Wave, nil
+}
+
+func MustImportPCM(filepath string, sampleRate int) Wave {
+	out, err := ImportPCM(filepath, sampleRate)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Creates a clip from a PCM audio file, its duration is the length of the audio.
+func ImportPCMClip(filepath string, sampleRate int) (Clip, error) {
+	if sampleRate <= 0 {
+		return Clip{}, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
 	f, err := os.Open(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("open file: %s: %w", filepath, err)
+		return Clip{}, fmt.Errorf("open file: %s: %w", filepath, err)
 	}
 	defer f.Close()
 
 	rawfile, err := ioutil.ReadAll(f)
 	if err != nil {
-		return nil, fmt.Errorf("read file: %s: %w", filepath, err)
+		return Clip{}, fmt.Errorf("read file: %s: %w", filepath, err)
 	}
 
 	frames := []float64{}
-	for i := 0; i < len(rawfile); i += 8 {
+	for i := 0; i+8 <= len(rawfile); i += 8 {
 		frames = append(frames, math.Float64frombits(binary.LittleEndian.Uint64(rawfile[i:i+8])))
 	}
 
-	return pcmFramesToWave(sampleRate, frames), nil
+	return pcmFramesToClip(sampleRate, frames), nil
 }
 
-func MustImportPCM(filepath string, sampleRate int) Wave {
-	out, err := ImportPCM(filepath, sampleRate)
+func MustImportPCMClip(filepath string, sampleRate int) Clip {
+	out, err := ImportPCMClip(filepath, sampleRate)
 	if err != nil {
 		panic(err)
 	}
@@ -126,62 +182,124 @@ func MustImportPCM(filepath string, sampleRate int) Wave {
 }
 
 func ImportWav(filepath string) (Wave, error) {
+	c, err := ImportWavClip(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return c.Wave, nil
+}
+
+func MustImportWav(filepath string) Wave {
+	out, err := ImportWav(filepath)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Creates a clip from a (mono) WAV file, its duration is the length of the audio.
+func ImportWavClip(filepath string) (Clip, error) {
 	f, err := os.Open(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("open file: %s: %w", filepath, err)
+		return Clip{}, fmt.Errorf("open file: %s: %w", filepath, err)
 	}
 	defer f.Close()
 
 	pcmBuffer, err := wav.NewDecoder(f).FullPCMBuffer()
 	if err != nil {
-		return nil, fmt.Errorf("decode wav to pcm: %w", err)
+		return Clip{}, fmt.Errorf("decode wav to pcm: %w", err)
 	}
 	numChannels := pcmBuffer.PCMFormat().NumChannels
 	if numChannels != 1 {
-		return nil, fmt.Errorf("num channels should be 1: %d", numChannels)
+		return Clip{}, fmt.Errorf("num channels should be 1: %d", numChannels)
 	}
 	frames := []float64{}
 	for _, srcframe := range pcmBuffer.AsFloatBuffer().Data {
 		frames = append(frames, srcframe/18_000.0)
 	}
-	return pcmFramesToWave(pcmBuffer.Format.SampleRate, frames), nil
+	return pcmFramesToClip(pcmBuffer.Format.SampleRate, frames), nil
 }
 
-func MustImportWav(filepath string) Wave {
-	out, err := ImportWav(filepath)
+// ImportWavFrames decodes a WAV file to frames between -1 and 1 (the channels of multi-channel files are averaged),
+// and returns its sample rate, for example to load the samples of an instrument at their own rate.
+func ImportWavFrames(filepath string) ([]float64, int, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open file: %s: %w", filepath, err)
+	}
+	defer f.Close()
+
+	pcmBuffer, err := wav.NewDecoder(f).FullPCMBuffer()
+	if err != nil {
+		return nil, 0, fmt.Errorf("decode wav to pcm: %w", err)
+	}
+	numChannels, bitDepth := pcmBuffer.PCMFormat().NumChannels, pcmBuffer.SourceBitDepth
+	if numChannels <= 0 || bitDepth <= 0 {
+		return nil, 0, fmt.Errorf("invalid format: %d channels of %d bits", numChannels, bitDepth)
+	}
+	scale := float64(numChannels) * float64(int64(1)<<(bitDepth-1))
+	frames := make([]float64, len(pcmBuffer.Data)/numChannels)
+	for i, v := range pcmBuffer.Data[:len(frames)*numChannels] {
+		frames[i/numChannels] += float64(v) / scale
+	}
+	return frames, pcmBuffer.Format.SampleRate, nil
+}
+
+func MustImportWavClip(filepath string) Clip {
+	out, err := ImportWavClip(filepath)
 	if err != nil {
 		panic(err)
 	}
 	return out
 }
 
-// type ChainedWave struct {
-// 	waves   []Wave
-// 	pattern []time.Duration
-// }
-
-// func NewChainedWave(waves []Wave, pattern ...time.Duration) (*ChainedWave, error) {
-// 	if len(waves) < 2 {
-// 		return nil, errors.New("missing waves")
-// 	}
-// 	if len(waves) == 0 {
-// 		return nil, errors.New("missing pattern")
-// 	}
-// 	return &ChainedWave{waves: waves, pattern: pattern}, nil
-// }
-
-// func (cw *ChainedWave) At(x time.Duration) float64 {
-// 	maxDuration := time.Duration(0)
-// 	x = time.Duration(math.Mod(float64(x), float64(maxDuration)))
-
-// 	countDuration := time.Duration(0.0)
-// 	for i, segment := range cw.pattern {
-// 		if !(x >= countDuration && x < countDuration+segment) {
-// 			countDuration += segment
-// 			continue
-// 		}
-// 		index := i
-// 		return cw.waves[index].At(x - countDuration)
-// 	}
-// 	panic("wave not found")
-// }
+// Segment is a part of a sequence: a wave played during a given duration.
+type Segment struct {
+	Wave     Wave
+	Duration time.Duration
+}
+
+// Sequence plays the segments back-to-back.
+// Each segment wave starts from its beginning (at 0) when the segment starts.
+// The sequence is silent once all segments have been played.
+func Sequence(segments ...Segment) Wave {
+	starts := make([]time.Duration, len(segments))
+	total := time.Duration(0)
+	for i, s := range segments {
+		starts[i] = total
+		total += s.Duration
+	}
+
+	return func(x time.Duration) float64 {
+		if x < 0 || x >= total {
+			return 0
+		}
+		i := sort.Search(len(starts), func(i int) bool { return starts[i] > x }) - 1
+		return segments[i].Wave(x - starts[i])
+	}
+}
+
+// LoopSequence plays the segments back-to-back and repeats the whole sequence forever.
+func LoopSequence(segments ...Segment) Wave {
+	total := SequenceDuration(segments...)
+	if total <= 0 {
+		return Const(0)
+	}
+	seq := Sequence(segments...)
+	return func(x time.Duration) float64 {
+		x %= total
+		if x < 0 {
+			x += total
+		}
+		return seq(x)
+	}
+}
+
+// SequenceDuration returns the total duration of the segments.
+func SequenceDuration(segments ...Segment) time.Duration {
+	total := time.Duration(0)
+	for _, s := range segments {
+		total += s.Duration
+	}
+	return total
+}
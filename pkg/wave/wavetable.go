@@ -0,0 +1,102 @@
+package wave
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// WavetableFrameSize is the usual size of the frames of wavetable banks (as exported by Serum and most wavetable synths).
+const WavetableFrameSize = 2048
+
+// Wavetable is a bank of single-cycle frames, played by morphing from a frame to the next one (see Oscillate).
+type Wavetable struct {
+	frames []table
+}
+
+// NewWavetable splits samples (concatenated single-cycle frames) into frames of frameSize samples
+// (default: WavetableFrameSize), the remaining samples (an incomplete frame) are ignored.
+func NewWavetable(samples []float64, frameSize int) (Wavetable, error) {
+	if frameSize <= 0 {
+		frameSize = WavetableFrameSize
+	}
+	if len(samples) < frameSize {
+		return Wavetable{}, fmt.Errorf("not enough samples for a frame of %d samples: %d", frameSize, len(samples))
+	}
+	wt := Wavetable{frames: make([]table, len(samples)/frameSize)}
+	for i := range wt.frames {
+		frame := samples[i*frameSize : (i+1)*frameSize]
+		t := make(table, frameSize+1) // the extra value (a copy of the first one) avoids wrapping when interpolating
+		copy(t, frame)
+		t[frameSize] = frame[0]
+		wt.frames[i] = t
+	}
+	return wt, nil
+}
+
+// ImportWavetable loads a wavetable bank from a WAV file of concatenated single-cycle frames of frameSize samples
+// (default: WavetableFrameSize). A file shorter than a frame is a single-cycle WAV, used as a bank of one frame.
+func ImportWavetable(filepath string, frameSize int) (Wavetable, error) {
+	samples, _, err := ImportWavFrames(filepath)
+	if err != nil {
+		return Wavetable{}, err
+	}
+	if frameSize <= 0 {
+		frameSize = WavetableFrameSize
+	}
+	if len(samples) > 0 && len(samples) < frameSize {
+		frameSize = len(samples)
+	}
+	wt, err := NewWavetable(samples, frameSize)
+	if err != nil {
+		return Wavetable{}, fmt.Errorf("split frames: %s: %w", filepath, err)
+	}
+	return wt, nil
+}
+
+func MustImportWavetable(filepath string, frameSize int) Wavetable {
+	out, err := ImportWavetable(filepath, frameSize)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Frames returns the number of frames of the wavetable.
+func (wt Wavetable) Frames() int { return len(wt.frames) }
+
+// Shape returns the shape at a position in the bank, from 0 (first frame) to 1 (last frame, values are clamped
+// and NaN is the first frame), positions in between crossfade linearly between adjacent frames.
+func (wt Wavetable) Shape(position float64) Shape {
+	return func(phase float64) float64 { return wt.at(position, phase) }
+}
+
+func (wt Wavetable) at(position, phase float64) float64 {
+	if len(wt.frames) == 0 {
+		return 0
+	}
+	if math.IsNaN(position) {
+		position = 0
+	}
+	pos := math.Max(0, math.Min(1, position)) * float64(len(wt.frames)-1)
+	i := int(pos)
+	if i >= len(wt.frames)-1 {
+		return wt.frames[len(wt.frames)-1].at(phase)
+	}
+	frac := pos - float64(i)
+	a := wt.frames[i].at(phase)
+	if frac == 0 {
+		return a
+	}
+	return a + (wt.frames[i+1].at(phase)-a)*frac
+}
+
+// Oscillate plays the wavetable at the given frequency (in Hz), morphing between its frames
+// as the position wave moves from 0 to 1 (for example: an LFO or an envelope sweeping the bank).
+// The phase shifts the start of the cycle, like for wave.Oscillate.
+func (wt Wavetable) Oscillate(frequency, position Wave, phase float64) Wave {
+	return func(x time.Duration) float64 {
+		pos := x.Seconds()*frequency(x) + phase
+		return wt.at(position(x), pos-math.Floor(pos))
+	}
+}
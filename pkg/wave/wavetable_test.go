@@ -0,0 +1,79 @@
+package wave
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewWavetable(t *testing.T) {
+	tests := []struct {
+		samples   int
+		frameSize int
+		frames    int
+		wantErr   bool
+	}{
+		{samples: 4 * WavetableFrameSize, frames: 4},
+		{samples: 4*WavetableFrameSize + 10, frames: 4}, // incomplete frame
+		{samples: 256, frameSize: 256, frames: 1},
+		{samples: 100, frameSize: 256, wantErr: true},
+		{samples: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		wt, err := NewWavetable(make([]float64, tt.samples), tt.frameSize)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d samples, frames of %d: error = %v, want error: %v", tt.samples, tt.frameSize, err, tt.wantErr)
+			continue
+		}
+		if wt.Frames() != tt.frames {
+			t.Errorf("%d samples, frames of %d: %d frames, want %d", tt.samples, tt.frameSize, wt.Frames(), tt.frames)
+		}
+	}
+}
+
+func TestWavetableShape(t *testing.T) {
+	// Three frames of 4 samples: a ramp up, silence and a constant
+	wt, err := NewWavetable([]float64{0, 0.25, 0.5, 0.75, 0, 0, 0, 0, 1, 1, 1, 1}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		position, phase float64
+		want            float64
+	}{
+		{position: 0, phase: 0.25, want: 0.25},
+		{position: 0, phase: 0.125, want: 0.125}, // between samples
+		{position: 0, phase: 0.875, want: 0.375}, // between the last sample and the first one
+		{position: 0.25, phase: 0.5, want: 0.25}, // between the first frames
+		{position: 0.75, phase: 0.5, want: 0.5},  // between the last frames
+		{position: 1, phase: 0.5, want: 1},
+		{position: -1, phase: 0.5, want: 0.5}, // clamped to the first frame
+		{position: 2, phase: 0.5, want: 1},    // clamped to the last frame
+		{position: math.NaN(), phase: 0.5, want: 0.5},
+		{position: math.Inf(1), phase: 0.5, want: 1},
+	}
+	for _, tt := range tests {
+		if got := wt.Shape(tt.position)(tt.phase); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("shape at %v, phase %v = %v, want %v", tt.position, tt.phase, got, tt.want)
+		}
+	}
+}
+
+func TestWavetableOscillate(t *testing.T) {
+	// A single sine frame plays like a sine oscillator
+	frame := make([]float64, WavetableFrameSize)
+	for i := range frame {
+		frame[i] = math.Sin(2 * math.Pi * float64(i) / WavetableFrameSize)
+	}
+	wt, err := NewWavetable(frame, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := wt.Oscillate(Const(440), Const(0.5), 0.25)
+	for x := time.Duration(0); x < 100*time.Millisecond; x += time.Second / 44100 {
+		want := math.Sin(2*math.Pi*440*x.Seconds() + math.Pi/2)
+		if math.Abs(got(x)-want) > 1e-5 {
+			t.Fatalf("wavetable at %s = %v, want %v", x, got(x), want)
+		}
+	}
+}
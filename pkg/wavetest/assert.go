@@ -0,0 +1,89 @@
+package wavetest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// Options describes how assertions render waves.
+type Options struct {
+	// SampleRate of the render (default: 44100).
+	SampleRate int
+	// Duration of the render, from 0 (default: 1s).
+	Duration time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.SampleRate <= 0 {
+		o.SampleRate = 44100
+	}
+	if o.Duration <= 0 {
+		o.Duration = time.Second
+	}
+}
+
+func (o Options) render(src wave.Wave) []float64 {
+	return audio.FrameRange(src, o.SampleRate, 0, int(int64(o.Duration)*int64(o.SampleRate)/int64(time.Second)))
+}
+
+func (o Options) frameTime(i int) time.Duration {
+	return time.Duration(int64(i) * int64(time.Second) / int64(o.SampleRate))
+}
+
+// AssertApproxEqual fails the test if a sample of got differs from the sample of want by more than tol.
+// Both waves are rendered once, in order, so they can be stateful.
+func AssertApproxEqual(t testing.TB, got, want wave.Wave, tol float64, opts Options) {
+	t.Helper()
+	opts.setDefaults()
+	g, w := opts.render(got), opts.render(want)
+	for i := range g {
+		if d := math.Abs(g[i] - w[i]); d > tol || math.IsNaN(d) {
+			t.Errorf("waves differ at %s: got %g, want %g (tolerance: %g)", opts.frameTime(i), g[i], w[i], tol)
+			return
+		}
+	}
+}
+
+// AssertPeriodic fails the test if the wave doesn't repeat every period:
+// if a sample differs by more than tol from the sample one period later.
+// The wave is evaluated out of order, so it shouldn't hold state.
+func AssertPeriodic(t testing.TB, w wave.Wave, period time.Duration, tol float64, opts Options) {
+	t.Helper()
+	opts.setDefaults()
+	if period <= 0 {
+		t.Fatalf("invalid period: %s", period)
+	}
+	for i, v := range opts.render(w) {
+		x := opts.frameTime(i)
+		if next := w(x + period); math.Abs(next-v) > tol || math.IsNaN(next-v) {
+			t.Errorf("wave isn't periodic every %s: %g at %s, %g at %s (tolerance: %g)", period, v, x, next, x+period, tol)
+			return
+		}
+	}
+}
+
+// AssertRMSBetween fails the test if the RMS level of the wave (1 for a full-scale square wave)
+// isn't between min and max, for example to check the level of an instrument.
+// The wave is rendered once, in order, so it can be stateful.
+func AssertRMSBetween(t testing.TB, w wave.Wave, min, max float64, opts Options) {
+	t.Helper()
+	opts.setDefaults()
+	if rms := analyze.Measure(opts.render(w), opts.SampleRate, 1).RMS; rms < min || rms > max || math.IsNaN(rms) {
+		t.Errorf("RMS level is %g (%.1f dBFS), want between %g and %g", rms, 20*math.Log10(rms), min, max)
+	}
+}
+
+// AssertNoClipping fails the test if samples of the wave are outside of [-1, 1], and reports where.
+// The wave is rendered once, in order, so it can be stateful.
+func AssertNoClipping(t testing.TB, w wave.Wave, opts Options) {
+	t.Helper()
+	opts.setDefaults()
+	if report := analyze.DetectClipping(opts.render(w), opts.SampleRate, 1); report.Samples > 0 {
+		t.Errorf("wave clips: %s", report)
+	}
+}
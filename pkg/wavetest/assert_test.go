@@ -0,0 +1,54 @@
+package wavetest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+func TestAssertions(t *testing.T) {
+	sine := wave.OscillateSine(wave.Const(100))
+	// A sine that becomes louder after 1.5s
+	swelling := func(x time.Duration) float64 {
+		if x >= 1500*time.Millisecond {
+			return 2 * sine(x)
+		}
+		return sine(x)
+	}
+	long := Options{Duration: 2 * time.Second}
+	tests := []struct {
+		name    string
+		assert  func(tb testing.TB)
+		wantErr string // part of the failure, empty if the assertion should pass
+	}{
+		{name: "equal", assert: func(tb testing.TB) { AssertApproxEqual(tb, sine, sine, 0, Options{}) }},
+		{name: "equal during the first second", assert: func(tb testing.TB) { AssertApproxEqual(tb, swelling, sine, 1e-9, Options{}) }},
+		{name: "different after 1.5s", assert: func(tb testing.TB) { AssertApproxEqual(tb, swelling, sine, 1e-9, long) }, wantErr: "waves differ at 1.500022675s"}, // the sine is 0 at 1.5s
+		{
+			name: "different at another sample rate",
+			assert: func(tb testing.TB) {
+				AssertApproxEqual(tb, swelling, sine, 1e-9, Options{SampleRate: 1000, Duration: 2 * time.Second})
+			},
+			// The first frame after 1.5s at 1000Hz
+			wantErr: "waves differ at 1.501s",
+		},
+		{name: "periodic", assert: func(tb testing.TB) { AssertPeriodic(tb, sine, 10*time.Millisecond, 1e-9, long) }},
+		{name: "not periodic", assert: func(tb testing.TB) { AssertPeriodic(tb, sine, 15*time.Millisecond, 1e-9, Options{}) }, wantErr: "isn't periodic"},
+		{name: "RMS in range", assert: func(tb testing.TB) { AssertRMSBetween(tb, sine, 0.7, 0.72, Options{}) }},
+		{name: "RMS too high", assert: func(tb testing.TB) { AssertRMSBetween(tb, swelling, 0.7, 0.72, long) }, wantErr: "RMS level is"},
+		{name: "no clipping", assert: func(tb testing.TB) { AssertNoClipping(tb, swelling, Options{}) }},
+		{name: "clipping", assert: func(tb testing.TB) { AssertNoClipping(tb, swelling, long) }, wantErr: "wave clips"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := record(t, tt.assert)
+			if tt.wantErr == "" && len(errs) != 0 {
+				t.Errorf("failures = %q, want none", errs)
+			} else if tt.wantErr != "" && (len(errs) != 1 || !strings.Contains(errs[0], tt.wantErr)) {
+				t.Errorf("failures = %q, want one containing %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
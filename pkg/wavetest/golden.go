@@ -0,0 +1,181 @@
+// Package wavetest tests waves against reference renders (golden files),
+// so changes of DSP code that alter the sound of a wave are caught by tests.
+//
+// Golden files are regenerated by running the tests with the WAVETEST_UPDATE environment variable set to 1:
+//
+//	WAVETEST_UPDATE=1 go test ./...
+package wavetest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/analyze"
+	"github.com/ejuju/ziq/pkg/audio"
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// updateEnv is the environment variable regenerating golden files (when set to 1) instead of comparing renders with them.
+const updateEnv = "WAVETEST_UPDATE"
+
+// Number of frames of each spectrum compared (and hop between them).
+const (
+	spectrumWindow = 2048
+	spectrumHop    = spectrumWindow / 2
+)
+
+// Config describes how a wave is rendered and compared with its reference.
+type Config struct {
+	// SampleRate of the render (default: 44100).
+	SampleRate int
+	// Duration of the render (default: 1s).
+	Duration time.Duration
+	// Epsilon is the largest difference allowed between a sample and its reference (default: 1e-6).
+	// A negative value skips the comparison of samples, for renders only expected to sound the same
+	// (for example: when the phase of an oscillator may change).
+	Epsilon float64
+	// SpectralTolerance is the largest difference allowed between the average spectra
+	// of the render and its reference, in dB (default: 0.5dB).
+	// Only bands louder than -80dB are compared.
+	SpectralTolerance float64
+}
+
+func (c *Config) setDefaults() {
+	if c.SampleRate <= 0 {
+		c.SampleRate = 44100
+	}
+	if c.Duration <= 0 {
+		c.Duration = time.Second
+	}
+	if c.Epsilon == 0 {
+		c.Epsilon = 1e-6
+	}
+	if c.SpectralTolerance <= 0 {
+		c.SpectralTolerance = 0.5
+	}
+}
+
+// Golden renders the wave and compares it with the reference render stored at path
+// (raw 64-bit float PCM, little-endian, like the files of wave.ImportPCMClip), failing the test if they differ.
+// With WAVETEST_UPDATE=1 in the environment, the reference is (re)written instead.
+// Since the wave is rendered once, in order, it can be stateful.
+func Golden(t testing.TB, path string, src wave.Wave, config Config) {
+	t.Helper()
+	config.setDefaults()
+	count := int(int64(config.Duration) * int64(config.SampleRate) / int64(time.Second))
+	got := audio.FrameRange(src, config.SampleRate, 0, count)
+
+	if os.Getenv(updateEnv) == "1" {
+		if err := writeGolden(path, got); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated golden file: %s", path)
+		return
+	}
+	want, err := readGolden(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing golden file: %s (run the tests with %s=1 to create it)", path, updateEnv)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := Compare(got, want, config); err != nil {
+		t.Errorf("render differs from golden file: %s: %s", path, err)
+	}
+}
+
+// Compare returns an error describing how (mono) frames differ from their reference:
+// their length, their samples (see Config.Epsilon) or their average spectrum (see Config.SpectralTolerance).
+func Compare(got, want []float64, config Config) error {
+	config.setDefaults()
+	if len(got) != len(want) {
+		return fmt.Errorf("got %d frames, want %d", len(got), len(want))
+	}
+	if len(got) == 0 {
+		return nil
+	}
+
+	if config.Epsilon > 0 {
+		first, worst, count := -1, 0.0, 0
+		for i := range got {
+			if d := math.Abs(got[i] - want[i]); d > config.Epsilon || math.IsNaN(d) {
+				if first < 0 {
+					first = i
+				}
+				worst = math.Max(worst, d)
+				count++
+			}
+		}
+		if first >= 0 {
+			at := time.Duration(int64(first) * int64(time.Second) / int64(config.SampleRate))
+			return fmt.Errorf("%d samples differ by more than %g (up to %g), the first one at %s: got %g, want %g",
+				count, config.Epsilon, worst, at, got[first], want[first])
+		}
+	}
+
+	gotSpectrum, wantSpectrum := averageSpectrum(got, config.SampleRate), averageSpectrum(want, config.SampleRate)
+	for i, b := range gotSpectrum {
+		g, w := decibels(b.Magnitude), decibels(wantSpectrum[i].Magnitude)
+		if math.Max(g, w) < -80 {
+			continue
+		}
+		if math.Abs(g-w) > config.SpectralTolerance {
+			return fmt.Errorf("spectrum differs by %.1fdB at %.0fHz: got %.1fdB, want %.1fdB", math.Abs(g-w), b.Frequency, g, w)
+		}
+	}
+	return nil
+}
+
+// averageSpectrum returns the mean magnitudes of the spectra of overlapping windows of the frames.
+func averageSpectrum(frames []float64, sampleRate int) []analyze.Bin {
+	var average []analyze.Bin
+	count := 0
+	for start := 0; start == 0 || start+spectrumWindow <= len(frames); start += spectrumHop {
+		end := start + spectrumWindow
+		if end > len(frames) {
+			end = len(frames) // padded with silence
+		}
+		bins := analyze.SpectrumWindow(frames[start:end], sampleRate, analyze.Hann)
+		if average == nil {
+			average = bins
+		} else {
+			for i := range average {
+				average[i].Magnitude += bins[i].Magnitude
+			}
+		}
+		count++
+	}
+	for i := range average {
+		average[i].Magnitude /= float64(count)
+	}
+	return average
+}
+
+func decibels(magnitude float64) float64 { return 20 * math.Log10(magnitude) }
+
+func writeGolden(path string, frames []float64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, audio.EncodePCM(nil, frames), 0o644); err != nil {
+		return fmt.Errorf("write file: %s: %w", path, err)
+	}
+	return nil
+}
+
+func readGolden(path string) ([]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %s: %w", path, err)
+	}
+	frames := make([]float64, 0, len(data)/8)
+	for i := 0; i+8 <= len(data); i += 8 {
+		frames = append(frames, math.Float64frombits(binary.LittleEndian.Uint64(data[i:])))
+	}
+	return frames, nil
+}
@@ -0,0 +1,106 @@
+package wavetest
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ejuju/ziq/pkg/wave"
+)
+
+// recorder records the failures of the wavetest helpers, instead of failing the test using them.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// record runs f with a recorder (in its own goroutine, so Fatalf can stop it) and returns the failures.
+func record(t *testing.T, f func(tb testing.TB)) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	return r.errors
+}
+
+func sineFrames(frequency, amplitude float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = amplitude * math.Sin(2*math.Pi*frequency*float64(i)/44100)
+	}
+	return out
+}
+
+func TestCompare(t *testing.T) {
+	reference := sineFrames(440, 0.5, 8192)
+	shifted := append([]float64{0}, reference[:len(reference)-1]...)
+	tests := []struct {
+		name    string
+		got     []float64
+		config  Config
+		wantErr string // part of the error, empty if the frames should match
+	}{
+		{name: "identical", got: reference},
+		{name: "within epsilon", got: sineFrames(440, 0.5+1e-7, 8192)},
+		{name: "different length", got: reference[:100], wantErr: "got 100 frames, want 8192"},
+		{name: "different samples", got: sineFrames(440, 0.51, 8192), wantErr: "samples differ by more than 1e-06"},
+		{name: "samples skipped", got: shifted, config: Config{Epsilon: -1}},
+		{name: "louder", got: sineFrames(440, 0.6, 8192), config: Config{Epsilon: -1}, wantErr: "spectrum differs by 1.6dB"},
+		{name: "louder within the tolerance", got: sineFrames(440, 0.6, 8192), config: Config{Epsilon: -1, SpectralTolerance: 2}},
+		{name: "another frequency", got: sineFrames(880, 0.5, 8192), config: Config{Epsilon: -1}, wantErr: "spectrum differs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare(tt.got, reference, tt.config)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("error = %v, want nil", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "sine.pcm")
+	sine := wave.OscillateSine(wave.Const(440))
+	config := Config{Duration: 100 * time.Millisecond}
+
+	if errs := record(t, func(tb testing.TB) { Golden(tb, path, sine, config) }); len(errs) != 1 || !strings.Contains(errs[0], "missing golden file") {
+		t.Errorf("failures without a golden file = %q, want a missing golden file", errs)
+	}
+
+	t.Setenv(updateEnv, "1")
+	if errs := record(t, func(tb testing.TB) { Golden(tb, path, sine, config) }); len(errs) != 0 {
+		t.Fatalf("failures while updating = %q", errs)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 4410*8 {
+		t.Fatalf("golden file: %v, %v (want %d bytes)", info, err, 4410*8)
+	}
+
+	t.Setenv(updateEnv, "")
+	if errs := record(t, func(tb testing.TB) { Golden(tb, path, sine, config) }); len(errs) != 0 {
+		t.Errorf("failures of the same wave = %q", errs)
+	}
+	louder := wave.Amplitude(sine, wave.Const(1.5))
+	if errs := record(t, func(tb testing.TB) { Golden(tb, path, louder, config) }); len(errs) != 1 || !strings.Contains(errs[0], "render differs") {
+		t.Errorf("failures of a louder wave = %q, want a different render", errs)
+	}
+}